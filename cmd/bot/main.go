@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -15,7 +16,7 @@ import (
 )
 
 // 启动每日零点重置提示状态的定时任务
-func startDailyResetTask(database *db.DB) {
+func startDailyResetTask(database db.Store) {
 	go func() {
 		for {
 			// 计算距离下一个零点的时间
@@ -38,9 +39,80 @@ func startDailyResetTask(database *db.DB) {
 	}()
 }
 
+// printMigrationStatus 打印数据库迁移状态
+func printMigrationStatus(cfg *config.Config) error {
+	database, err := db.Connect(cfg.DatabaseDriver, cfg.DatabaseSource())
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	infos, err := database.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	version, err := database.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("当前结构版本: %d，最新结构版本: %d\n", version, db.LatestSchemaVersion())
+	for _, info := range infos {
+		if info.Applied {
+			fmt.Printf("  [已执行] %3d  %s  (%s)\n", info.Version, info.Description, info.AppliedAt.Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("  [待执行] %3d  %s\n", info.Version, info.Description)
+		}
+	}
+	return nil
+}
+
+// startPruneTask 启动定期清理过期被阻止消息的任务
+func startPruneTask(database db.Store, defaultRetentionDays int) {
+	go func() {
+		for {
+			log.Println("正在清理过期的被阻止消息...")
+			removed, err := database.PruneBlockedMessages(defaultRetentionDays, 1000)
+			if err != nil {
+				log.Printf("清理过期的被阻止消息失败: %v", err)
+			} else {
+				log.Printf("已清理 %d 条过期的被阻止消息", removed)
+			}
+
+			// 有数据被删除时回收磁盘空间
+			if removed > 0 {
+				if err := database.Vacuum(); err != nil {
+					log.Printf("回收数据库空间失败: %v", err)
+				}
+			}
+
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+// startBackupTask 启动定期备份数据库的任务
+func startBackupTask(database db.Store, dir string, interval time.Duration, keep int) {
+	go func() {
+		for {
+			time.Sleep(interval)
+
+			path, err := db.CreateBackup(database, dir, keep)
+			if err != nil {
+				log.Printf("备份数据库失败: %v", err)
+				continue
+			}
+			log.Printf("数据库已备份到 %s", path)
+		}
+	}()
+}
+
 func main() {
 	// 解析命令行参数
 	configPath := flag.String("config", "config.json", "配置文件路径")
+	migrateOnly := flag.Bool("migrate-only", false, "仅执行数据库迁移后退出")
+	migrateStatus := flag.Bool("migrate-status", false, "显示数据库迁移状态后退出")
 	flag.Parse()
 
 	// 加载配置
@@ -49,15 +121,79 @@ func main() {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
-	// 初始化数据库
-	database, err := db.New(cfg.DatabasePath)
-	if err != nil {
-		log.Fatalf("初始化数据库失败: %v", err)
+	// 内存存储不涉及数据库迁移
+	useMemoryStore := cfg.DatabaseSource() == db.MemoryStorePath
+	if useMemoryStore && (*migrateStatus || *migrateOnly) {
+		log.Println("内存存储无需执行数据库迁移")
+		return
 	}
-	defer database.Close()
+
+	// 查看迁移状态，不修改数据库
+	if *migrateStatus {
+		if err := printMigrationStatus(cfg); err != nil {
+			log.Fatalf("获取迁移状态失败: %v", err)
+		}
+		return
+	}
+
+	// 初始化存储
+	var store db.Store
+	if useMemoryStore {
+		log.Println("使用内存存储，重启后所有数据都会丢失")
+		store = db.NewMemoryStore()
+	} else {
+		database, err := db.Connect(cfg.DatabaseDriver, cfg.DatabaseSource())
+		if err != nil {
+			log.Fatalf("初始化数据库失败: %v", err)
+		}
+
+		if err := database.Migrate(); err != nil {
+			database.Close()
+			log.Fatalf("数据库迁移失败: %v", err)
+		}
+
+		if *migrateOnly {
+			// 旧数据库在离线时一次性切换为增量回收模式，之后定期清理只执行增量回收
+			switched, err := database.EnableIncrementalVacuum()
+			if err != nil {
+				database.Close()
+				log.Fatalf("切换增量回收模式失败: %v", err)
+			}
+			if switched {
+				log.Println("数据库已切换为增量回收模式")
+			}
+
+			version, err := database.SchemaVersion()
+			database.Close()
+			if err != nil {
+				log.Fatalf("获取数据库结构版本失败: %v", err)
+			}
+			log.Printf("数据库迁移完成，当前结构版本: %d", version)
+			return
+		}
+
+		store = database
+
+		// 为白名单查询启用进程内缓存。PostgreSQL 数据库可能由多个进程共享，
+		// 缓存无法感知其他进程的修改，因此不启用
+		if cfg.WhitelistCacheTTL > 0 && cfg.DatabaseDriver == db.DriverPostgres {
+			log.Println("使用 PostgreSQL 时不启用白名单缓存")
+		} else if cfg.WhitelistCacheTTL > 0 {
+			store = db.NewCachedStore(store, time.Duration(cfg.WhitelistCacheTTL)*time.Second)
+		}
+	}
+	defer store.Close()
 
 	// 启动每日零点重置提示状态的定时任务
-	startDailyResetTask(database)
+	startDailyResetTask(store)
+
+	// 启动定期清理过期被阻止消息的任务
+	startPruneTask(store, cfg.BlockedMessageRetentionDays)
+
+	// 启动定期备份数据库的任务，仅 SQLite 支持在线备份
+	if cfg.BackupIntervalHours > 0 && !useMemoryStore && cfg.DatabaseDriver == db.DriverSQLite {
+		startBackupTask(store, cfg.BackupDir, time.Duration(cfg.BackupIntervalHours)*time.Hour, cfg.BackupKeep)
+	}
 
 	// 初始化 Telegram Bot
 	bot, err := tgbotapi.NewBotAPI(cfg.Token)
@@ -70,7 +206,8 @@ func main() {
 	log.Printf("授权账号 %s", bot.Self.UserName)
 
 	// 创建消息处理器
-	handler := handlers.New(bot, database, cfg)
+	handler := handlers.New(bot, store, cfg)
+	handler.Start()
 
 	// 设置更新配置
 	updateConfig := tgbotapi.NewUpdate(0)
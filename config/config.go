@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 )
 
@@ -9,9 +10,16 @@ import (
 type Config struct {
 	Token                          string  `json:"token"`                             // Telegram Bot Token
 	DatabasePath                   string  `json:"database_path"`                     // SQLite数据库路径
+	DatabaseDriver                 string  `json:"database_driver"`                   // 数据库驱动：sqlite 或 postgres
+	DatabaseDSN                    string  `json:"database_dsn"`                      // PostgreSQL连接字符串
 	AdminUsers                     []int64 `json:"admin_users"`                       // 全局管理员用户ID列表
 	Debug                          bool    `json:"debug"`                             // 是否启用调试模式
 	RequireRealAccountVerification bool    `json:"require_real_account_verification"` // 是否需要真实账号验证
+	WhitelistCacheTTL              int     `json:"whitelist_cache_ttl"`               // 白名单缓存有效期（秒），负数表示禁用缓存
+	BlockedMessageRetentionDays    int     `json:"blocked_message_retention_days"`    // 被阻止消息的默认保留天数，负数表示永久保留
+	BackupDir                      string  `json:"backup_dir"`                        // 数据库备份目录
+	BackupIntervalHours            int     `json:"backup_interval_hours"`             // 自动备份间隔（小时），负数表示禁用自动备份
+	BackupKeep                     int     `json:"backup_keep"`                       // 保留的备份数量
 }
 
 // LoadConfig 从文件加载配置
@@ -33,6 +41,35 @@ func LoadConfig(path string) (*Config, error) {
 	if config.DatabasePath == "" {
 		config.DatabasePath = "./whitelist.db"
 	}
+	if config.DatabaseDriver == "" {
+		config.DatabaseDriver = "sqlite"
+	}
+	if config.WhitelistCacheTTL == 0 {
+		config.WhitelistCacheTTL = 300
+	}
+	if config.BlockedMessageRetentionDays == 0 {
+		config.BlockedMessageRetentionDays = 30
+	}
+	if config.BackupDir == "" {
+		config.BackupDir = "./backups"
+	}
+	if config.BackupIntervalHours == 0 {
+		config.BackupIntervalHours = 24
+	}
+	if config.BackupKeep <= 0 {
+		config.BackupKeep = 7
+	}
+	if config.DatabaseDriver == "postgres" && config.DatabaseDSN == "" {
+		return nil, fmt.Errorf("使用 postgres 驱动时必须设置 database_dsn")
+	}
 
 	return &config, nil
 }
+
+// DatabaseSource 返回当前驱动使用的数据源：SQLite 为文件路径，PostgreSQL 为连接字符串
+func (c *Config) DatabaseSource() string {
+	if c.DatabaseDriver == "postgres" {
+		return c.DatabaseDSN
+	}
+	return c.DatabasePath
+}
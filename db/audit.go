@@ -0,0 +1,68 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// AddAuditLog 记录一次管理操作
+func (db *DB) AddAuditLog(entry models.AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	_, err := db.conn.Exec(`
+		INSERT INTO audit_log (chat_id, actor_id, action, target_id, before_value, after_value, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, entry.ChatID, entry.ActorID, entry.Action, entry.TargetID, entry.BeforeValue, entry.AfterValue, entry.CreatedAt)
+	return err
+}
+
+// GetAuditLogs 按时间倒序分页获取群组的审计日志，同时返回符合条件的总条数
+func (db *DB) GetAuditLogs(chatID int64, filter models.AuditFilter, limit, offset int) ([]models.AuditEntry, int, error) {
+	conditions := []string{"chat_id = ?"}
+	args := []interface{}{chatID}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetID != 0 {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM audit_log WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT id, chat_id, actor_id, action, target_id, before_value, after_value, created_at
+		FROM audit_log
+		WHERE `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		err := rows.Scan(
+			&entry.ID, &entry.ChatID, &entry.ActorID, &entry.Action,
+			&entry.TargetID, &entry.BeforeValue, &entry.AfterValue, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, total, rows.Err()
+}
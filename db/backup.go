@@ -0,0 +1,75 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 备份文件名的前缀和后缀
+const (
+	backupFilePrefix = "whitelist-"
+	backupFileSuffix = ".db"
+)
+
+// Backup 使用 VACUUM INTO 在线生成数据库快照，目标文件不能已存在
+func (db *DB) Backup(path string) error {
+	if db.conn.dialect != dialectSQLite {
+		return fmt.Errorf("%s 数据库不支持在线备份，请使用数据库自带的备份工具", db.conn.dialect)
+	}
+
+	_, err := db.conn.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+// CreateBackup 在备份目录中生成一份新的快照，并只保留最新的 keep 份，返回快照路径
+func CreateBackup(store Store, dir string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	name := backupFilePrefix + time.Now().Format("20060102-150405") + backupFileSuffix
+	path := filepath.Join(dir, name)
+	if err := store.Backup(path); err != nil {
+		return "", err
+	}
+
+	if err := rotateBackups(dir, keep); err != nil {
+		return path, fmt.Errorf("清理旧备份失败: %w", err)
+	}
+
+	return path, nil
+}
+
+// rotateBackups 删除备份目录中超出保留数量的旧快照
+func rotateBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, backupFilePrefix) && strings.HasSuffix(name, backupFileSuffix) {
+			backups = append(backups, name)
+		}
+	}
+
+	// 文件名包含时间戳，按名称倒序即为从新到旧
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(dir, backups[i])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
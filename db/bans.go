@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// AddSenderChatBan 记录群组中被封禁的频道，expiresAt 为零值时永久封禁。
+// 已有记录（包括已解封的记录）时覆盖为新的封禁信息
+func (db *DB) AddSenderChatBan(chatID, channelID, bannedBy int64, reason string, expiresAt time.Time) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO banned_sender_chats (chat_id, channel_id, banned_by, banned_at, reason, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, channel_id) DO UPDATE SET
+			banned_by = excluded.banned_by,
+			banned_at = excluded.banned_at,
+			reason = excluded.reason,
+			expires_at = excluded.expires_at,
+			unbanned_at = NULL
+	`, chatID, channelID, bannedBy, time.Now(), reason, nullTime(expiresAt))
+	return err
+}
+
+// RemoveSenderChatBan 解除频道的封禁，保留记录并写入解封时间
+func (db *DB) RemoveSenderChatBan(chatID, channelID int64) error {
+	_, err := db.conn.Exec(`
+		UPDATE banned_sender_chats SET unbanned_at = ?
+		WHERE chat_id = ? AND channel_id = ? AND unbanned_at IS NULL
+	`, time.Now(), chatID, channelID)
+	return err
+}
+
+// GetSenderChatUnbanTime 获取频道在群组中最近一次解除封禁的时间，从未被解封或仍在封禁中时返回零值
+func (db *DB) GetSenderChatUnbanTime(chatID, channelID int64) (time.Time, error) {
+	var unbannedAt sql.NullTime
+	err := db.conn.QueryRow(`
+		SELECT unbanned_at FROM banned_sender_chats
+		WHERE chat_id = ? AND channel_id = ?
+	`, chatID, channelID).Scan(&unbannedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return unbannedAt.Time, err
+}
+
+// IsSenderChatBanned 检查频道在群组中是否处于封禁中
+func (db *DB) IsSenderChatBanned(chatID, channelID int64) (bool, error) {
+	var count int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM banned_sender_chats
+		WHERE chat_id = ? AND channel_id = ? AND unbanned_at IS NULL
+	`, chatID, channelID).Scan(&count)
+	return count > 0, err
+}
+
+// GetSenderChatBans 获取群组中被封禁的频道，按封禁时间倒序
+func (db *DB) GetSenderChatBans(chatID int64) ([]models.SenderChatBan, error) {
+	return db.querySenderChatBans(`
+		SELECT id, chat_id, channel_id, banned_by, banned_at, reason, expires_at
+		FROM banned_sender_chats
+		WHERE chat_id = ? AND unbanned_at IS NULL
+		ORDER BY banned_at DESC
+	`, chatID)
+}
+
+// GetExpiredSenderChatBans 获取在 now 之前到期的临时封禁
+func (db *DB) GetExpiredSenderChatBans(now time.Time) ([]models.SenderChatBan, error) {
+	return db.querySenderChatBans(`
+		SELECT id, chat_id, channel_id, banned_by, banned_at, reason, expires_at
+		FROM banned_sender_chats
+		WHERE expires_at IS NOT NULL AND expires_at <= ? AND unbanned_at IS NULL
+	`, now)
+}
+
+// querySenderChatBans 执行查询并扫描封禁记录
+func (db *DB) querySenderChatBans(query string, args ...interface{}) ([]models.SenderChatBan, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []models.SenderChatBan
+	for rows.Next() {
+		var ban models.SenderChatBan
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&ban.ID, &ban.ChatID, &ban.ChannelID, &ban.BannedBy, &ban.BannedAt, &ban.Reason, &expiresAt); err != nil {
+			return nil, err
+		}
+		ban.ExpiresAt = expiresAt.Time
+		bans = append(bans, ban)
+	}
+
+	return bans, rows.Err()
+}
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// maxWhitelistCacheEntries 缓存条目上限，超过后清理过期条目
+const maxWhitelistCacheEntries = 10000
+
+// whitelistCacheEntry 白名单查询结果的缓存条目，包括未命中白名单的结果
+type whitelistCacheEntry struct {
+	whitelisted bool
+	expiresAt   time.Time
+}
+
+// globalListCacheEntry 全局名单查询结果的缓存条目，listType 为空表示不在任何名单中
+type globalListCacheEntry struct {
+	listType  string
+	expiresAt time.Time
+}
+
+// CacheStats 白名单缓存的统计信息
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// CachedStore 为 Store 的白名单查询增加进程内缓存，写入白名单时同步失效对应条目。
+// 缓存只能感知本进程的写入，其他进程的修改最多延迟 ttl 生效，因此只适合单进程使用的数据库
+type CachedStore struct {
+	Store
+
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[whitelistKey]whitelistCacheEntry
+	global  map[int64]globalListCacheEntry
+	hits    uint64
+	misses  uint64
+
+	// generation 每次失效缓存时递增。查询开始后缓存被失效时，查询结果可能已经过时，不再写入缓存
+	generation uint64
+}
+
+// NewCachedStore 创建带白名单缓存的 Store，ttl 为缓存条目的有效期
+func NewCachedStore(store Store, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		Store:   store,
+		ttl:     ttl,
+		entries: make(map[whitelistKey]whitelistCacheEntry),
+		global:  make(map[int64]globalListCacheEntry),
+	}
+}
+
+// IsChannelWhitelisted 检查频道是否在白名单中，优先使用缓存
+func (c *CachedStore) IsChannelWhitelisted(chatID, channelID int64) (bool, error) {
+	key := whitelistKey{ChatID: chatID, ChannelID: channelID}
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if ok && time.Now().Before(entry.expiresAt) {
+		atomic.AddUint64(&c.hits, 1)
+		return entry.whitelisted, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	generation := c.currentGeneration()
+	whitelisted, err := c.Store.IsChannelWhitelisted(chatID, channelID)
+	if err != nil {
+		return false, err
+	}
+
+	// 临时白名单条目的缓存不能超过条目本身的到期时间
+	expiresAt := time.Now().Add(c.ttl)
+	if whitelisted {
+		channel, err := c.Store.GetWhitelistEntry(chatID, channelID)
+		if err != nil {
+			return false, err
+		}
+		if channel.IsTemporary() && channel.ExpiresAt.Before(expiresAt) {
+			expiresAt = channel.ExpiresAt
+		}
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		if len(c.entries) >= maxWhitelistCacheEntries {
+			c.evictExpiredLocked()
+		}
+		c.entries[key] = whitelistCacheEntry{
+			whitelisted: whitelisted,
+			expiresAt:   expiresAt,
+		}
+	}
+	c.mu.Unlock()
+
+	return whitelisted, nil
+}
+
+// AddChannelToWhitelist 将频道添加到白名单并使缓存失效
+func (c *CachedStore) AddChannelToWhitelist(chatID, channelID, addedBy int64, username, description string, expiresAt time.Time) error {
+	err := c.Store.AddChannelToWhitelist(chatID, channelID, addedBy, username, description, expiresAt)
+	c.InvalidateWhitelist(chatID, channelID)
+	return err
+}
+
+// RemoveChannelFromWhitelist 从白名单中移除频道并使缓存失效
+func (c *CachedStore) RemoveChannelFromWhitelist(chatID, channelID int64) error {
+	err := c.Store.RemoveChannelFromWhitelist(chatID, channelID)
+	c.InvalidateWhitelist(chatID, channelID)
+	return err
+}
+
+// RemoveExpiredWhitelistEntries 删除过期的白名单条目并使对应缓存失效
+func (c *CachedStore) RemoveExpiredWhitelistEntries(now time.Time) ([]models.WhitelistedChannel, error) {
+	expired, err := c.Store.RemoveExpiredWhitelistEntries(now)
+	for _, channel := range expired {
+		c.InvalidateWhitelist(channel.ChatID, channel.ChannelID)
+	}
+	return expired, err
+}
+
+// DeleteWhitelistSet 删除共享白名单并清空白名单缓存
+func (c *CachedStore) DeleteWhitelistSet(setID int64) error {
+	err := c.Store.DeleteWhitelistSet(setID)
+	c.invalidateWhitelistEntries(func(whitelistKey) bool { return true })
+	return err
+}
+
+// AddChannelToSet 将频道加入共享白名单并使该频道的缓存失效
+func (c *CachedStore) AddChannelToSet(setID, channelID, addedBy int64) error {
+	err := c.Store.AddChannelToSet(setID, channelID, addedBy)
+	c.invalidateWhitelistEntries(func(key whitelistKey) bool { return key.ChannelID == channelID })
+	return err
+}
+
+// RemoveChannelFromSet 将频道从共享白名单中移除并使该频道的缓存失效
+func (c *CachedStore) RemoveChannelFromSet(setID, channelID int64) error {
+	err := c.Store.RemoveChannelFromSet(setID, channelID)
+	c.invalidateWhitelistEntries(func(key whitelistKey) bool { return key.ChannelID == channelID })
+	return err
+}
+
+// SubscribeToSet 群组订阅共享白名单并使该群组的缓存失效
+func (c *CachedStore) SubscribeToSet(chatID, setID, subscribedBy int64) error {
+	err := c.Store.SubscribeToSet(chatID, setID, subscribedBy)
+	c.invalidateWhitelistEntries(func(key whitelistKey) bool { return key.ChatID == chatID })
+	return err
+}
+
+// UnsubscribeFromSet 群组取消订阅共享白名单并使该群组的缓存失效
+func (c *CachedStore) UnsubscribeFromSet(chatID, setID int64) error {
+	err := c.Store.UnsubscribeFromSet(chatID, setID)
+	c.invalidateWhitelistEntries(func(key whitelistKey) bool { return key.ChatID == chatID })
+	return err
+}
+
+// invalidateWhitelistEntries 使满足条件的白名单缓存失效
+func (c *CachedStore) invalidateWhitelistEntries(match func(whitelistKey) bool) {
+	c.mu.Lock()
+	c.generation++
+	for key := range c.entries {
+		if match(key) {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// GetGlobalListType 获取频道所在的全局名单类型，优先使用缓存
+func (c *CachedStore) GetGlobalListType(channelID int64) (string, error) {
+	c.mu.RLock()
+	entry, ok := c.global[channelID]
+	c.mu.RUnlock()
+
+	if ok && time.Now().Before(entry.expiresAt) {
+		atomic.AddUint64(&c.hits, 1)
+		return entry.listType, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	generation := c.currentGeneration()
+	listType, err := c.Store.GetGlobalListType(channelID)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		if len(c.global) >= maxWhitelistCacheEntries {
+			c.global = make(map[int64]globalListCacheEntry)
+		}
+		c.global[channelID] = globalListCacheEntry{
+			listType:  listType,
+			expiresAt: time.Now().Add(c.ttl),
+		}
+	}
+	c.mu.Unlock()
+
+	return listType, nil
+}
+
+// SetGlobalListEntry 将频道加入全局名单并使缓存失效
+func (c *CachedStore) SetGlobalListEntry(listType string, channelID, addedBy int64, description string) error {
+	err := c.Store.SetGlobalListEntry(listType, channelID, addedBy, description)
+	c.invalidateGlobalList(channelID)
+	return err
+}
+
+// RemoveGlobalListEntry 将频道从全局名单中移除并使缓存失效
+func (c *CachedStore) RemoveGlobalListEntry(listType string, channelID int64) error {
+	err := c.Store.RemoveGlobalListEntry(listType, channelID)
+	c.invalidateGlobalList(channelID)
+	return err
+}
+
+// invalidateGlobalList 使指定频道的全局名单缓存失效
+func (c *CachedStore) invalidateGlobalList(channelID int64) {
+	c.mu.Lock()
+	c.generation++
+	delete(c.global, channelID)
+	c.mu.Unlock()
+}
+
+// InvalidateWhitelist 使指定群组和频道的缓存失效
+func (c *CachedStore) InvalidateWhitelist(chatID, channelID int64) {
+	c.mu.Lock()
+	c.generation++
+	delete(c.entries, whitelistKey{ChatID: chatID, ChannelID: channelID})
+	c.mu.Unlock()
+}
+
+// InvalidateAll 清空全部缓存
+func (c *CachedStore) InvalidateAll() {
+	c.mu.Lock()
+	c.generation++
+	c.entries = make(map[whitelistKey]whitelistCacheEntry)
+	c.global = make(map[int64]globalListCacheEntry)
+	c.mu.Unlock()
+}
+
+// currentGeneration 获取当前的缓存代数，查询数据库前调用
+func (c *CachedStore) currentGeneration() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.generation
+}
+
+// WhitelistCacheStats 获取缓存命中统计
+func (c *CachedStore) WhitelistCacheStats() CacheStats {
+	c.mu.RLock()
+	entries := len(c.entries) + len(c.global)
+	c.mu.RUnlock()
+
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: entries,
+	}
+}
+
+// evictExpiredLocked 清理过期条目，清理后仍超过上限时清空缓存，调用方需持有写锁
+func (c *CachedStore) evictExpiredLocked() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) >= maxWhitelistCacheEntries {
+		c.entries = make(map[whitelistKey]whitelistCacheEntry)
+	}
+}
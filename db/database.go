@@ -12,37 +12,61 @@ import (
 
 // DB 封装数据库连接和操作
 type DB struct {
-	conn *sql.DB
+	conn *conn
 }
 
-// New 创建一个新的数据库连接
+// New 创建一个新的数据库连接，并执行所有未执行的结构迁移
 func New(dbPath string) (*DB, error) {
-	conn, err := sql.Open("sqlite", dbPath)
+	db, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Connect 按驱动名称打开数据库连接但不执行迁移
+func Connect(driver, source string) (*DB, error) {
+	switch driver {
+	case "", DriverSQLite:
+		return Open(source)
+	case DriverPostgres:
+		return OpenPostgres(source)
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+	}
+}
+
+// Open 打开数据库连接但不执行迁移
+func Open(dbPath string) (*DB, error) {
+	sqlDB, err := sql.Open("sqlite", dbPath)
 	if err != nil {
 		return nil, err
 	}
 
 	// 设置连接池参数
-	conn.SetMaxOpenConns(1)
-	conn.SetMaxIdleConns(1)
-	conn.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	if _, err := conn.Exec(`PRAGMA journal_mode=WAL;
+	if _, err := sqlDB.Exec(`PRAGMA journal_mode=WAL;
 		PRAGMA synchronous=NORMAL;
 		PRAGMA busy_timeout=10000;
 		PRAGMA cache_size=10000;
 		PRAGMA temp_store=MEMORY;
 		PRAGMA mmap_size=30000000;
-		PRAGMA page_size=4096;`); err != nil {
+		PRAGMA page_size=4096;
+		PRAGMA auto_vacuum=INCREMENTAL;`); err != nil {
+		sqlDB.Close()
 		return nil, err
 	}
 
-	db := &DB{conn: conn}
-	if err := db.init(); err != nil {
-		return nil, err
-	}
-
-	return db, nil
+	return &DB{conn: &conn{DB: sqlDB, dialect: dialectSQLite}}, nil
 }
 
 // Close 关闭数据库连接
@@ -50,105 +74,21 @@ func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
-// init 初始化数据库表结构
-func (db *DB) init() error {
-	// 创建白名单频道表
-	_, err := db.conn.Exec(`
-		CREATE TABLE IF NOT EXISTS whitelisted_channels (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			chat_id INTEGER NOT NULL,
-			channel_id INTEGER NOT NULL,
-			added_by INTEGER NOT NULL,
-			added_at TIMESTAMP NOT NULL,
-			description TEXT,
-			UNIQUE(chat_id, channel_id)
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// 创建被阻止的消息表
-	_, err = db.conn.Exec(`
-		CREATE TABLE IF NOT EXISTS blocked_messages (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			chat_id INTEGER NOT NULL,
-			channel_id INTEGER NOT NULL,
-			message_id INTEGER NOT NULL,
-			blocked_at TIMESTAMP NOT NULL,
-			message_text TEXT
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// 创建群组设置表
-	_, err = db.conn.Exec(`
-		CREATE TABLE IF NOT EXISTS group_settings (
-			chat_id INTEGER PRIMARY KEY,
-			admin_only BOOLEAN NOT NULL DEFAULT 1,
-			log_channel_id INTEGER NOT NULL DEFAULT 0,
-			enabled BOOLEAN NOT NULL DEFAULT 1
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// 创建频道申请表
-	_, err = db.conn.Exec(`
-		CREATE TABLE IF NOT EXISTS channel_applications (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			chat_id INTEGER NOT NULL,
-			channel_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			reason TEXT,
-			applied_at TIMESTAMP NOT NULL,
-			status TEXT NOT NULL DEFAULT 'pending',
-			verified_channel BOOLEAN NOT NULL DEFAULT 0,
-			last_prompt_date DATE,
-			prompted_today BOOLEAN NOT NULL DEFAULT 0,
-			UNIQUE(chat_id, channel_id, user_id)
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// 创建用户状态表
-	_, err = db.conn.Exec(`
-		CREATE TABLE IF NOT EXISTS user_states (
-			user_id INTEGER PRIMARY KEY,
-			state TEXT,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-
-	// 创建每日频道提示记录表
-	_, err = db.conn.Exec(`
-		CREATE TABLE IF NOT EXISTS channel_daily_prompts (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			chat_id INTEGER NOT NULL,
-			channel_id INTEGER NOT NULL,
-			prompt_type TEXT NOT NULL,
-			prompt_date DATE NOT NULL,
-			UNIQUE(chat_id, channel_id, prompt_type, prompt_date)
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	return err
+// nullTime 将零值时间转换为 NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
 }
 
-// AddChannelToWhitelist 将频道添加到白名单
-func (db *DB) AddChannelToWhitelist(chatID, channelID, addedBy int64, description string) error {
+// AddChannelToWhitelist 将频道添加到白名单，username 为频道的公开用户名（不含 @，可以为空），
+// expiresAt 为零值时永久有效。已存在的条目（包括已过期但尚未清理的条目）会被覆盖
+func (db *DB) AddChannelToWhitelist(chatID, channelID, addedBy int64, username, description string, expiresAt time.Time) error {
 	_, err := db.conn.Exec(`
-		INSERT INTO whitelisted_channels (chat_id, channel_id, added_by, added_at, description)
-		VALUES (?, ?, ?, ?, ?)
-	`, chatID, channelID, addedBy, time.Now(), description)
+		INSERT INTO whitelisted_channels (chat_id, channel_id, added_by, added_at, username, description, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, channel_id) DO UPDATE SET
+		added_by = excluded.added_by, added_at = excluded.added_at, username = excluded.username,
+		description = excluded.description, expires_at = excluded.expires_at
+	`, chatID, channelID, addedBy, time.Now(), username, description, nullTime(expiresAt))
 	return err
 }
 
@@ -161,13 +101,18 @@ func (db *DB) RemoveChannelFromWhitelist(chatID, channelID int64) error {
 	return err
 }
 
-// IsChannelWhitelisted 检查频道是否在白名单中
+// IsChannelWhitelisted 检查频道是否在群组的白名单或群组订阅的共享白名单中，已过期的条目视为不在白名单中
 func (db *DB) IsChannelWhitelisted(chatID, channelID int64) (bool, error) {
 	var count int
 	err := db.conn.QueryRow(`
-		SELECT COUNT(*) FROM whitelisted_channels
-		WHERE chat_id = ? AND channel_id = ?
-	`, chatID, channelID).Scan(&count)
+		SELECT
+			(SELECT COUNT(*) FROM whitelisted_channels
+			WHERE chat_id = ? AND channel_id = ? AND (expires_at IS NULL OR expires_at > ?))
+			+
+			(SELECT COUNT(*) FROM whitelist_set_subscriptions s
+			JOIN whitelist_set_channels c ON c.set_id = s.set_id
+			WHERE s.chat_id = ? AND c.channel_id = ?)
+	`, chatID, channelID, time.Now(), chatID, channelID).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -177,7 +122,7 @@ func (db *DB) IsChannelWhitelisted(chatID, channelID int64) (bool, error) {
 // GetWhitelistedChannels 获取群组的白名单频道列表
 func (db *DB) GetWhitelistedChannels(chatID int64) ([]models.WhitelistedChannel, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, chat_id, channel_id, added_by, added_at, description
+		SELECT `+whitelistColumns+`
 		FROM whitelisted_channels
 		WHERE chat_id = ?
 		ORDER BY added_at DESC
@@ -189,43 +134,98 @@ func (db *DB) GetWhitelistedChannels(chatID int64) ([]models.WhitelistedChannel,
 
 	var channels []models.WhitelistedChannel
 	for rows.Next() {
-		var channel models.WhitelistedChannel
-		var addedAt string
-		err := rows.Scan(
-			&channel.ID,
-			&channel.ChatID,
-			&channel.ChannelID,
-			&channel.AddedBy,
-			&addedAt,
-			&channel.Description,
-		)
+		channel, err := scanWhitelistedChannel(rows)
 		if err != nil {
 			return nil, err
 		}
+		channels = append(channels, channel)
+	}
+
+	return channels, nil
+}
+
+// whitelistColumns 白名单表的查询列，顺序与 scanWhitelistedChannel 一致
+const whitelistColumns = `id, chat_id, channel_id, added_by, added_at, username, description, expires_at, quota_posts, quota_window_seconds, content_policy`
+
+// scanWhitelistedChannel 扫描一行白名单记录
+func scanWhitelistedChannel(row rowScanner) (models.WhitelistedChannel, error) {
+	var channel models.WhitelistedChannel
+	var description sql.NullString
+	var expiresAt sql.NullTime
+	var quotaWindowSeconds int64
+	var contentPolicy string
+	err := row.Scan(
+		&channel.ID,
+		&channel.ChatID,
+		&channel.ChannelID,
+		&channel.AddedBy,
+		&channel.AddedAt,
+		&channel.Username,
+		&description,
+		&expiresAt,
+		&channel.QuotaPosts,
+		&quotaWindowSeconds,
+		&contentPolicy,
+	)
+	if err != nil {
+		return channel, err
+	}
+	channel.Description = description.String
+	channel.ExpiresAt = expiresAt.Time
+	channel.QuotaWindow = time.Duration(quotaWindowSeconds) * time.Second
+	channel.ContentPolicy = models.ParseContentPolicy(contentPolicy)
+	return channel, nil
+}
+
+// RemoveExpiredWhitelistEntries 删除在 now 之前过期的白名单条目，返回被删除的条目
+func (db *DB) RemoveExpiredWhitelistEntries(now time.Time) ([]models.WhitelistedChannel, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-		// 解析时间
-		t, err := time.Parse("2006-01-02 15:04:05", addedAt)
+	rows, err := tx.Query(`
+		SELECT `+whitelistColumns+`
+		FROM whitelisted_channels
+		WHERE expires_at IS NOT NULL AND expires_at <= ?
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []models.WhitelistedChannel
+	for rows.Next() {
+		channel, err := scanWhitelistedChannel(rows)
 		if err != nil {
-			// 如果解析失败，使用当前时间
-			t = time.Now()
+			rows.Close()
+			return nil, err
 		}
-		channel.AddedAt = t
+		expired = append(expired, channel)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		channels = append(channels, channel)
+	for _, channel := range expired {
+		if _, err := tx.Exec(`DELETE FROM whitelisted_channels WHERE id = ?`, channel.ID); err != nil {
+			return nil, err
+		}
 	}
 
-	return channels, nil
+	return expired, tx.Commit()
 }
 
-// LogBlockedMessage 记录被阻止的消息
-func (db *DB) LogBlockedMessage(chatID, channelID int64, messageID int, messageText string) error {
+// LogBlockedMessage 记录被阻止的消息，reason 为阻止原因，observed 表示观察模式下未实际删除
+func (db *DB) LogBlockedMessage(chatID, channelID int64, messageID int, messageText, reason string, observed bool) error {
 	// 添加重试机制
 	var err error
 	for i := 0; i < 5; i++ {
 		_, err = db.conn.Exec(`
-			INSERT INTO blocked_messages (chat_id, channel_id, message_id, blocked_at, message_text)
-			VALUES (?, ?, ?, ?, ?)
-		`, chatID, channelID, messageID, time.Now(), messageText)
+			INSERT INTO blocked_messages (chat_id, channel_id, message_id, blocked_at, message_text, reason, observed)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, chatID, channelID, messageID, time.Now(), messageText, reason, observed)
 
 		if err == nil {
 			return nil
@@ -244,48 +244,79 @@ func (db *DB) LogBlockedMessage(chatID, channelID int64, messageID int, messageT
 	return err
 }
 
-// GetBlockedMessagesStats 获取被阻止消息的统计信息
+// GetBlockedMessagesStats 获取被阻止消息的统计信息，不包括观察模式下未实际删除的消息
 func (db *DB) GetBlockedMessagesStats(chatID int64) (int, error) {
 	var count int
 	err := db.conn.QueryRow(`
 		SELECT COUNT(*) FROM blocked_messages
-		WHERE chat_id = ?
-	`, chatID).Scan(&count)
+		WHERE chat_id = ? AND observed = ?
+	`, chatID, false).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
 	return count, nil
 }
 
-// GetOrCreateGroupSettings 获取或创建群组设置
-func (db *DB) GetOrCreateGroupSettings(chatID int64) (models.GroupSettings, error) {
-	var settings models.GroupSettings
+// groupSettingsColumns 群组设置表的查询列，顺序与 scanGroupSettings 一致
+const groupSettingsColumns = `chat_id, admin_only, log_channel_id, enabled, retention_days, use_global_lists, ban_threshold, escalation_window_hours, linked_channel_admin,
+	linked_channel_id, linked_channel_auto, observe_mode, observe_since, review_quorum,
+	reapply_cooldown_seconds, unclaimed_timeout_seconds, unreviewed_timeout_seconds, ownership_policy`
 
-	// 尝试获取设置
-	err := db.conn.QueryRow(`
-		SELECT chat_id, admin_only, log_channel_id, enabled
-		FROM group_settings
-		WHERE chat_id = ?
-	`, chatID).Scan(
+// scanGroupSettings 读取一行群组设置
+func scanGroupSettings(row rowScanner) (models.GroupSettings, error) {
+	var settings models.GroupSettings
+	var observeSince sql.NullTime
+	var reapplyCooldownSeconds, unclaimedTimeoutSeconds, unreviewedTimeoutSeconds int64
+	var ownershipPolicy string
+	err := row.Scan(
 		&settings.ChatID,
 		&settings.AdminOnly,
 		&settings.LogChannelID,
 		&settings.Enabled,
+		&settings.RetentionDays,
+		&settings.UseGlobalLists,
+		&settings.BanThreshold,
+		&settings.EscalationWindowHours,
+		&settings.LinkedChannelAdmin,
+		&settings.LinkedChannelID,
+		&settings.LinkedChannelAuto,
+		&settings.ObserveMode,
+		&observeSince,
+		&settings.ReviewQuorum,
+		&reapplyCooldownSeconds,
+		&unclaimedTimeoutSeconds,
+		&unreviewedTimeoutSeconds,
+		&ownershipPolicy,
 	)
+	if err != nil {
+		return settings, err
+	}
+	settings.ObserveSince = observeSince.Time
+	settings.ReapplyCooldown = time.Duration(reapplyCooldownSeconds) * time.Second
+	settings.UnclaimedTimeout = time.Duration(unclaimedTimeoutSeconds) * time.Second
+	settings.UnreviewedTimeout = time.Duration(unreviewedTimeoutSeconds) * time.Second
+	settings.OwnershipPolicy = models.ParseOwnershipPolicy(ownershipPolicy)
+	return settings, nil
+}
 
-	// 如果不存在则创建
+// GetOrCreateGroupSettings 获取或创建群组设置
+func (db *DB) GetOrCreateGroupSettings(chatID int64) (models.GroupSettings, error) {
+	// 尝试获取设置
+	query := `SELECT ` + groupSettingsColumns + ` FROM group_settings WHERE chat_id = ?`
+	settings, err := scanGroupSettings(db.conn.QueryRow(query, chatID))
+
+	// 如果不存在则使用默认值创建
 	if err == sql.ErrNoRows {
-		settings = models.GroupSettings{
-			ChatID:       chatID,
-			AdminOnly:    true,
-			LogChannelID: 0,
-			Enabled:      true,
+		_, err := db.conn.Exec(`
+			INSERT INTO group_settings (chat_id)
+			VALUES (?)
+			ON CONFLICT(chat_id) DO NOTHING
+		`, chatID)
+		if err != nil {
+			return models.GroupSettings{}, err
 		}
 
-		_, err := db.conn.Exec(`
-			INSERT INTO group_settings (chat_id, admin_only, log_channel_id, enabled)
-			VALUES (?, ?, ?, ?)
-		`, settings.ChatID, settings.AdminOnly, settings.LogChannelID, settings.Enabled)
+		settings, err = scanGroupSettings(db.conn.QueryRow(query, chatID))
 		if err != nil {
 			return models.GroupSettings{}, err
 		}
@@ -300,76 +331,69 @@ func (db *DB) GetOrCreateGroupSettings(chatID int64) (models.GroupSettings, erro
 	return settings, nil
 }
 
+// UpdateLinkedChannelID 只更新群组记录的关联频道，不覆盖其他可能已被管理员修改的设置
+func (db *DB) UpdateLinkedChannelID(chatID, linkedChannelID int64) error {
+	_, err := db.conn.Exec(`UPDATE group_settings SET linked_channel_id = ? WHERE chat_id = ?`, linkedChannelID, chatID)
+	return err
+}
+
 // UpdateGroupSettings 更新群组设置
 func (db *DB) UpdateGroupSettings(settings models.GroupSettings) error {
 	_, err := db.conn.Exec(`
 		UPDATE group_settings
-		SET admin_only = ?, log_channel_id = ?, enabled = ?
+		SET admin_only = ?, log_channel_id = ?, enabled = ?, retention_days = ?, use_global_lists = ?, ban_threshold = ?,
+			escalation_window_hours = ?, linked_channel_admin = ?, linked_channel_id = ?, linked_channel_auto = ?,
+			observe_mode = ?, observe_since = ?, review_quorum = ?, reapply_cooldown_seconds = ?,
+			unclaimed_timeout_seconds = ?, unreviewed_timeout_seconds = ?, ownership_policy = ?
 		WHERE chat_id = ?
-	`, settings.AdminOnly, settings.LogChannelID, settings.Enabled, settings.ChatID)
+	`, settings.AdminOnly, settings.LogChannelID, settings.Enabled, settings.RetentionDays, settings.UseGlobalLists,
+		settings.BanThreshold, settings.EscalationWindowHours, settings.LinkedChannelAdmin, settings.LinkedChannelID,
+		settings.LinkedChannelAuto, settings.ObserveMode, nullTime(settings.ObserveSince), settings.ReviewQuorum,
+		int64(settings.ReapplyCooldown/time.Second), int64(settings.UnclaimedTimeout/time.Second),
+		int64(settings.UnreviewedTimeout/time.Second), settings.OwnershipPolicy.String(), settings.ChatID)
 	return err
 }
 
-// CreateChannelApplication 创建频道申请
-func (db *DB) CreateChannelApplication(chatID, channelID, userID int64, reason string) error {
-	// 检查是否已存在该频道的申请记录
-	var existingID int64
-	var existingStatus string
-
-	err := db.conn.QueryRow(`
-		SELECT id, status
-		FROM channel_applications
-		WHERE chat_id = ? AND channel_id = ?
-		ORDER BY applied_at DESC
-		LIMIT 1
-	`, chatID, channelID).Scan(&existingID, &existingStatus)
-
-	if err != nil && err != sql.ErrNoRows {
-		return err
+// GetAllGroupSettings 获取所有群组的设置
+func (db *DB) GetAllGroupSettings() ([]models.GroupSettings, error) {
+	rows, err := db.conn.Query(`SELECT ` + groupSettingsColumns + ` FROM group_settings ORDER BY chat_id`)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	// 如果已存在申请且状态为pending，则返回错误
-	if existingID > 0 && existingStatus == "pending" {
-		return fmt.Errorf("该频道已有待审核的申请")
+	var all []models.GroupSettings
+	for rows.Next() {
+		settings, err := scanGroupSettings(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, settings)
 	}
+	return all, rows.Err()
+}
 
-	// 如果不存在申请或申请已被拒绝，创建/更新申请
-	if existingID > 0 {
-		// 使用现有记录ID更新
-		_, err = db.conn.Exec(`
-			UPDATE channel_applications
-			SET user_id = ?, reason = ?, applied_at = ?, status = ?, verified_channel = 0
-			WHERE id = ?
-		`, userID, reason, time.Now(), "pending", existingID)
-		return err
-	} else {
-		// 创建新记录
-		_, err = db.conn.Exec(`
-			INSERT INTO channel_applications (chat_id, channel_id, user_id, reason, applied_at, status)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`, chatID, channelID, userID, reason, time.Now(), "pending")
-		return err
-	}
+// channelApplicationColumns 查询频道申请时使用的列，顺序与 scanChannelApplication 一致
+const channelApplicationColumns = `id, chat_id, channel_id, user_id, reason, applied_at, status, verified_channel,
+	last_prompt_date, reviewer_id, decided_at, decision_note, claimant_id, claimed_at`
+
+// rowScanner 是 *sql.Row 和 *sql.Rows 共有的 Scan 方法
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
-// GetChannelApplication 获取频道申请信息
-func (db *DB) GetChannelApplication(chatID, channelID, userID int64) (models.ChannelApplication, error) {
+// scanChannelApplication 读取一行频道申请
+func scanChannelApplication(row rowScanner) (models.ChannelApplication, error) {
 	var app models.ChannelApplication
 	var lastPromptDate sql.NullString
+	var decidedAt, claimedAt sql.NullTime
 
-	err := db.conn.QueryRow(`
-		SELECT id, chat_id, channel_id, user_id, reason, applied_at, status, verified_channel, last_prompt_date
-		FROM channel_applications
-		WHERE chat_id = ? AND channel_id = ? AND user_id = ?
-	`, chatID, channelID, userID).Scan(
+	err := row.Scan(
 		&app.ID, &app.ChatID, &app.ChannelID, &app.UserID,
 		&app.Reason, &app.AppliedAt, &app.Status, &app.VerifiedChannel, &lastPromptDate,
+		&app.ReviewerID, &decidedAt, &app.DecisionNote, &app.ClaimantID, &claimedAt,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ChannelApplication{}, nil
-		}
 		return models.ChannelApplication{}, err
 	}
 
@@ -380,26 +404,222 @@ func (db *DB) GetChannelApplication(chatID, channelID, userID int64) (models.Cha
 			app.LastPromptDate = t
 		}
 	}
+	if decidedAt.Valid {
+		app.DecidedAt = decidedAt.Time
+	}
+	if claimedAt.Valid {
+		app.ClaimedAt = claimedAt.Time
+	}
 
 	return app, nil
 }
 
-// UpdateChannelApplicationStatus 更新频道申请状态
-func (db *DB) UpdateChannelApplicationStatus(chatID, channelID, userID int64, status string) error {
-	_, err := db.conn.Exec(`
-		UPDATE channel_applications
-		SET status = ?
-		WHERE chat_id = ? AND channel_id = ? AND user_id = ?
-	`, status, chatID, channelID, userID)
+// queryChannelApplications 执行查询并读取全部频道申请
+func (db *DB) queryChannelApplications(query string, args ...interface{}) ([]models.ChannelApplication, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applications []models.ChannelApplication
+	for rows.Next() {
+		app, err := scanChannelApplication(rows)
+		if err != nil {
+			return nil, err
+		}
+		applications = append(applications, app)
+	}
+
+	return applications, rows.Err()
+}
+
+// CreateChannelApplication 创建频道申请，每次申请都保存为一条新记录
+func (db *DB) CreateChannelApplication(chatID, channelID, userID int64, reason string) error {
+	// 检查是否已有待审核的申请
+	hasPending, err := db.HasPendingApplication(chatID, channelID)
+	if err != nil {
+		return err
+	}
+	if hasPending {
+		return fmt.Errorf("该频道已有待审核的申请")
+	}
+
+	// 申请被拒绝后，在群组设置的冷却时间内不接受新的申请
+	reapplyAt, err := db.GetReapplyTime(chatID, channelID)
+	if err != nil {
+		return err
+	}
+	if time.Now().Before(reapplyAt) {
+		return fmt.Errorf("该频道的申请已被拒绝，%s 之后才能再次申请", reapplyAt.Format("2006-01-02 15:04"))
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO channel_applications (chat_id, channel_id, user_id, reason, applied_at, status)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, chatID, channelID, userID, reason, time.Now(), "pending")
+	// 同时提交的申请由待处理申请的唯一索引拦截
+	if isUniqueViolation(err) {
+		return fmt.Errorf("该频道已有待审核的申请")
+	}
 	return err
 }
 
+// isUniqueViolation 判断错误是否由唯一约束冲突引起，兼容 SQLite 和 PostgreSQL
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") || strings.Contains(msg, "duplicate key value violates unique constraint")
+}
+
+// GetReapplyTime 获取频道最近一次申请被拒绝后可以重新申请的时间，群组未设置冷却时间或没有被拒绝的申请时返回零值
+func (db *DB) GetReapplyTime(chatID, channelID int64) (time.Time, error) {
+	var cooldownSeconds int64
+	err := db.conn.QueryRow(`SELECT reapply_cooldown_seconds FROM group_settings WHERE chat_id = ?`, chatID).Scan(&cooldownSeconds)
+	if err == sql.ErrNoRows || (err == nil && cooldownSeconds <= 0) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var decidedAt sql.NullTime
+	err = db.conn.QueryRow(`
+		SELECT decided_at
+		FROM channel_applications
+		WHERE chat_id = ? AND channel_id = ? AND status = 'rejected' AND decided_at IS NOT NULL
+		ORDER BY decided_at DESC
+		LIMIT 1
+	`, chatID, channelID).Scan(&decidedAt)
+	if err == sql.ErrNoRows || (err == nil && !decidedAt.Valid) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return decidedAt.Time.Add(time.Duration(cooldownSeconds) * time.Second), nil
+}
+
+// GetChannelApplication 获取用户对频道的最新一次申请
+func (db *DB) GetChannelApplication(chatID, channelID, userID int64) (models.ChannelApplication, error) {
+	app, err := scanChannelApplication(db.conn.QueryRow(`
+		SELECT `+channelApplicationColumns+`
+		FROM channel_applications
+		WHERE chat_id = ? AND channel_id = ? AND user_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`, chatID, channelID, userID))
+
+	if err == sql.ErrNoRows {
+		return models.ChannelApplication{}, nil
+	}
+	return app, err
+}
+
+// ReviewChannelApplication 审核待处理的申请，记录审核人、审核时间、备注和当前认领人
+func (db *DB) ReviewChannelApplication(applicationID int64, status string, reviewerID int64, note string) error {
+	result, err := db.conn.Exec(`
+		UPDATE channel_applications
+		SET status = ?, reviewer_id = ?, decided_at = ?, decision_note = ?, claimant_id = user_id
+		WHERE id = ? AND status = 'pending'
+	`, status, reviewerID, time.Now(), note, applicationID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("该申请不存在或已被处理")
+	}
+	return nil
+}
+
+// ExpireStaleApplications 将超过群组设置的认领或审核时限的待处理申请标记为已过期，返回过期的申请
+func (db *DB) ExpireStaleApplications(now time.Time) ([]models.ChannelApplication, error) {
+	rows, err := db.conn.Query(`
+		SELECT ` + groupSettingsColumns + `
+		FROM group_settings
+		WHERE unclaimed_timeout_seconds > 0 OR unreviewed_timeout_seconds > 0
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []models.GroupSettings
+	for rows.Next() {
+		settings, err := scanGroupSettings(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		groups = append(groups, settings)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var expired []models.ChannelApplication
+	for _, settings := range groups {
+		applications, err := db.queryChannelApplications(`
+			SELECT `+channelApplicationColumns+`
+			FROM channel_applications
+			WHERE chat_id = ? AND status = 'pending'
+			ORDER BY id ASC
+		`, settings.ChatID)
+		if err != nil {
+			return expired, err
+		}
+
+		for _, app := range applications {
+			expiresAt := models.ApplicationExpiresAt(settings, app)
+			if expiresAt.IsZero() || now.Before(expiresAt) {
+				continue
+			}
+
+			result, err := db.conn.Exec(`
+				UPDATE channel_applications
+				SET status = 'expired', decided_at = ?, claimant_id = user_id
+				WHERE id = ? AND status = 'pending'
+			`, now, app.ID)
+			if err != nil {
+				return expired, err
+			}
+			// 申请可能刚被审核，已不再是待处理状态
+			if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+				continue
+			}
+
+			app.Status = "expired"
+			app.DecidedAt = now
+			app.ClaimantID = app.UserID
+			expired = append(expired, app)
+		}
+	}
+	return expired, nil
+}
+
+// GetChannelApplicationHistory 按申请时间先后获取频道在群组中的全部申请记录
+func (db *DB) GetChannelApplicationHistory(chatID, channelID int64) ([]models.ChannelApplication, error) {
+	return db.queryChannelApplications(`
+		SELECT `+channelApplicationColumns+`
+		FROM channel_applications
+		WHERE chat_id = ? AND channel_id = ?
+		ORDER BY id ASC
+	`, chatID, channelID)
+}
+
 // VerifyChannelOwnership 验证频道所有权
 func (db *DB) VerifyChannelOwnership(chatID, channelID, userID int64) error {
 	_, err := db.conn.Exec(`
 		UPDATE channel_applications
-		SET verified_channel = 1
-		WHERE chat_id = ? AND channel_id = ? AND user_id = ?
+		SET verified_channel = TRUE
+		WHERE chat_id = ? AND channel_id = ? AND user_id = ? AND status = 'pending'
 	`, chatID, channelID, userID)
 	return err
 }
@@ -409,8 +629,8 @@ func (db *DB) UpdateLastPromptDate(chatID, channelID int64) error {
 	today := time.Now().Format("2006-01-02")
 
 	_, err := db.conn.Exec(`
-		UPDATE channel_applications 
-		SET prompted_today = 0, last_prompt_date = ? 
+		UPDATE channel_applications
+		SET prompted_today = FALSE, last_prompt_date = ?
 		WHERE chat_id = ? AND channel_id = ? AND status = 'pending'
 	`, today, chatID, channelID)
 
@@ -419,41 +639,49 @@ func (db *DB) UpdateLastPromptDate(chatID, channelID int64) error {
 
 // GetPendingApplications 获取待处理的申请
 func (db *DB) GetPendingApplications() ([]models.ChannelApplication, error) {
-	rows, err := db.conn.Query(`
-		SELECT id, chat_id, channel_id, user_id, reason, applied_at, status, verified_channel, last_prompt_date
+	return db.queryChannelApplications(`
+		SELECT ` + channelApplicationColumns + `
 		FROM channel_applications
 		WHERE status = 'pending'
 	`)
-	if err != nil {
-		return nil, err
+}
+
+// GetPendingApplicationsPage 按申请先后分页获取待处理的申请，同时返回总条数。chatID 为 0 时不限群组
+func (db *DB) GetPendingApplicationsPage(chatID int64, limit, offset int) ([]models.ChannelApplication, int, error) {
+	where := "status = 'pending'"
+	var args []interface{}
+	if chatID != 0 {
+		where += " AND chat_id = ?"
+		args = append(args, chatID)
 	}
-	defer rows.Close()
 
-	var applications []models.ChannelApplication
-	for rows.Next() {
-		var app models.ChannelApplication
-		var lastPromptDate sql.NullString
+	var total int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM channel_applications WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
 
-		err := rows.Scan(
-			&app.ID, &app.ChatID, &app.ChannelID, &app.UserID,
-			&app.Reason, &app.AppliedAt, &app.Status, &app.VerifiedChannel, &lastPromptDate,
-		)
-		if err != nil {
-			return nil, err
-		}
+	applications, err := db.queryChannelApplications(`
+		SELECT `+channelApplicationColumns+`
+		FROM channel_applications
+		WHERE `+where+`
+		ORDER BY id ASC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	return applications, total, err
+}
 
-		// 处理可能为NULL的last_prompt_date
-		if lastPromptDate.Valid {
-			t, err := time.Parse("2006-01-02", lastPromptDate.String)
-			if err == nil {
-				app.LastPromptDate = t
-			}
-		}
+// GetChannelApplicationByID 根据申请ID获取申请，不存在时返回零值
+func (db *DB) GetChannelApplicationByID(applicationID int64) (models.ChannelApplication, error) {
+	app, err := scanChannelApplication(db.conn.QueryRow(`
+		SELECT `+channelApplicationColumns+`
+		FROM channel_applications
+		WHERE id = ?
+	`, applicationID))
 
-		applications = append(applications, app)
+	if err == sql.ErrNoRows {
+		return models.ChannelApplication{}, nil
 	}
-
-	return applications, nil
+	return app, err
 }
 
 // GetChannelApplicationByDate 根据日期获取频道今日是否已提示过申请
@@ -472,34 +700,25 @@ func (db *DB) GetChannelApplicationByDate(chatID, channelID int64, date string)
 	return count > 0, nil
 }
 
-// UpdateChannelApplicationUser 更新频道申请的用户ID
+// UpdateChannelApplicationUser 更新频道申请的认领人。已通过所有权验证的认领不能被取代；
+// 尚未通过验证的认领可以由其他用户重新认领，同时清除原认领人的所有权验证记录
 func (db *DB) UpdateChannelApplicationUser(chatID, channelID, userID int64) error {
-	// 首先检查是否已经存在处于pending状态且已被认领的记录
-	var count int
-	err := db.conn.QueryRow(`
-		SELECT COUNT(*)
-		FROM channel_applications
-		WHERE chat_id = ? AND channel_id = ? AND user_id != 0 AND user_id != ? AND status = 'pending'
-	`, chatID, channelID, userID).Scan(&count)
-
+	tx, err := db.conn.Begin()
 	if err != nil {
 		return err
 	}
-
-	// 如果已经存在被其他人认领过且处于pending状态的记录，则不允许再次认领
-	if count > 0 {
-		return fmt.Errorf("该频道申请已被认领")
-	}
+	defer tx.Rollback()
 
 	// 查找最新的申请记录
-	var applicationID int64
-	err = db.conn.QueryRow(`
-		SELECT id
+	var applicationID, claimantID int64
+	var verified bool
+	err = tx.QueryRow(`
+		SELECT id, user_id, verified_channel
 		FROM channel_applications
 		WHERE chat_id = ? AND channel_id = ? AND status = 'pending'
 		ORDER BY applied_at DESC
 		LIMIT 1
-	`, chatID, channelID).Scan(&applicationID)
+	`, chatID, channelID).Scan(&applicationID, &claimantID, &verified)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -508,14 +727,34 @@ func (db *DB) UpdateChannelApplicationUser(chatID, channelID, userID int64) erro
 		return err
 	}
 
-	// 更新申请的用户ID
-	_, err = db.conn.Exec(`
+	if claimantID != 0 && claimantID != userID {
+		// 如果已经被其他人认领并通过了所有权验证，则不允许再次认领
+		if verified {
+			return fmt.Errorf("该频道申请已被认领")
+		}
+		if _, err := tx.Exec(`DELETE FROM ownership_checks WHERE application_id = ?`, applicationID); err != nil {
+			return err
+		}
+	}
+
+	// 更新申请的用户ID，首次认领时记录认领时间。只在读取后认领人和验证状态没有变化时更新
+	result, err := tx.Exec(`
 		UPDATE channel_applications
-		SET user_id = ?
-		WHERE id = ?
-	`, userID, applicationID)
+		SET user_id = ?, claimed_at = COALESCE(claimed_at, ?)
+		WHERE id = ? AND status = 'pending' AND user_id = ? AND verified_channel = ?
+	`, userID, time.Now(), applicationID, claimantID, verified)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("该频道申请已被认领")
+	}
 
-	return err
+	return tx.Commit()
 }
 
 // SetUserState 设置用户状态
@@ -552,6 +791,15 @@ func (db *DB) ClearUserState(userID int64) error {
 	return err
 }
 
+// ClearUserStatesByValue 清除所有处于指定状态的用户状态
+func (db *DB) ClearUserStatesByValue(state string) error {
+	_, err := db.conn.Exec(`
+		DELETE FROM user_states
+		WHERE state = ?
+	`, state)
+	return err
+}
+
 // UpdateChannelApplicationReason 更新频道申请的理由
 func (db *DB) UpdateChannelApplicationReason(chatID, channelID int64, reason string) error {
 	_, err := db.conn.Exec(`
@@ -564,42 +812,26 @@ func (db *DB) UpdateChannelApplicationReason(chatID, channelID int64, reason str
 
 // GetPendingChannelApplication 获取指定频道的待处理申请
 func (db *DB) GetPendingChannelApplication(chatID, channelID int64) (models.ChannelApplication, error) {
-	var app models.ChannelApplication
-	var lastPromptDate sql.NullString
-
-	err := db.conn.QueryRow(`
-		SELECT id, chat_id, channel_id, user_id, reason, applied_at, status, verified_channel, last_prompt_date
+	app, err := scanChannelApplication(db.conn.QueryRow(`
+		SELECT `+channelApplicationColumns+`
 		FROM channel_applications
 		WHERE chat_id = ? AND channel_id = ? AND status = 'pending'
 		ORDER BY applied_at DESC
 		LIMIT 1
-	`, chatID, channelID).Scan(
-		&app.ID, &app.ChatID, &app.ChannelID, &app.UserID,
-		&app.Reason, &app.AppliedAt, &app.Status, &app.VerifiedChannel, &lastPromptDate,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ChannelApplication{}, nil
-		}
-		return models.ChannelApplication{}, err
-	}
+	`, chatID, channelID))
 
-	// 处理可能为NULL的last_prompt_date
-	if lastPromptDate.Valid {
-		t, err := time.Parse("2006-01-02", lastPromptDate.String)
-		if err == nil {
-			app.LastPromptDate = t
-		}
+	if err == sql.ErrNoRows {
+		return models.ChannelApplication{}, nil
 	}
-
-	return app, nil
+	return app, err
 }
 
 // 定义提示类型常量
 const (
 	PromptTypeWhitelistWarning = "whitelist_warning" // 非白名单提示（需要申请）
 	PromptTypePendingNotice    = "pending_notice"    // 待审核提示
+	PromptTypeQuotaNotice      = "quota_notice"      // 超出发言配额提示
+	PromptTypeContentNotice    = "content_notice"    // 违反内容限制提示
 )
 
 // HasChannelDailyPrompt 检查指定频道在当天是否已经有过特定类型的提示
@@ -625,9 +857,10 @@ func (db *DB) RecordChannelDailyPrompt(chatID, channelID int64, promptType strin
 	today := time.Now().Format("2006-01-02")
 
 	_, err := db.conn.Exec(`
-		INSERT OR IGNORE INTO channel_daily_prompts 
-		(chat_id, channel_id, prompt_type, prompt_date) 
+		INSERT INTO channel_daily_prompts
+		(chat_id, channel_id, prompt_type, prompt_date)
 		VALUES (?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
 	`, chatID, channelID, promptType, today)
 
 	return err
@@ -666,8 +899,8 @@ func (db *DB) ResetDailyPrompts() error {
 	// 同时重置application表中的提示状态（向后兼容）
 	if err == nil {
 		_, err = db.conn.Exec(`
-			UPDATE channel_applications 
-			SET prompted_today = 0 
+			UPDATE channel_applications
+			SET prompted_today = FALSE
 			WHERE status = 'pending'
 		`)
 	}
@@ -690,28 +923,175 @@ func (db *DB) HasPendingApplication(chatID, channelID int64) (bool, error) {
 	return count > 0, nil
 }
 
-// BeginTx 开始一个数据库事务
-func (db *DB) BeginTx() (*sql.Tx, error) {
-	return db.conn.Begin()
-}
+// LogBlockedMessagesBatch 在一个事务中批量记录被阻止的消息
+func (db *DB) LogBlockedMessagesBatch(messages []models.BlockedMessageInfo) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-// LogBlockedMessagesBatch 批量记录被阻止的消息
-func (db *DB) LogBlockedMessagesBatch(tx *sql.Tx, messages []models.BlockedMessageInfo) bool {
 	stmt, err := tx.Prepare(`
-		INSERT INTO blocked_messages (chat_id, channel_id, message_id, blocked_at, message_text)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO blocked_messages (chat_id, channel_id, message_id, blocked_at, message_text, reason, observed)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
-		return false
+		return err
 	}
 	defer stmt.Close()
 
 	for _, msg := range messages {
-		_, err := stmt.Exec(msg.ChatID, msg.ChannelID, msg.MessageID, time.Now(), msg.MessageText)
+		_, err := stmt.Exec(msg.ChatID, msg.ChannelID, msg.MessageID, time.Now(), msg.MessageText, msg.Reason, msg.Observed)
 		if err != nil {
-			return false
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CountBlockedMessagesSince 统计频道在群组中自 since 以来因不在白名单或在全局黑名单中而被阻止的消息数量，不包括超出配额等其他原因和观察模式下的记录
+func (db *DB) CountBlockedMessagesSince(chatID, channelID int64, since time.Time) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM blocked_messages
+		WHERE chat_id = ? AND channel_id = ? AND reason IN (?, ?) AND observed = ? AND blocked_at >= ?
+	`, chatID, channelID, models.BlockReasonNotWhitelisted, models.BlockReasonBlacklisted, false, since).Scan(&count)
+	return count, err
+}
+
+// CountObservedMessagesSince 统计频道在群组中自 since 以来在观察模式下因不在白名单或在全局黑名单中本应被删除的消息数量
+func (db *DB) CountObservedMessagesSince(chatID, channelID int64, since time.Time) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM blocked_messages
+		WHERE chat_id = ? AND channel_id = ? AND reason IN (?, ?) AND observed = ? AND blocked_at >= ?
+	`, chatID, channelID, models.BlockReasonNotWhitelisted, models.BlockReasonBlacklisted, true, since).Scan(&count)
+	return count, err
+}
+
+// GetObservedMessageStats 按频道和原因统计群组自 since 以来在观察模式下本应被删除的消息数量，按数量从多到少排序
+func (db *DB) GetObservedMessageStats(chatID int64, since time.Time) ([]models.ObservedMessageStat, error) {
+	rows, err := db.conn.Query(`
+		SELECT channel_id, reason, COUNT(*) AS n FROM blocked_messages
+		WHERE chat_id = ? AND observed = ? AND blocked_at >= ?
+		GROUP BY channel_id, reason
+		ORDER BY n DESC, channel_id
+	`, chatID, true, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.ObservedMessageStat
+	for rows.Next() {
+		var stat models.ObservedMessageStat
+		if err := rows.Scan(&stat.ChannelID, &stat.Reason, &stat.Count); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
+// PruneBlockedMessages 按各群组的保留期限分批删除过期的被阻止消息，返回删除的总行数
+// 群组未单独设置保留期限时使用 defaultRetentionDays，保留期限小于 0 表示永久保留
+func (db *DB) PruneBlockedMessages(defaultRetentionDays, batchSize int) (int64, error) {
+	rows, err := db.conn.Query(`
+		SELECT b.chat_id, COALESCE(g.retention_days, 0)
+		FROM (SELECT DISTINCT chat_id FROM blocked_messages) b
+		LEFT JOIN group_settings g ON g.chat_id = b.chat_id
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	retentions := make(map[int64]int)
+	for rows.Next() {
+		var chatID int64
+		var days int
+		if err := rows.Scan(&chatID, &days); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		retentions[chatID] = days
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for chatID, days := range retentions {
+		days = models.EffectiveRetentionDays(days, defaultRetentionDays)
+		if days < 0 {
+			continue
 		}
+		cutoff := time.Now().AddDate(0, 0, -days)
+
+		for {
+			result, err := db.conn.Exec(`
+				DELETE FROM blocked_messages
+				WHERE id IN (
+					SELECT id FROM blocked_messages
+					WHERE chat_id = ? AND blocked_at < ?
+					LIMIT ?
+				)
+			`, chatID, cutoff, batchSize)
+			if err != nil {
+				return total, err
+			}
+
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return total, err
+			}
+			total += affected
+
+			if affected < int64(batchSize) {
+				break
+			}
+
+			// 批次之间让出数据库连接，避免阻塞消息处理
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	return total, nil
+}
+
+// Vacuum 以增量方式回收已删除数据占用的磁盘空间，仅对 SQLite 生效。
+// 启用增量回收之前创建的数据库不会回收空间，需要先离线执行一次 EnableIncrementalVacuum
+func (db *DB) Vacuum() error {
+	if db.conn.dialect != dialectSQLite {
+		// PostgreSQL 由 autovacuum 负责回收空间
+		return nil
+	}
+
+	_, err := db.conn.Exec(`PRAGMA incremental_vacuum`)
+	return err
+}
+
+// EnableIncrementalVacuum 将旧数据库切换为增量回收模式，已经是增量模式时不做任何操作，返回是否执行了切换。
+// 切换需要执行一次完整的 VACUUM：期间独占数据库，并需要约为数据库文件两倍的可用磁盘空间，只应在机器人停止时执行
+func (db *DB) EnableIncrementalVacuum() (bool, error) {
+	if db.conn.dialect != dialectSQLite {
+		return false, nil
+	}
+
+	var mode int
+	if err := db.conn.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+		return false, err
+	}
+	if mode == 2 {
+		return false, nil
 	}
 
-	return true
+	if _, err := db.conn.Exec(`PRAGMA auto_vacuum=INCREMENTAL`); err != nil {
+		return false, err
+	}
+	if _, err := db.conn.Exec(`VACUUM`); err != nil {
+		return false, err
+	}
+	return true, nil
 }
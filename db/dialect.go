@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// dialect 表示 SQL 方言，用于处理不同数据库之间的语法差异
+type dialect string
+
+const (
+	dialectSQLite   dialect = DriverSQLite
+	dialectPostgres dialect = DriverPostgres
+)
+
+// rebind 将查询中的 ? 占位符转换为当前方言的占位符
+func (d dialect) rebind(query string) string {
+	if d != dialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+
+	n := 0
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+			b.WriteRune(r)
+		case r == '?' && !inQuote:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// autoIncrementPK 返回自增主键列的定义
+func (d dialect) autoIncrementPK() string {
+	if d == dialectPostgres {
+		return "BIGSERIAL PRIMARY KEY"
+	}
+	return "INTEGER PRIMARY KEY AUTOINCREMENT"
+}
+
+// conn 包装 *sql.DB，在执行查询前按方言转换占位符
+type conn struct {
+	*sql.DB
+	dialect dialect
+}
+
+// Exec 执行语句
+func (c *conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.DB.Exec(c.dialect.rebind(query), args...)
+}
+
+// Query 执行查询并返回多行结果
+func (c *conn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.DB.Query(c.dialect.rebind(query), args...)
+}
+
+// QueryRow 执行查询并返回单行结果
+func (c *conn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.DB.QueryRow(c.dialect.rebind(query), args...)
+}
+
+// Begin 开始一个按方言转换占位符的事务
+func (c *conn) Begin() (*tx, error) {
+	t, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &tx{Tx: t, dialect: c.dialect}, nil
+}
+
+// tx 包装 *sql.Tx，在执行语句前按方言转换占位符
+type tx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+// Exec 在事务中执行语句
+func (t *tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.rebind(query), args...)
+}
+
+// Query 在事务中执行查询并返回多行结果
+func (t *tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.rebind(query), args...)
+}
+
+// QueryRow 在事务中执行查询并返回单行结果
+func (t *tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.rebind(query), args...)
+}
+
+// Prepare 在事务中预编译语句
+func (t *tx) Prepare(query string) (*sql.Stmt, error) {
+	return t.Tx.Prepare(t.dialect.rebind(query))
+}
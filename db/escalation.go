@@ -0,0 +1,59 @@
+package db
+
+import (
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// GetEscalationSteps 获取群组的处罚升级规则，按触发次数升序
+func (db *DB) GetEscalationSteps(chatID int64) ([]models.EscalationStep, error) {
+	rows, err := db.conn.Query(`
+		SELECT chat_id, threshold, action, duration_seconds
+		FROM escalation_steps
+		WHERE chat_id = ?
+		ORDER BY threshold
+	`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []models.EscalationStep
+	for rows.Next() {
+		var step models.EscalationStep
+		var seconds int64
+		if err := rows.Scan(&step.ChatID, &step.Threshold, &step.Action, &seconds); err != nil {
+			return nil, err
+		}
+		step.Duration = time.Duration(seconds) * time.Second
+		steps = append(steps, step)
+	}
+
+	return steps, rows.Err()
+}
+
+// SetEscalationSteps 用 steps 替换群组的全部处罚升级规则，steps 为空时清除规则
+func (db *DB) SetEscalationSteps(chatID int64, steps []models.EscalationStep) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM escalation_steps WHERE chat_id = ?`, chatID); err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		_, err := tx.Exec(`
+			INSERT INTO escalation_steps (chat_id, threshold, action, duration_seconds)
+			VALUES (?, ?, ?, ?)
+		`, chatID, step.Threshold, step.Action, int64(step.Duration/time.Second))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
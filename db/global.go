@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// SetGlobalListEntry 将频道加入全局白名单或黑名单，频道已在另一个名单中时会被移过来
+func (db *DB) SetGlobalListEntry(listType string, channelID, addedBy int64, description string) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO global_channel_lists (channel_id, list_type, added_by, added_at, description)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(channel_id) DO UPDATE SET
+		list_type = excluded.list_type, added_by = excluded.added_by,
+		added_at = excluded.added_at, description = excluded.description
+	`, channelID, listType, addedBy, time.Now(), description)
+	return err
+}
+
+// RemoveGlobalListEntry 将频道从指定的全局名单中移除
+func (db *DB) RemoveGlobalListEntry(listType string, channelID int64) error {
+	_, err := db.conn.Exec(`
+		DELETE FROM global_channel_lists
+		WHERE channel_id = ? AND list_type = ?
+	`, channelID, listType)
+	return err
+}
+
+// GetGlobalListType 获取频道所在的全局名单类型，不在任何名单中时返回空字符串
+func (db *DB) GetGlobalListType(channelID int64) (string, error) {
+	var listType string
+	err := db.conn.QueryRow(`
+		SELECT list_type FROM global_channel_lists
+		WHERE channel_id = ?
+	`, channelID).Scan(&listType)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return listType, err
+}
+
+// GetGlobalListEntries 获取指定全局名单中的全部频道，按添加时间倒序
+func (db *DB) GetGlobalListEntries(listType string) ([]models.GlobalListEntry, error) {
+	rows, err := db.conn.Query(`
+		SELECT channel_id, list_type, added_by, added_at, description
+		FROM global_channel_lists
+		WHERE list_type = ?
+		ORDER BY added_at DESC
+	`, listType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.GlobalListEntry
+	for rows.Next() {
+		var entry models.GlobalListEntry
+		err := rows.Scan(&entry.ChannelID, &entry.ListType, &entry.AddedBy, &entry.AddedAt, &entry.Description)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
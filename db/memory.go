@@ -0,0 +1,1404 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// MemoryStorePath 配置中使用此数据库路径时启用纯内存存储
+const MemoryStorePath = ":memory-store:"
+
+// whitelistKey 白名单条目的唯一键
+type whitelistKey struct {
+	ChatID    int64
+	ChannelID int64
+}
+
+// dailyPromptKey 每日提示记录的唯一键
+type dailyPromptKey struct {
+	ChatID     int64
+	ChannelID  int64
+	PromptType string
+	PromptDate string
+}
+
+// MemoryStore 纯内存的 Store 实现，用于测试和无需持久化的临时运行
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	nextID          map[string]int64
+	whitelist       map[whitelistKey]models.WhitelistedChannel
+	blockedMessages []models.BlockedMessage
+	groupSettings   map[int64]models.GroupSettings
+	applications    []*models.ChannelApplication
+	userStates      map[int64]string
+	dailyPrompts    map[dailyPromptKey]struct{}
+	auditLog        []models.AuditEntry
+	globalLists     map[int64]models.GlobalListEntry
+	senderChatBans  map[whitelistKey]models.SenderChatBan
+	senderUnbans    map[whitelistKey]time.Time
+	escalation      map[int64][]models.EscalationStep
+	whitelistSets   map[int64]models.WhitelistSet
+	setChannels     map[setChannelKey]models.WhitelistSetChannel
+	subscriptions   map[subscriptionKey]struct{}
+	channelPosts    map[whitelistKey][]time.Time
+	votes           []models.ApplicationVote
+	notices         []models.ApplicationNotice
+	ownershipChecks []models.OwnershipCheck
+}
+
+// setChannelKey 共享白名单频道的索引键
+type setChannelKey struct {
+	SetID     int64
+	ChannelID int64
+}
+
+// subscriptionKey 群组订阅共享白名单的索引键
+type subscriptionKey struct {
+	ChatID int64
+	SetID  int64
+}
+
+// 确保 MemoryStore 实现了 Store 接口
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore 创建一个空的内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		nextID:         make(map[string]int64),
+		whitelist:      make(map[whitelistKey]models.WhitelistedChannel),
+		groupSettings:  make(map[int64]models.GroupSettings),
+		globalLists:    make(map[int64]models.GlobalListEntry),
+		senderChatBans: make(map[whitelistKey]models.SenderChatBan),
+		senderUnbans:   make(map[whitelistKey]time.Time),
+		escalation:     make(map[int64][]models.EscalationStep),
+		whitelistSets:  make(map[int64]models.WhitelistSet),
+		setChannels:    make(map[setChannelKey]models.WhitelistSetChannel),
+		subscriptions:  make(map[subscriptionKey]struct{}),
+		channelPosts:   make(map[whitelistKey][]time.Time),
+		userStates:     make(map[int64]string),
+		dailyPrompts:   make(map[dailyPromptKey]struct{}),
+	}
+}
+
+// Backup 内存存储没有可备份的数据库文件
+func (m *MemoryStore) Backup(path string) error {
+	return fmt.Errorf("内存存储不支持备份")
+}
+
+// Close 内存存储无需释放资源
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// newID 按表生成自增ID，调用方需持有写锁
+func (m *MemoryStore) newID(table string) int64 {
+	m.nextID[table]++
+	return m.nextID[table]
+}
+
+// AddChannelToWhitelist 将频道添加到白名单，username 为频道的公开用户名（不含 @，可以为空），
+// expiresAt 为零值时永久有效。已存在的条目（包括已过期但尚未清理的条目）会被覆盖
+func (m *MemoryStore) AddChannelToWhitelist(chatID, channelID, addedBy int64, username, description string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := whitelistKey{ChatID: chatID, ChannelID: channelID}
+	channel, exists := m.whitelist[key]
+	if !exists {
+		channel = models.WhitelistedChannel{
+			ID:        m.newID("whitelisted_channels"),
+			ChatID:    chatID,
+			ChannelID: channelID,
+		}
+	}
+	channel.AddedBy = addedBy
+	channel.AddedAt = time.Now()
+	channel.Username = username
+	channel.Description = description
+	channel.ExpiresAt = expiresAt
+	m.whitelist[key] = channel
+	return nil
+}
+
+// RemoveChannelFromWhitelist 从白名单中移除频道
+func (m *MemoryStore) RemoveChannelFromWhitelist(chatID, channelID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.whitelist, whitelistKey{ChatID: chatID, ChannelID: channelID})
+	return nil
+}
+
+// IsChannelWhitelisted 检查频道是否在群组的白名单或群组订阅的共享白名单中，已过期的条目视为不在白名单中
+func (m *MemoryStore) IsChannelWhitelisted(chatID, channelID int64) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	channel, exists := m.whitelist[whitelistKey{ChatID: chatID, ChannelID: channelID}]
+	if exists && (!channel.IsTemporary() || channel.ExpiresAt.After(time.Now())) {
+		return true, nil
+	}
+	return len(m.subscribedSetsContainingLocked(chatID, channelID)) > 0, nil
+}
+
+// RemoveExpiredWhitelistEntries 删除在 now 之前过期的白名单条目，返回被删除的条目
+func (m *MemoryStore) RemoveExpiredWhitelistEntries(now time.Time) ([]models.WhitelistedChannel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []models.WhitelistedChannel
+	for key, channel := range m.whitelist {
+		if channel.IsTemporary() && !channel.ExpiresAt.After(now) {
+			expired = append(expired, channel)
+			delete(m.whitelist, key)
+		}
+	}
+	return expired, nil
+}
+
+// GetWhitelistedChannels 获取群组的白名单频道列表，按添加时间倒序
+func (m *MemoryStore) GetWhitelistedChannels(chatID int64) ([]models.WhitelistedChannel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var channels []models.WhitelistedChannel
+	for key, channel := range m.whitelist {
+		if key.ChatID == chatID {
+			channels = append(channels, channel)
+		}
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		if channels[i].AddedAt.Equal(channels[j].AddedAt) {
+			return channels[i].ID > channels[j].ID
+		}
+		return channels[i].AddedAt.After(channels[j].AddedAt)
+	})
+	return channels, nil
+}
+
+// GetWhitelistEntry 获取群组白名单中的频道条目，不存在时返回 ID 为 0 的条目
+func (m *MemoryStore) GetWhitelistEntry(chatID, channelID int64) (models.WhitelistedChannel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.whitelist[whitelistKey{ChatID: chatID, ChannelID: channelID}], nil
+}
+
+// SetChannelQuota 设置白名单频道的发言配额，posts 为 0 时取消配额
+func (m *MemoryStore) SetChannelQuota(chatID, channelID int64, posts int, window time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := whitelistKey{ChatID: chatID, ChannelID: channelID}
+	channel, exists := m.whitelist[key]
+	if !exists {
+		return nil
+	}
+	channel.QuotaPosts = posts
+	channel.QuotaWindow = window
+	m.whitelist[key] = channel
+	return nil
+}
+
+// SetChannelContentPolicy 设置白名单频道的内容限制，policy 为空时取消限制
+func (m *MemoryStore) SetChannelContentPolicy(chatID, channelID int64, policy models.ContentPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := whitelistKey{ChatID: chatID, ChannelID: channelID}
+	channel, exists := m.whitelist[key]
+	if !exists {
+		return nil
+	}
+	channel.ContentPolicy = policy
+	m.whitelist[key] = channel
+	return nil
+}
+
+// RecordChannelPost 记录设置了发言配额的频道发送的一条消息
+func (m *MemoryStore) RecordChannelPost(chatID, channelID int64, postedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := whitelistKey{ChatID: chatID, ChannelID: channelID}
+	m.channelPosts[key] = append(m.channelPosts[key], postedAt)
+	return nil
+}
+
+// CountChannelPostsSince 统计频道在群组中自 since 以来发送的消息数量
+func (m *MemoryStore) CountChannelPostsSince(chatID, channelID int64, since time.Time) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, postedAt := range m.channelPosts[whitelistKey{ChatID: chatID, ChannelID: channelID}] {
+		if !postedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// PruneChannelPosts 删除 before 之前的发言记录，返回删除的条数
+func (m *MemoryStore) PruneChannelPosts(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed int64
+	for key, posts := range m.channelPosts {
+		kept := posts[:0]
+		for _, postedAt := range posts {
+			if postedAt.Before(before) {
+				removed++
+				continue
+			}
+			kept = append(kept, postedAt)
+		}
+		if len(kept) == 0 {
+			delete(m.channelPosts, key)
+		} else {
+			m.channelPosts[key] = kept
+		}
+	}
+	return removed, nil
+}
+
+// LogBlockedMessage 记录被阻止的消息，reason 为阻止原因，observed 表示观察模式下未实际删除
+func (m *MemoryStore) LogBlockedMessage(chatID, channelID int64, messageID int, messageText, reason string, observed bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.blockedMessages = append(m.blockedMessages, models.BlockedMessage{
+		ID:          m.newID("blocked_messages"),
+		ChatID:      chatID,
+		ChannelID:   channelID,
+		MessageID:   messageID,
+		BlockedAt:   time.Now(),
+		MessageText: messageText,
+		Reason:      reason,
+		Observed:    observed,
+	})
+	return nil
+}
+
+// LogBlockedMessagesBatch 批量记录被阻止的消息
+func (m *MemoryStore) LogBlockedMessagesBatch(messages []models.BlockedMessageInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, msg := range messages {
+		m.blockedMessages = append(m.blockedMessages, models.BlockedMessage{
+			ID:          m.newID("blocked_messages"),
+			ChatID:      msg.ChatID,
+			ChannelID:   msg.ChannelID,
+			MessageID:   msg.MessageID,
+			BlockedAt:   now,
+			MessageText: msg.MessageText,
+			Reason:      msg.Reason,
+			Observed:    msg.Observed,
+		})
+	}
+	return nil
+}
+
+// GetBlockedMessagesStats 获取被阻止消息的统计信息，不包括观察模式下未实际删除的消息
+func (m *MemoryStore) GetBlockedMessagesStats(chatID int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, msg := range m.blockedMessages {
+		if msg.ChatID == chatID && !msg.Observed {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountBlockedMessagesSince 统计频道在群组中自 since 以来因不在白名单或在全局黑名单中而被阻止的消息数量，不包括超出配额等其他原因和观察模式下的记录
+func (m *MemoryStore) CountBlockedMessagesSince(chatID, channelID int64, since time.Time) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, msg := range m.blockedMessages {
+		if msg.ChatID != chatID || msg.ChannelID != channelID || msg.Observed || msg.BlockedAt.Before(since) {
+			continue
+		}
+		if msg.Reason == models.BlockReasonNotWhitelisted || msg.Reason == models.BlockReasonBlacklisted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountObservedMessagesSince 统计频道在群组中自 since 以来在观察模式下因不在白名单或在全局黑名单中本应被删除的消息数量
+func (m *MemoryStore) CountObservedMessagesSince(chatID, channelID int64, since time.Time) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, msg := range m.blockedMessages {
+		if msg.ChatID != chatID || msg.ChannelID != channelID || !msg.Observed || msg.BlockedAt.Before(since) {
+			continue
+		}
+		if msg.Reason == models.BlockReasonNotWhitelisted || msg.Reason == models.BlockReasonBlacklisted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetObservedMessageStats 按频道和原因统计群组自 since 以来在观察模式下本应被删除的消息数量，按数量从多到少排序
+func (m *MemoryStore) GetObservedMessageStats(chatID int64, since time.Time) ([]models.ObservedMessageStat, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type statKey struct {
+		channelID int64
+		reason    string
+	}
+	counts := make(map[statKey]int)
+	for _, msg := range m.blockedMessages {
+		if msg.ChatID == chatID && msg.Observed && !msg.BlockedAt.Before(since) {
+			counts[statKey{msg.ChannelID, msg.Reason}]++
+		}
+	}
+
+	var stats []models.ObservedMessageStat
+	for key, count := range counts {
+		stats = append(stats, models.ObservedMessageStat{ChannelID: key.channelID, Reason: key.reason, Count: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		if stats[i].ChannelID != stats[j].ChannelID {
+			return stats[i].ChannelID < stats[j].ChannelID
+		}
+		return stats[i].Reason < stats[j].Reason
+	})
+	return stats, nil
+}
+
+// PruneBlockedMessages 按各群组的保留期限删除过期的被阻止消息，返回删除的总条数
+func (m *MemoryStore) PruneBlockedMessages(defaultRetentionDays, batchSize int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	kept := m.blockedMessages[:0]
+	var removed int64
+	for _, msg := range m.blockedMessages {
+		days := models.EffectiveRetentionDays(m.groupSettings[msg.ChatID].RetentionDays, defaultRetentionDays)
+		if days >= 0 && msg.BlockedAt.Before(now.AddDate(0, 0, -days)) {
+			removed++
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	m.blockedMessages = kept
+	return removed, nil
+}
+
+// Vacuum 内存存储无需回收空间
+func (m *MemoryStore) Vacuum() error {
+	return nil
+}
+
+// GetOrCreateGroupSettings 获取或创建群组设置
+func (m *MemoryStore) GetOrCreateGroupSettings(chatID int64) (models.GroupSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if settings, exists := m.groupSettings[chatID]; exists {
+		return settings, nil
+	}
+
+	settings := models.GroupSettings{
+		ChatID:         chatID,
+		AdminOnly:      true,
+		LogChannelID:   0,
+		Enabled:        true,
+		UseGlobalLists: true,
+
+		EscalationWindowHours: 24,
+		LinkedChannelAuto:     true,
+		ReviewQuorum:          1,
+	}
+	m.groupSettings[chatID] = settings
+	return settings, nil
+}
+
+// UpdateGroupSettings 更新群组设置，与 SQL 实现一致，不存在的群组不会被创建
+func (m *MemoryStore) UpdateGroupSettings(settings models.GroupSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.groupSettings[settings.ChatID]; exists {
+		m.groupSettings[settings.ChatID] = settings
+	}
+	return nil
+}
+
+// UpdateLinkedChannelID 只更新群组记录的关联频道，不覆盖其他可能已被管理员修改的设置
+func (m *MemoryStore) UpdateLinkedChannelID(chatID, linkedChannelID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if settings, exists := m.groupSettings[chatID]; exists {
+		settings.LinkedChannelID = linkedChannelID
+		m.groupSettings[chatID] = settings
+	}
+	return nil
+}
+
+// GetAllGroupSettings 获取所有群组的设置，按群组ID排序
+func (m *MemoryStore) GetAllGroupSettings() ([]models.GroupSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var all []models.GroupSettings
+	for _, settings := range m.groupSettings {
+		all = append(all, settings)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ChatID < all[j].ChatID
+	})
+	return all, nil
+}
+
+// latestApplication 获取群组内某频道最新的申请，status 为空时不限状态，调用方需持有锁
+func (m *MemoryStore) latestApplication(chatID, channelID int64, status string) *models.ChannelApplication {
+	var latest *models.ChannelApplication
+	for _, app := range m.applications {
+		if app.ChatID != chatID || app.ChannelID != channelID {
+			continue
+		}
+		if status != "" && app.Status != status {
+			continue
+		}
+		if latest == nil || app.AppliedAt.After(latest.AppliedAt) ||
+			(app.AppliedAt.Equal(latest.AppliedAt) && app.ID > latest.ID) {
+			latest = app
+		}
+	}
+	return latest
+}
+
+// CreateChannelApplication 创建频道申请，每次申请都保存为一条新记录
+func (m *MemoryStore) CreateChannelApplication(chatID, channelID, userID int64, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 如果已存在申请且状态为pending，则返回错误
+	if m.latestApplication(chatID, channelID, "pending") != nil {
+		return fmt.Errorf("该频道已有待审核的申请")
+	}
+
+	// 申请被拒绝后，在群组设置的冷却时间内不接受新的申请
+	if reapplyAt := m.reapplyTime(chatID, channelID); time.Now().Before(reapplyAt) {
+		return fmt.Errorf("该频道的申请已被拒绝，%s 之后才能再次申请", reapplyAt.Format("2006-01-02 15:04"))
+	}
+
+	m.applications = append(m.applications, &models.ChannelApplication{
+		ID:        m.newID("channel_applications"),
+		ChatID:    chatID,
+		ChannelID: channelID,
+		UserID:    userID,
+		Reason:    reason,
+		AppliedAt: time.Now(),
+		Status:    "pending",
+	})
+	return nil
+}
+
+// GetReapplyTime 获取频道最近一次申请被拒绝后可以重新申请的时间，群组未设置冷却时间或没有被拒绝的申请时返回零值
+func (m *MemoryStore) GetReapplyTime(chatID, channelID int64) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.reapplyTime(chatID, channelID), nil
+}
+
+// reapplyTime 计算频道可以重新申请的时间，调用方需持有锁
+func (m *MemoryStore) reapplyTime(chatID, channelID int64) time.Time {
+	settings, exists := m.groupSettings[chatID]
+	if !exists || settings.ReapplyCooldown <= 0 {
+		return time.Time{}
+	}
+
+	var lastRejected time.Time
+	for _, app := range m.applications {
+		if app.ChatID == chatID && app.ChannelID == channelID && app.Status == "rejected" && app.DecidedAt.After(lastRejected) {
+			lastRejected = app.DecidedAt
+		}
+	}
+	if lastRejected.IsZero() {
+		return time.Time{}
+	}
+	return lastRejected.Add(settings.ReapplyCooldown)
+}
+
+// GetChannelApplication 获取用户对频道的最新一次申请
+func (m *MemoryStore) GetChannelApplication(chatID, channelID, userID int64) (models.ChannelApplication, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := len(m.applications) - 1; i >= 0; i-- {
+		app := m.applications[i]
+		if app.ChatID == chatID && app.ChannelID == channelID && app.UserID == userID {
+			return *app, nil
+		}
+	}
+	return models.ChannelApplication{}, nil
+}
+
+// ReviewChannelApplication 审核待处理的申请，记录审核人、审核时间、备注和当前认领人
+func (m *MemoryStore) ReviewChannelApplication(applicationID int64, status string, reviewerID int64, note string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, app := range m.applications {
+		if app.ID == applicationID && app.Status == "pending" {
+			app.Status = status
+			app.ReviewerID = reviewerID
+			app.DecidedAt = time.Now()
+			app.DecisionNote = note
+			app.ClaimantID = app.UserID
+			return nil
+		}
+	}
+	return fmt.Errorf("该申请不存在或已被处理")
+}
+
+// ExpireStaleApplications 将超过群组设置的认领或审核时限的待处理申请标记为已过期，返回过期的申请
+func (m *MemoryStore) ExpireStaleApplications(now time.Time) ([]models.ChannelApplication, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []models.ChannelApplication
+	for _, app := range m.applications {
+		if app.Status != "pending" {
+			continue
+		}
+		settings, exists := m.groupSettings[app.ChatID]
+		if !exists {
+			continue
+		}
+		expiresAt := models.ApplicationExpiresAt(settings, *app)
+		if expiresAt.IsZero() || now.Before(expiresAt) {
+			continue
+		}
+
+		app.Status = "expired"
+		app.DecidedAt = now
+		app.ClaimantID = app.UserID
+		expired = append(expired, *app)
+	}
+	return expired, nil
+}
+
+// GetChannelApplicationHistory 按申请时间先后获取频道在群组中的全部申请记录
+func (m *MemoryStore) GetChannelApplicationHistory(chatID, channelID int64) ([]models.ChannelApplication, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var applications []models.ChannelApplication
+	for _, app := range m.applications {
+		if app.ChatID == chatID && app.ChannelID == channelID {
+			applications = append(applications, *app)
+		}
+	}
+	return applications, nil
+}
+
+// VerifyChannelOwnership 验证频道所有权
+func (m *MemoryStore) VerifyChannelOwnership(chatID, channelID, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, app := range m.applications {
+		if app.ChatID == chatID && app.ChannelID == channelID && app.UserID == userID && app.Status == "pending" {
+			app.VerifiedChannel = true
+		}
+	}
+	return nil
+}
+
+// UpdateLastPromptDate 更新最后提示日期，并重置 prompted_today 状态
+func (m *MemoryStore) UpdateLastPromptDate(chatID, channelID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	for _, app := range m.applications {
+		if app.ChatID == chatID && app.ChannelID == channelID && app.Status == "pending" {
+			app.PromptedToday = false
+			app.LastPromptDate = today
+		}
+	}
+	return nil
+}
+
+// GetPendingApplications 获取待处理的申请
+func (m *MemoryStore) GetPendingApplications() ([]models.ChannelApplication, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var applications []models.ChannelApplication
+	for _, app := range m.applications {
+		if app.Status == "pending" {
+			applications = append(applications, *app)
+		}
+	}
+	return applications, nil
+}
+
+// GetPendingApplicationsPage 按申请先后分页获取待处理的申请，同时返回总条数。chatID 为 0 时不限群组
+func (m *MemoryStore) GetPendingApplicationsPage(chatID int64, limit, offset int) ([]models.ChannelApplication, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matched []models.ChannelApplication
+	for _, app := range m.applications {
+		if app.Status == "pending" && (chatID == 0 || app.ChatID == chatID) {
+			matched = append(matched, *app)
+		}
+	}
+
+	total := len(matched)
+	if offset >= total {
+		return nil, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return matched[offset:end], total, nil
+}
+
+// GetChannelApplicationByID 根据申请ID获取申请，不存在时返回零值
+func (m *MemoryStore) GetChannelApplicationByID(applicationID int64) (models.ChannelApplication, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, app := range m.applications {
+		if app.ID == applicationID {
+			return *app, nil
+		}
+	}
+	return models.ChannelApplication{}, nil
+}
+
+// RecordApplicationVote 记录管理员对申请的投票，同一管理员再次投票时覆盖之前的投票
+func (m *MemoryStore) RecordApplicationVote(vote models.ApplicationVote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if vote.VotedAt.IsZero() {
+		vote.VotedAt = time.Now()
+	}
+	for i, existing := range m.votes {
+		if existing.ApplicationID == vote.ApplicationID && existing.VoterID == vote.VoterID {
+			m.votes[i] = vote
+			return nil
+		}
+	}
+	m.votes = append(m.votes, vote)
+	return nil
+}
+
+// GetApplicationVotes 按投票先后获取申请的全部投票
+func (m *MemoryStore) GetApplicationVotes(applicationID int64) ([]models.ApplicationVote, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var votes []models.ApplicationVote
+	for _, vote := range m.votes {
+		if vote.ApplicationID == applicationID {
+			votes = append(votes, vote)
+		}
+	}
+	sort.SliceStable(votes, func(i, j int) bool {
+		if !votes[i].VotedAt.Equal(votes[j].VotedAt) {
+			return votes[i].VotedAt.Before(votes[j].VotedAt)
+		}
+		return votes[i].VoterID < votes[j].VoterID
+	})
+	return votes, nil
+}
+
+// AddApplicationNotice 记录发送给管理员的申请通知消息，同一私聊只保留最新的一条
+func (m *MemoryStore) AddApplicationNotice(notice models.ApplicationNotice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.notices {
+		if existing.ApplicationID == notice.ApplicationID && existing.ChatID == notice.ChatID {
+			m.notices[i] = notice
+			return nil
+		}
+	}
+	m.notices = append(m.notices, notice)
+	return nil
+}
+
+// GetApplicationNotices 获取申请的全部通知消息
+func (m *MemoryStore) GetApplicationNotices(applicationID int64) ([]models.ApplicationNotice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var notices []models.ApplicationNotice
+	for _, notice := range m.notices {
+		if notice.ApplicationID == applicationID {
+			notices = append(notices, notice)
+		}
+	}
+	sort.Slice(notices, func(i, j int) bool {
+		return notices[i].ChatID < notices[j].ChatID
+	})
+	return notices, nil
+}
+
+// AddOwnershipCheck 为申请添加一项所有权验证，已存在时保留原有的验证码和验证结果
+func (m *MemoryStore) AddOwnershipCheck(check models.OwnershipCheck) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.ownershipChecks {
+		if existing.ApplicationID == check.ApplicationID && existing.Method == check.Method {
+			return nil
+		}
+	}
+	m.ownershipChecks = append(m.ownershipChecks, check)
+	return nil
+}
+
+// GetOwnershipChecks 获取申请的全部所有权验证
+func (m *MemoryStore) GetOwnershipChecks(applicationID int64) ([]models.OwnershipCheck, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var checks []models.OwnershipCheck
+	for _, check := range m.ownershipChecks {
+		if check.ApplicationID == applicationID {
+			checks = append(checks, check)
+		}
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].Method < checks[j].Method
+	})
+	return checks, nil
+}
+
+// PassOwnershipCheck 将申请的一项所有权验证标记为已通过
+func (m *MemoryStore) PassOwnershipCheck(applicationID int64, method string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, check := range m.ownershipChecks {
+		if check.ApplicationID == applicationID && check.Method == method {
+			if check.PassedAt.IsZero() {
+				m.ownershipChecks[i].PassedAt = time.Now()
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// GetChannelApplicationByDate 根据日期获取频道今日是否已提示过申请
+func (m *MemoryStore) GetChannelApplicationByDate(chatID, channelID int64, date string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, app := range m.applications {
+		if app.ChatID == chatID && app.ChannelID == channelID &&
+			!app.LastPromptDate.IsZero() && app.LastPromptDate.Format("2006-01-02") == date {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UpdateChannelApplicationUser 更新频道申请的认领人。已通过所有权验证的认领不能被取代；
+// 尚未通过验证的认领可以由其他用户重新认领，同时清除原认领人的所有权验证记录
+func (m *MemoryStore) UpdateChannelApplicationUser(chatID, channelID, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target := m.latestApplication(chatID, channelID, "pending")
+	if target == nil {
+		return fmt.Errorf("未找到该频道的待处理申请")
+	}
+
+	if target.UserID != 0 && target.UserID != userID {
+		// 如果已经被其他人认领并通过了所有权验证，则不允许再次认领
+		if target.VerifiedChannel {
+			return fmt.Errorf("该频道申请已被认领")
+		}
+		checks := m.ownershipChecks[:0]
+		for _, check := range m.ownershipChecks {
+			if check.ApplicationID != target.ID {
+				checks = append(checks, check)
+			}
+		}
+		m.ownershipChecks = checks
+	}
+
+	target.UserID = userID
+	if target.ClaimedAt.IsZero() {
+		target.ClaimedAt = time.Now()
+	}
+	return nil
+}
+
+// UpdateChannelApplicationReason 更新频道申请的理由
+func (m *MemoryStore) UpdateChannelApplicationReason(chatID, channelID int64, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, app := range m.applications {
+		if app.ChatID == chatID && app.ChannelID == channelID && app.Status == "pending" {
+			app.Reason = reason
+		}
+	}
+	return nil
+}
+
+// GetPendingChannelApplication 获取指定频道的待处理申请
+func (m *MemoryStore) GetPendingChannelApplication(chatID, channelID int64) (models.ChannelApplication, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if app := m.latestApplication(chatID, channelID, "pending"); app != nil {
+		return *app, nil
+	}
+	return models.ChannelApplication{}, nil
+}
+
+// HasPendingApplication 检查是否有待处理的申请
+func (m *MemoryStore) HasPendingApplication(chatID, channelID int64) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.latestApplication(chatID, channelID, "pending") != nil, nil
+}
+
+// SetUserState 设置用户状态
+func (m *MemoryStore) SetUserState(userID int64, state string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.userStates[userID] = state
+	return nil
+}
+
+// GetUserState 获取用户状态
+func (m *MemoryStore) GetUserState(userID int64) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.userStates[userID], nil
+}
+
+// ClearUserState 清除用户状态
+func (m *MemoryStore) ClearUserState(userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.userStates, userID)
+	return nil
+}
+
+// ClearUserStatesByValue 清除所有处于指定状态的用户状态
+func (m *MemoryStore) ClearUserStatesByValue(state string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for userID, current := range m.userStates {
+		if current == state {
+			delete(m.userStates, userID)
+		}
+	}
+	return nil
+}
+
+// HasChannelDailyPrompt 检查指定频道在当天是否已经有过特定类型的提示
+func (m *MemoryStore) HasChannelDailyPrompt(chatID, channelID int64, promptType string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key := dailyPromptKey{
+		ChatID:     chatID,
+		ChannelID:  channelID,
+		PromptType: promptType,
+		PromptDate: time.Now().Format("2006-01-02"),
+	}
+	_, exists := m.dailyPrompts[key]
+	return exists, nil
+}
+
+// RecordChannelDailyPrompt 记录指定频道的每日提示
+func (m *MemoryStore) RecordChannelDailyPrompt(chatID, channelID int64, promptType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := dailyPromptKey{
+		ChatID:     chatID,
+		ChannelID:  channelID,
+		PromptType: promptType,
+		PromptDate: time.Now().Format("2006-01-02"),
+	}
+	m.dailyPrompts[key] = struct{}{}
+	return nil
+}
+
+// HasPromptedToday 检查今天是否已经提示过"需要申请"
+func (m *MemoryStore) HasPromptedToday(chatID, channelID int64) (bool, error) {
+	return m.HasChannelDailyPrompt(chatID, channelID, PromptTypeWhitelistWarning)
+}
+
+// HasPendingNoticeToday 检查今天是否已经提示过待审核通知
+func (m *MemoryStore) HasPendingNoticeToday(chatID, channelID int64) (bool, error) {
+	return m.HasChannelDailyPrompt(chatID, channelID, PromptTypePendingNotice)
+}
+
+// RecordPrompt 记录今天已经提示过"需要申请"
+func (m *MemoryStore) RecordPrompt(chatID, channelID int64) error {
+	return m.RecordChannelDailyPrompt(chatID, channelID, PromptTypeWhitelistWarning)
+}
+
+// RecordPendingNotice 记录今天已经提示过待审核通知
+func (m *MemoryStore) RecordPendingNotice(chatID, channelID int64) error {
+	return m.RecordChannelDailyPrompt(chatID, channelID, PromptTypePendingNotice)
+}
+
+// ResetDailyPrompts 重置每日提示状态
+func (m *MemoryStore) ResetDailyPrompts() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	today := time.Now().Format("2006-01-02")
+	for key := range m.dailyPrompts {
+		if key.PromptDate != today {
+			delete(m.dailyPrompts, key)
+		}
+	}
+
+	for _, app := range m.applications {
+		if app.Status == "pending" {
+			app.PromptedToday = false
+		}
+	}
+	return nil
+}
+
+// AddAuditLog 记录一次管理操作
+func (m *MemoryStore) AddAuditLog(entry models.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry.ID = m.newID("audit_log")
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	m.auditLog = append(m.auditLog, entry)
+	return nil
+}
+
+// GetAuditLogs 按时间倒序分页获取群组的审计日志，同时返回符合条件的总条数
+func (m *MemoryStore) GetAuditLogs(chatID int64, filter models.AuditFilter, limit, offset int) ([]models.AuditEntry, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matched []models.AuditEntry
+	for i := len(m.auditLog) - 1; i >= 0; i-- {
+		entry := m.auditLog[i]
+		if entry.ChatID != chatID {
+			continue
+		}
+		if filter.Action != "" && entry.Action != filter.Action {
+			continue
+		}
+		if filter.TargetID != 0 && entry.TargetID != filter.TargetID {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+
+	total := len(matched)
+	if offset >= total {
+		return nil, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return matched[offset:end], total, nil
+}
+
+// SetGlobalListEntry 将频道加入全局白名单或黑名单，频道已在另一个名单中时会被移过来
+func (m *MemoryStore) SetGlobalListEntry(listType string, channelID, addedBy int64, description string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.globalLists[channelID] = models.GlobalListEntry{
+		ChannelID:   channelID,
+		ListType:    listType,
+		AddedBy:     addedBy,
+		AddedAt:     time.Now(),
+		Description: description,
+	}
+	return nil
+}
+
+// RemoveGlobalListEntry 将频道从指定的全局名单中移除
+func (m *MemoryStore) RemoveGlobalListEntry(listType string, channelID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, exists := m.globalLists[channelID]; exists && entry.ListType == listType {
+		delete(m.globalLists, channelID)
+	}
+	return nil
+}
+
+// GetGlobalListType 获取频道所在的全局名单类型，不在任何名单中时返回空字符串
+func (m *MemoryStore) GetGlobalListType(channelID int64) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.globalLists[channelID].ListType, nil
+}
+
+// GetGlobalListEntries 获取指定全局名单中的全部频道，按添加时间倒序
+func (m *MemoryStore) GetGlobalListEntries(listType string) ([]models.GlobalListEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []models.GlobalListEntry
+	for _, entry := range m.globalLists {
+		if entry.ListType == listType {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].AddedAt.After(entries[j].AddedAt)
+	})
+	return entries, nil
+}
+
+// AddSenderChatBan 记录群组中被封禁的频道，expiresAt 为零值时永久封禁。
+// 已有记录时覆盖为新的封禁信息
+func (m *MemoryStore) AddSenderChatBan(chatID, channelID, bannedBy int64, reason string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := whitelistKey{ChatID: chatID, ChannelID: channelID}
+	ban, exists := m.senderChatBans[key]
+	if !exists {
+		ban = models.SenderChatBan{
+			ID:        m.newID("banned_sender_chats"),
+			ChatID:    chatID,
+			ChannelID: channelID,
+		}
+	}
+	ban.BannedBy = bannedBy
+	ban.BannedAt = time.Now()
+	ban.Reason = reason
+	ban.ExpiresAt = expiresAt
+	m.senderChatBans[key] = ban
+	delete(m.senderUnbans, key)
+	return nil
+}
+
+// RemoveSenderChatBan 解除频道的封禁，并记录解封时间
+func (m *MemoryStore) RemoveSenderChatBan(chatID, channelID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := whitelistKey{ChatID: chatID, ChannelID: channelID}
+	if _, exists := m.senderChatBans[key]; exists {
+		delete(m.senderChatBans, key)
+		m.senderUnbans[key] = time.Now()
+	}
+	return nil
+}
+
+// GetSenderChatUnbanTime 获取频道在群组中最近一次解除封禁的时间，从未被解封或仍在封禁中时返回零值
+func (m *MemoryStore) GetSenderChatUnbanTime(chatID, channelID int64) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.senderUnbans[whitelistKey{ChatID: chatID, ChannelID: channelID}], nil
+}
+
+// IsSenderChatBanned 检查频道在群组中是否有封禁记录
+func (m *MemoryStore) IsSenderChatBanned(chatID, channelID int64) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, exists := m.senderChatBans[whitelistKey{ChatID: chatID, ChannelID: channelID}]
+	return exists, nil
+}
+
+// GetSenderChatBans 获取群组中被封禁的频道，按封禁时间倒序
+func (m *MemoryStore) GetSenderChatBans(chatID int64) ([]models.SenderChatBan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var bans []models.SenderChatBan
+	for key, ban := range m.senderChatBans {
+		if key.ChatID == chatID {
+			bans = append(bans, ban)
+		}
+	}
+
+	sort.Slice(bans, func(i, j int) bool {
+		if bans[i].BannedAt.Equal(bans[j].BannedAt) {
+			return bans[i].ID > bans[j].ID
+		}
+		return bans[i].BannedAt.After(bans[j].BannedAt)
+	})
+	return bans, nil
+}
+
+// GetExpiredSenderChatBans 获取在 now 之前到期的临时封禁
+func (m *MemoryStore) GetExpiredSenderChatBans(now time.Time) ([]models.SenderChatBan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var bans []models.SenderChatBan
+	for _, ban := range m.senderChatBans {
+		if ban.IsTemporary() && !ban.ExpiresAt.After(now) {
+			bans = append(bans, ban)
+		}
+	}
+	return bans, nil
+}
+
+// GetEscalationSteps 获取群组的处罚升级规则，按触发次数升序
+func (m *MemoryStore) GetEscalationSteps(chatID int64) ([]models.EscalationStep, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	steps := make([]models.EscalationStep, len(m.escalation[chatID]))
+	copy(steps, m.escalation[chatID])
+	return steps, nil
+}
+
+// SetEscalationSteps 用 steps 替换群组的全部处罚升级规则，steps 为空时清除规则
+func (m *MemoryStore) SetEscalationSteps(chatID int64, steps []models.EscalationStep) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(steps) == 0 {
+		delete(m.escalation, chatID)
+		return nil
+	}
+
+	stored := make([]models.EscalationStep, 0, len(steps))
+	seen := make(map[int]bool)
+	for _, step := range steps {
+		if seen[step.Threshold] {
+			return fmt.Errorf("群组 %d 的处罚升级规则中有重复的触发次数 %d", chatID, step.Threshold)
+		}
+		seen[step.Threshold] = true
+		step.ChatID = chatID
+		stored = append(stored, step)
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].Threshold < stored[j].Threshold
+	})
+	m.escalation[chatID] = stored
+	return nil
+}
+
+// CreateWhitelistSet 创建共享白名单并返回创建的记录
+func (m *MemoryStore) CreateWhitelistSet(name string, ownerID int64, description string) (models.WhitelistSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, set := range m.whitelistSets {
+		if set.Name == name {
+			return models.WhitelistSet{}, fmt.Errorf("共享白名单 %s 已存在", name)
+		}
+	}
+
+	set := models.WhitelistSet{
+		ID:          m.newID("whitelist_sets"),
+		Name:        name,
+		OwnerID:     ownerID,
+		Description: description,
+		CreatedAt:   time.Now(),
+	}
+	m.whitelistSets[set.ID] = set
+	return set, nil
+}
+
+// GetWhitelistSet 按名称获取共享白名单，不存在时返回 ID 为 0 的零值
+func (m *MemoryStore) GetWhitelistSet(name string) (models.WhitelistSet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, set := range m.whitelistSets {
+		if set.Name == name {
+			return set, nil
+		}
+	}
+	return models.WhitelistSet{}, nil
+}
+
+// GetWhitelistSets 获取 ownerID 拥有的共享白名单，ownerID 为 0 时返回全部，按名称排序
+func (m *MemoryStore) GetWhitelistSets(ownerID int64) ([]models.WhitelistSet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sets []models.WhitelistSet
+	for _, set := range m.whitelistSets {
+		if ownerID == 0 || set.OwnerID == ownerID {
+			sets = append(sets, set)
+		}
+	}
+	sortWhitelistSets(sets)
+	return sets, nil
+}
+
+// DeleteWhitelistSet 删除共享白名单及其频道和全部订阅
+func (m *MemoryStore) DeleteWhitelistSet(setID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.whitelistSets, setID)
+	for key := range m.setChannels {
+		if key.SetID == setID {
+			delete(m.setChannels, key)
+		}
+	}
+	for key := range m.subscriptions {
+		if key.SetID == setID {
+			delete(m.subscriptions, key)
+		}
+	}
+	return nil
+}
+
+// AddChannelToSet 将频道加入共享白名单，已存在时保持不变
+func (m *MemoryStore) AddChannelToSet(setID, channelID, addedBy int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := setChannelKey{SetID: setID, ChannelID: channelID}
+	if _, exists := m.setChannels[key]; exists {
+		return nil
+	}
+	m.setChannels[key] = models.WhitelistSetChannel{
+		SetID:     setID,
+		ChannelID: channelID,
+		AddedBy:   addedBy,
+		AddedAt:   time.Now(),
+	}
+	return nil
+}
+
+// RemoveChannelFromSet 将频道从共享白名单中移除
+func (m *MemoryStore) RemoveChannelFromSet(setID, channelID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.setChannels, setChannelKey{SetID: setID, ChannelID: channelID})
+	return nil
+}
+
+// GetSetChannels 获取共享白名单中的全部频道，按添加时间倒序
+func (m *MemoryStore) GetSetChannels(setID int64) ([]models.WhitelistSetChannel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var channels []models.WhitelistSetChannel
+	for key, channel := range m.setChannels {
+		if key.SetID == setID {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].AddedAt.After(channels[j].AddedAt)
+	})
+	return channels, nil
+}
+
+// SubscribeToSet 群组订阅共享白名单，已订阅时保持不变
+func (m *MemoryStore) SubscribeToSet(chatID, setID, subscribedBy int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.subscriptions[subscriptionKey{ChatID: chatID, SetID: setID}] = struct{}{}
+	return nil
+}
+
+// UnsubscribeFromSet 群组取消订阅共享白名单
+func (m *MemoryStore) UnsubscribeFromSet(chatID, setID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.subscriptions, subscriptionKey{ChatID: chatID, SetID: setID})
+	return nil
+}
+
+// GetSubscribedSets 获取群组订阅的共享白名单，按名称排序
+func (m *MemoryStore) GetSubscribedSets(chatID int64) ([]models.WhitelistSet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sets []models.WhitelistSet
+	for key := range m.subscriptions {
+		if set, ok := m.whitelistSets[key.SetID]; ok && key.ChatID == chatID {
+			sets = append(sets, set)
+		}
+	}
+	sortWhitelistSets(sets)
+	return sets, nil
+}
+
+// GetSubscribedSetsContaining 获取群组订阅的共享白名单中包含该频道的名单，按名称排序
+func (m *MemoryStore) GetSubscribedSetsContaining(chatID, channelID int64) ([]models.WhitelistSet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.subscribedSetsContainingLocked(chatID, channelID), nil
+}
+
+// subscribedSetsContainingLocked 获取群组订阅的共享白名单中包含该频道的名单，调用方需持有锁
+func (m *MemoryStore) subscribedSetsContainingLocked(chatID, channelID int64) []models.WhitelistSet {
+	var sets []models.WhitelistSet
+	for key := range m.subscriptions {
+		if key.ChatID != chatID {
+			continue
+		}
+		if _, ok := m.setChannels[setChannelKey{SetID: key.SetID, ChannelID: channelID}]; !ok {
+			continue
+		}
+		if set, ok := m.whitelistSets[key.SetID]; ok {
+			sets = append(sets, set)
+		}
+	}
+	sortWhitelistSets(sets)
+	return sets
+}
+
+// sortWhitelistSets 按名称排序共享白名单
+func sortWhitelistSets(sets []models.WhitelistSet) {
+	sort.Slice(sets, func(i, j int) bool {
+		return sets[i].Name < sets[j].Name
+	})
+}
@@ -0,0 +1,678 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration 描述一次数据库结构变更
+type migration struct {
+	Version     int
+	Description string
+	Up          func(tx *tx) error
+}
+
+// MigrationInfo 描述一次迁移的执行状态
+type MigrationInfo struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+// migrationLockID PostgreSQL 迁移使用的咨询锁ID
+const migrationLockID = 7313620101
+
+// migrations 按版本号升序排列的全部迁移，已发布的迁移不可修改，只能追加
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "初始表结构",
+		Up:          migrateInitialSchema,
+	},
+	{
+		Version:     2,
+		Description: "补齐 channel_applications 的提示字段",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "channel_applications", "last_prompt_date", "DATE"); err != nil {
+				return err
+			}
+			return addColumnIfMissing(tx, "channel_applications", "prompted_today", "BOOLEAN NOT NULL DEFAULT 0")
+		},
+	},
+	{
+		Version:     3,
+		Description: "群组的被阻止消息保留期限",
+		Up: func(tx *tx) error {
+			return execStatements(tx, []string{
+				`ALTER TABLE group_settings ADD COLUMN retention_days INTEGER NOT NULL DEFAULT 0`,
+				`CREATE INDEX IF NOT EXISTS idx_blocked_messages_chat_time ON blocked_messages(chat_id, blocked_at)`,
+			})
+		},
+	},
+	{
+		Version:     4,
+		Description: "管理操作审计日志",
+		Up: func(tx *tx) error {
+			return execStatements(tx, []string{
+				fmt.Sprintf(`CREATE TABLE IF NOT EXISTS audit_log (
+					id %s,
+					chat_id BIGINT NOT NULL,
+					actor_id BIGINT NOT NULL,
+					action TEXT NOT NULL,
+					target_id BIGINT NOT NULL DEFAULT 0,
+					before_value TEXT NOT NULL DEFAULT '',
+					after_value TEXT NOT NULL DEFAULT '',
+					created_at TIMESTAMP NOT NULL
+				)`, tx.dialect.autoIncrementPK()),
+				`CREATE INDEX IF NOT EXISTS idx_audit_log_chat ON audit_log(chat_id, id)`,
+			})
+		},
+	},
+	{
+		Version:     5,
+		Description: "保留全部申请记录并记录审核信息",
+		Up:          migrateApplicationHistory,
+	},
+	{
+		Version:     6,
+		Description: "白名单条目的过期时间",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "whitelisted_channels", "expires_at", "TIMESTAMP"); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_whitelisted_channels_expires ON whitelisted_channels(expires_at)`)
+			return err
+		},
+	},
+	{
+		Version:     7,
+		Description: "全局白名单和黑名单",
+		Up: func(tx *tx) error {
+			return execStatements(tx, []string{
+				`CREATE TABLE IF NOT EXISTS global_channel_lists (
+					channel_id BIGINT PRIMARY KEY,
+					list_type TEXT NOT NULL,
+					added_by BIGINT NOT NULL,
+					added_at TIMESTAMP NOT NULL,
+					description TEXT NOT NULL DEFAULT ''
+				)`,
+				`ALTER TABLE group_settings ADD COLUMN use_global_lists BOOLEAN NOT NULL DEFAULT TRUE`,
+			})
+		},
+	},
+	{
+		Version:     8,
+		Description: "多次违规后自动封禁频道",
+		Up: func(tx *tx) error {
+			return execStatements(tx, []string{
+				`ALTER TABLE group_settings ADD COLUMN ban_threshold INTEGER NOT NULL DEFAULT 0`,
+				fmt.Sprintf(`CREATE TABLE IF NOT EXISTS banned_sender_chats (
+					id %s,
+					chat_id BIGINT NOT NULL,
+					channel_id BIGINT NOT NULL,
+					banned_by BIGINT NOT NULL,
+					banned_at TIMESTAMP NOT NULL,
+					reason TEXT NOT NULL DEFAULT '',
+					UNIQUE(chat_id, channel_id)
+				)`, tx.dialect.autoIncrementPK()),
+				`CREATE INDEX IF NOT EXISTS idx_blocked_messages_chat_channel ON blocked_messages(chat_id, channel_id)`,
+			})
+		},
+	},
+	{
+		Version:     9,
+		Description: "群组的违规处罚升级规则和临时封禁",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "banned_sender_chats", "expires_at", "TIMESTAMP"); err != nil {
+				return err
+			}
+			return execStatements(tx, []string{
+				`ALTER TABLE group_settings ADD COLUMN escalation_window_hours INTEGER NOT NULL DEFAULT 24`,
+				`CREATE TABLE IF NOT EXISTS escalation_steps (
+					chat_id BIGINT NOT NULL,
+					threshold INTEGER NOT NULL,
+					action TEXT NOT NULL,
+					duration_seconds BIGINT NOT NULL DEFAULT 0,
+					PRIMARY KEY (chat_id, threshold)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_blocked_messages_chat_channel_time ON blocked_messages(chat_id, channel_id, blocked_at)`,
+				`CREATE INDEX IF NOT EXISTS idx_banned_sender_chats_expires ON banned_sender_chats(expires_at)`,
+			})
+		},
+	},
+	{
+		Version:     10,
+		Description: "白名单记录频道用户名",
+		Up: func(tx *tx) error {
+			return addColumnIfMissing(tx, "whitelisted_channels", "username", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		Version:     11,
+		Description: "多个群组共享的白名单",
+		Up: func(tx *tx) error {
+			return execStatements(tx, []string{
+				fmt.Sprintf(`CREATE TABLE IF NOT EXISTS whitelist_sets (
+					id %s,
+					name TEXT NOT NULL UNIQUE,
+					owner_id BIGINT NOT NULL,
+					description TEXT NOT NULL DEFAULT '',
+					created_at TIMESTAMP NOT NULL
+				)`, tx.dialect.autoIncrementPK()),
+				`CREATE TABLE IF NOT EXISTS whitelist_set_channels (
+					set_id BIGINT NOT NULL,
+					channel_id BIGINT NOT NULL,
+					added_by BIGINT NOT NULL,
+					added_at TIMESTAMP NOT NULL,
+					PRIMARY KEY (set_id, channel_id)
+				)`,
+				`CREATE TABLE IF NOT EXISTS whitelist_set_subscriptions (
+					chat_id BIGINT NOT NULL,
+					set_id BIGINT NOT NULL,
+					subscribed_by BIGINT NOT NULL,
+					subscribed_at TIMESTAMP NOT NULL,
+					PRIMARY KEY (chat_id, set_id)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_whitelist_set_channels_channel ON whitelist_set_channels(channel_id)`,
+			})
+		},
+	},
+	{
+		Version:     12,
+		Description: "白名单频道的发言配额",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "whitelisted_channels", "quota_posts", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			if err := addColumnIfMissing(tx, "whitelisted_channels", "quota_window_seconds", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			if err := addColumnIfMissing(tx, "blocked_messages", "reason", "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			return execStatements(tx, []string{
+				fmt.Sprintf(`CREATE TABLE IF NOT EXISTS channel_posts (
+					id %s,
+					chat_id BIGINT NOT NULL,
+					channel_id BIGINT NOT NULL,
+					posted_at TIMESTAMP NOT NULL
+				)`, tx.dialect.autoIncrementPK()),
+				`CREATE INDEX IF NOT EXISTS idx_channel_posts_chat_channel_time ON channel_posts(chat_id, channel_id, posted_at)`,
+			})
+		},
+	},
+	{
+		Version:     13,
+		Description: "白名单频道的内容限制",
+		Up: func(tx *tx) error {
+			return addColumnIfMissing(tx, "whitelisted_channels", "content_policy", "TEXT NOT NULL DEFAULT ''")
+		},
+	},
+	{
+		Version:     14,
+		Description: "群组可以将关联频道身份视为管理员",
+		Up: func(tx *tx) error {
+			return addColumnIfMissing(tx, "group_settings", "linked_channel_admin", "BOOLEAN NOT NULL DEFAULT FALSE")
+		},
+	},
+	{
+		Version:     15,
+		Description: "记录群组的关联频道",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "group_settings", "linked_channel_id", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			return addColumnIfMissing(tx, "group_settings", "linked_channel_auto", "BOOLEAN NOT NULL DEFAULT TRUE")
+		},
+	},
+	{
+		Version:     16,
+		Description: "群组的观察模式",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "group_settings", "observe_mode", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+				return err
+			}
+			if err := addColumnIfMissing(tx, "group_settings", "observe_since", "TIMESTAMP"); err != nil {
+				return err
+			}
+			return addColumnIfMissing(tx, "blocked_messages", "observed", "BOOLEAN NOT NULL DEFAULT FALSE")
+		},
+	},
+	{
+		Version:     17,
+		Description: "多位管理员投票审核申请",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "group_settings", "review_quorum", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+				return err
+			}
+			return execStatements(tx, []string{
+				`CREATE TABLE IF NOT EXISTS application_votes (
+					application_id BIGINT NOT NULL,
+					voter_id BIGINT NOT NULL,
+					vote TEXT NOT NULL,
+					note TEXT NOT NULL DEFAULT '',
+					voted_at TIMESTAMP NOT NULL,
+					PRIMARY KEY (application_id, voter_id)
+				)`,
+				`CREATE TABLE IF NOT EXISTS application_notices (
+					application_id BIGINT NOT NULL,
+					chat_id BIGINT NOT NULL,
+					message_id BIGINT NOT NULL,
+					PRIMARY KEY (application_id, chat_id)
+				)`,
+			})
+		},
+	},
+	{
+		Version:     18,
+		Description: "申请被拒绝后重新申请的冷却时间",
+		Up: func(tx *tx) error {
+			return addColumnIfMissing(tx, "group_settings", "reapply_cooldown_seconds", "BIGINT NOT NULL DEFAULT 0")
+		},
+	},
+	{
+		Version:     19,
+		Description: "待处理申请的超时时间",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "group_settings", "unclaimed_timeout_seconds", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			if err := addColumnIfMissing(tx, "group_settings", "unreviewed_timeout_seconds", "BIGINT NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			return addColumnIfMissing(tx, "channel_applications", "claimed_at", "TIMESTAMP")
+		},
+	},
+	{
+		Version:     20,
+		Description: "认领申请时的频道所有权验证",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "group_settings", "ownership_policy", "TEXT NOT NULL DEFAULT ''"); err != nil {
+				return err
+			}
+			return execStatements(tx, []string{
+				`CREATE TABLE IF NOT EXISTS ownership_checks (
+					application_id BIGINT NOT NULL,
+					method TEXT NOT NULL,
+					code TEXT NOT NULL DEFAULT '',
+					passed_at TIMESTAMP,
+					PRIMARY KEY (application_id, method)
+				)`,
+			})
+		},
+	},
+	{
+		Version:     21,
+		Description: "每个频道在同一群组只能有一个待处理申请",
+		Up: func(tx *tx) error {
+			return execStatements(tx, []string{
+				// 并发申请可能留下重复的待处理申请，只保留最新的一条
+				`UPDATE channel_applications SET status = 'expired'
+				WHERE status = 'pending' AND id NOT IN (
+					SELECT MAX(id) FROM channel_applications WHERE status = 'pending' GROUP BY chat_id, channel_id
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_channel_applications_pending
+				ON channel_applications(chat_id, channel_id) WHERE status = 'pending'`,
+			})
+		},
+	},
+	{
+		Version:     22,
+		Description: "记录频道最近一次解除封禁的时间",
+		Up: func(tx *tx) error {
+			if err := addColumnIfMissing(tx, "banned_sender_chats", "unbanned_at", "TIMESTAMP"); err != nil {
+				return err
+			}
+			// 之前解除封禁时直接删除记录，从审计日志中恢复最近一次解封的时间
+			return execStatements(tx, []string{
+				`INSERT INTO banned_sender_chats (chat_id, channel_id, banned_by, banned_at, reason, unbanned_at)
+				SELECT chat_id, target_id, 0, MAX(created_at), '', MAX(created_at)
+				FROM audit_log
+				WHERE action = 'sender_unban' AND target_id <> 0
+				GROUP BY chat_id, target_id
+				ON CONFLICT(chat_id, channel_id) DO NOTHING`,
+			})
+		},
+	},
+}
+
+// migrateInitialSchema 创建初始表结构，兼容引入版本管理之前已存在的数据库
+func migrateInitialSchema(tx *tx) error {
+	if tx.dialect == dialectPostgres {
+		return execStatements(tx, postgresInitialSchema)
+	}
+
+	return execStatements(tx, []string{
+		// 白名单频道表
+		`CREATE TABLE IF NOT EXISTS whitelisted_channels (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			channel_id INTEGER NOT NULL,
+			added_by INTEGER NOT NULL,
+			added_at TIMESTAMP NOT NULL,
+			description TEXT,
+			UNIQUE(chat_id, channel_id)
+		)`,
+		// 被阻止的消息表
+		`CREATE TABLE IF NOT EXISTS blocked_messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			channel_id INTEGER NOT NULL,
+			message_id INTEGER NOT NULL,
+			blocked_at TIMESTAMP NOT NULL,
+			message_text TEXT
+		)`,
+		// 群组设置表
+		`CREATE TABLE IF NOT EXISTS group_settings (
+			chat_id INTEGER PRIMARY KEY,
+			admin_only BOOLEAN NOT NULL DEFAULT 1,
+			log_channel_id INTEGER NOT NULL DEFAULT 0,
+			enabled BOOLEAN NOT NULL DEFAULT 1
+		)`,
+		// 频道申请表
+		`CREATE TABLE IF NOT EXISTS channel_applications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			channel_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			reason TEXT,
+			applied_at TIMESTAMP NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			verified_channel BOOLEAN NOT NULL DEFAULT 0,
+			UNIQUE(chat_id, channel_id, user_id)
+		)`,
+		// 用户状态表
+		`CREATE TABLE IF NOT EXISTS user_states (
+			user_id INTEGER PRIMARY KEY,
+			state TEXT,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// 每日频道提示记录表
+		`CREATE TABLE IF NOT EXISTS channel_daily_prompts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL,
+			channel_id INTEGER NOT NULL,
+			prompt_type TEXT NOT NULL,
+			prompt_date DATE NOT NULL,
+			UNIQUE(chat_id, channel_id, prompt_type, prompt_date)
+		)`,
+	})
+}
+
+// execStatements 依次执行多条语句
+func execStatements(tx *tx, statements []string) error {
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateApplicationHistory 去掉 channel_applications 的 (chat_id, channel_id, user_id) 唯一约束，
+// 使每次申请都保留为独立记录，并增加审核人、审核时间、审核备注和审核时的认领人
+func migrateApplicationHistory(tx *tx) error {
+	if tx.dialect == dialectPostgres {
+		if _, err := tx.Exec(`ALTER TABLE channel_applications DROP CONSTRAINT IF EXISTS channel_applications_chat_id_channel_id_user_id_key`); err != nil {
+			return err
+		}
+		columns := [][2]string{
+			{"reviewer_id", "BIGINT NOT NULL DEFAULT 0"},
+			{"decided_at", "TIMESTAMP"},
+			{"decision_note", "TEXT NOT NULL DEFAULT ''"},
+			{"claimant_id", "BIGINT NOT NULL DEFAULT 0"},
+		}
+		for _, column := range columns {
+			if err := addColumnIfMissing(tx, "channel_applications", column[0], column[1]); err != nil {
+				return err
+			}
+		}
+	} else {
+		// SQLite 无法删除约束，只能重建表
+		err := execStatements(tx, []string{
+			`CREATE TABLE channel_applications_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				chat_id INTEGER NOT NULL,
+				channel_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				reason TEXT,
+				applied_at TIMESTAMP NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending',
+				verified_channel BOOLEAN NOT NULL DEFAULT 0,
+				last_prompt_date DATE,
+				prompted_today BOOLEAN NOT NULL DEFAULT 0,
+				reviewer_id INTEGER NOT NULL DEFAULT 0,
+				decided_at TIMESTAMP,
+				decision_note TEXT NOT NULL DEFAULT '',
+				claimant_id INTEGER NOT NULL DEFAULT 0
+			)`,
+			`INSERT INTO channel_applications_new
+				(id, chat_id, channel_id, user_id, reason, applied_at, status, verified_channel, last_prompt_date, prompted_today)
+			SELECT id, chat_id, channel_id, user_id, reason, applied_at, status, verified_channel, last_prompt_date, prompted_today
+			FROM channel_applications`,
+			`DROP TABLE channel_applications`,
+			`ALTER TABLE channel_applications_new RENAME TO channel_applications`,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return execStatements(tx, []string{
+		// 旧记录没有审核信息，以申请人作为审核时的认领人
+		`UPDATE channel_applications SET claimant_id = user_id WHERE status <> 'pending'`,
+		`CREATE INDEX IF NOT EXISTS idx_channel_applications_chat_channel ON channel_applications(chat_id, channel_id)`,
+	})
+}
+
+// columnExists 检查表中是否存在指定列
+func columnExists(tx *tx, table, column string) (bool, error) {
+	if tx.dialect == dialectPostgres {
+		var count int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?
+		`, table, column).Scan(&count)
+		return count > 0, err
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	exists := false
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			exists = true
+		}
+	}
+	return exists, rows.Err()
+}
+
+// addColumnIfMissing 当列不存在时为表添加该列
+func addColumnIfMissing(tx *tx, table, column, definition string) error {
+	exists, err := columnExists(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// ensureSchemaVersionTable 创建记录已执行迁移的版本表
+func (db *DB) ensureSchemaVersionTable() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`)
+	return err
+}
+
+// schemaVersionTableExists 检查版本表是否存在，只读取系统表，不修改数据库
+func (db *DB) schemaVersionTableExists() (bool, error) {
+	var count int
+	var err error
+	if db.conn.dialect == dialectPostgres {
+		err = db.conn.QueryRow(`
+			SELECT COUNT(*) FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name = 'schema_version'
+		`).Scan(&count)
+	} else {
+		err = db.conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&count)
+	}
+	return count > 0, err
+}
+
+// appliedMigrations 获取已执行的迁移及其执行时间
+func (db *DB) appliedMigrations() (map[int]time.Time, error) {
+	rows, err := db.conn.Query(`SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// LatestSchemaVersion 返回程序支持的最新数据库结构版本
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion 获取数据库当前的结构版本，未执行过任何迁移（版本表不存在）时返回 0，不修改数据库
+func (db *DB) SchemaVersion() (int, error) {
+	exists, err := db.schemaVersionTableExists()
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := db.conn.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Migrate 按版本顺序执行所有未执行的迁移，每个迁移在独立事务中完成
+func (db *DB) Migrate() error {
+	if err := db.ensureSchemaVersionTable(); err != nil {
+		return err
+	}
+
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); current > latest {
+		return fmt.Errorf("数据库结构版本 %d 高于程序支持的版本 %d，请升级程序", current, latest)
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := db.applyMigration(m); err != nil {
+			return fmt.Errorf("执行迁移 %d (%s) 失败: %w", m.Version, m.Description, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration 在事务中执行单个迁移并记录版本
+func (db *DB) applyMigration(m migration) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 多个进程共享 PostgreSQL 时，串行执行迁移并跳过其他进程已完成的版本
+	if tx.dialect == dialectPostgres {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, migrationLockID); err != nil {
+			return err
+		}
+
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_version WHERE version = ?`, m.Version).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO schema_version (version, description, applied_at)
+		VALUES (?, ?, ?)
+	`, m.Version, m.Description, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MigrationStatus 获取所有迁移的执行状态
+func (db *DB) MigrationStatus() ([]MigrationInfo, error) {
+	exists, err := db.schemaVersionTableExists()
+	if err != nil {
+		return nil, err
+	}
+
+	// 版本表不存在时视为所有迁移都未执行
+	applied := make(map[int]time.Time)
+	if exists {
+		if applied, err = db.appliedMigrations(); err != nil {
+			return nil, err
+		}
+	}
+
+	infos := make([]MigrationInfo, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		infos = append(infos, MigrationInfo{
+			Version:     m.Version,
+			Description: m.Description,
+			Applied:     ok,
+			AppliedAt:   appliedAt,
+		})
+	}
+	return infos, nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
@@ -11,7 +12,102 @@ type WhitelistedChannel struct {
 	ChannelID   int64     `db:"channel_id"`  // 频道ID
 	AddedBy     int64     `db:"added_by"`    // 添加者ID
 	AddedAt     time.Time `db:"added_at"`    // 添加时间
+	Username    string    `db:"username"`    // 频道的公开用户名，不含 @，私有频道为空
 	Description string    `db:"description"` // 频道描述
+	ExpiresAt   time.Time `db:"expires_at"`  // 过期时间，零值表示永久有效
+
+	QuotaPosts  int           `db:"quota_posts"`          // 统计窗口内最多允许发送的消息数，0 表示不限制
+	QuotaWindow time.Duration `db:"quota_window_seconds"` // 发言配额的统计窗口
+
+	ContentPolicy ContentPolicy `db:"content_policy"` // 允许发送的内容限制，为空表示不限制
+}
+
+// IsTemporary 判断白名单条目是否设置了过期时间
+func (c WhitelistedChannel) IsTemporary() bool {
+	return !c.ExpiresAt.IsZero()
+}
+
+// HasQuota 判断白名单条目是否设置了发言配额
+func (c WhitelistedChannel) HasQuota() bool {
+	return c.QuotaPosts > 0 && c.QuotaWindow > 0
+}
+
+// 白名单频道的内容限制
+const (
+	ContentTextOnly   = "text_only"   // 只允许纯文字消息
+	ContentNoLinks    = "no_links"    // 不允许包含链接
+	ContentNoMedia    = "no_media"    // 不允许图片、视频、文件等媒体
+	ContentNoForwards = "no_forwards" // 不允许转发消息
+	ContentNoViaBot   = "no_via_bot"  // 不允许通过内联机器人发送的消息
+)
+
+// ContentRules 全部内容限制，按显示顺序排列
+var ContentRules = []string{ContentTextOnly, ContentNoLinks, ContentNoMedia, ContentNoForwards, ContentNoViaBot}
+
+// ContentPolicy 白名单频道的内容限制，多条限制同时生效
+type ContentPolicy []string
+
+// ParseContentPolicy 解析以逗号分隔的内容限制，忽略无法识别的限制，结果按 ContentRules 的顺序排列
+func ParseContentPolicy(value string) ContentPolicy {
+	rules := make(map[string]bool)
+	for _, rule := range strings.Split(value, ",") {
+		rules[strings.TrimSpace(rule)] = true
+	}
+
+	var policy ContentPolicy
+	for _, rule := range ContentRules {
+		if rules[rule] {
+			policy = append(policy, rule)
+		}
+	}
+	return policy
+}
+
+// Has 判断是否包含指定的限制
+func (p ContentPolicy) Has(rule string) bool {
+	for _, r := range p {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// String 返回以逗号分隔的内容限制，用于存储
+func (p ContentPolicy) String() string {
+	return strings.Join(p, ",")
+}
+
+// 全局名单类型
+const (
+	GlobalListWhitelist = "whitelist" // 全局白名单
+	GlobalListBlacklist = "blacklist" // 全局黑名单
+)
+
+// GlobalListEntry 表示全局白名单或黑名单中的一个频道，一个频道只能属于其中一个名单
+type GlobalListEntry struct {
+	ChannelID   int64     `db:"channel_id"`  // 频道ID
+	ListType    string    `db:"list_type"`   // 名单类型：whitelist, blacklist
+	AddedBy     int64     `db:"added_by"`    // 添加者ID
+	AddedAt     time.Time `db:"added_at"`    // 添加时间
+	Description string    `db:"description"` // 备注
+}
+
+// WhitelistSet 多个群组可以订阅的共享白名单，由所有者在私聊中管理
+type WhitelistSet struct {
+	ID          int64     `db:"id"`
+	Name        string    `db:"name"`        // 名称，全局唯一
+	OwnerID     int64     `db:"owner_id"`    // 所有者ID
+	Description string    `db:"description"` // 描述
+	CreatedAt   time.Time `db:"created_at"`  // 创建时间
+}
+
+// WhitelistSetChannel 共享白名单中的一个频道
+type WhitelistSetChannel struct {
+	SetID     int64     `db:"set_id"`     // 共享白名单ID
+	ChannelID int64     `db:"channel_id"` // 频道ID
+	AddedBy   int64     `db:"added_by"`   // 添加者ID
+	AddedAt   time.Time `db:"added_at"`   // 添加时间
 }
 
 // BlockedMessage 记录被删除的消息
@@ -22,22 +118,178 @@ type BlockedMessage struct {
 	MessageID   int       `db:"message_id"`   // 消息ID
 	BlockedAt   time.Time `db:"blocked_at"`   // 阻止时间
 	MessageText string    `db:"message_text"` // 消息内容，可能为空
+	Reason      string    `db:"reason"`       // 阻止原因，见 BlockReason 常量
+	Observed    bool      `db:"observed"`     // 是否为观察模式下本应删除、实际未删除的消息
 }
 
+// 消息被阻止的原因
+const (
+	BlockReasonNotWhitelisted = ""               // 频道不在白名单中，旧记录没有原因，也视为此类
+	BlockReasonQuota          = "quota"          // 白名单频道超出发言配额
+	BlockReasonContentPolicy  = "content_policy" // 白名单频道发送了内容限制不允许的消息
+	BlockReasonBlacklisted    = "blacklisted"    // 频道在全局黑名单中
+)
+
 // BlockedMessageInfo 用于消息队列的简化结构
 type BlockedMessageInfo struct {
 	ChatID      int64  // 群组ID
 	ChannelID   int64  // 频道ID
 	MessageID   int    // 消息ID
 	MessageText string // 消息内容，可能为空
+	Reason      string // 阻止原因
+	Observed    bool   // 是否为观察模式下本应删除、实际未删除的消息
+}
+
+// ObservedMessageStat 观察模式下频道本应被删除的消息数量
+type ObservedMessageStat struct {
+	ChannelID int64  // 频道ID
+	Reason    string // 阻止原因，见 BlockReason 常量
+	Count     int    // 消息数量
 }
 
 // GroupSettings 存储群组的设置信息
 type GroupSettings struct {
-	ChatID       int64 `db:"chat_id"`        // 群组ID
-	AdminOnly    bool  `db:"admin_only"`     // 是否只有管理员可以管理白名单
-	LogChannelID int64 `db:"log_channel_id"` // 日志频道ID
-	Enabled      bool  `db:"enabled"`        // 是否启用机器人
+	ChatID                int64 `db:"chat_id"`                 // 群组ID
+	AdminOnly             bool  `db:"admin_only"`              // 是否只有管理员可以管理白名单
+	LogChannelID          int64 `db:"log_channel_id"`          // 日志频道ID
+	Enabled               bool  `db:"enabled"`                 // 是否启用机器人
+	RetentionDays         int   `db:"retention_days"`          // 被阻止消息的保留天数，0 表示使用全局默认值，小于 0 表示永久保留
+	UseGlobalLists        bool  `db:"use_global_lists"`        // 是否应用全局白名单和黑名单
+	BanThreshold          int   `db:"ban_threshold"`           // 频道被阻止的消息达到该数量时自动封禁，0 表示不自动封禁
+	EscalationWindowHours int   `db:"escalation_window_hours"` // 处罚升级统计违规次数的时间窗口（小时）
+	LinkedChannelAdmin    bool  `db:"linked_channel_admin"`    // 是否将以关联频道身份发送的消息视为管理员
+	LinkedChannelID       int64 `db:"linked_channel_id"`       // 最近一次检测到的关联频道ID，0 表示没有关联频道
+	LinkedChannelAuto     bool  `db:"linked_channel_auto"`     // 关联频道变更时是否自动更新白名单，否则需要管理员确认
+
+	ObserveMode  bool      `db:"observe_mode"`  // 观察模式：只记录本应删除的消息，不删除也不提示
+	ObserveSince time.Time `db:"observe_since"` // 最近一次开启观察模式的时间
+
+	ReviewQuorum    int           `db:"review_quorum"`            // 批准申请所需的管理员票数，任何一票拒绝即拒绝申请
+	ReapplyCooldown time.Duration `db:"reapply_cooldown_seconds"` // 申请被拒绝后多久才能重新申请，0 表示不限制
+
+	UnclaimedTimeout  time.Duration `db:"unclaimed_timeout_seconds"`  // 申请多久无人认领后过期，0 表示不过期
+	UnreviewedTimeout time.Duration `db:"unreviewed_timeout_seconds"` // 申请认领后多久未审核则过期，0 表示不过期
+
+	OwnershipPolicy OwnershipPolicy `db:"ownership_policy"` // 认领申请时需要通过的频道所有权验证，为空表示认领人确认即可
+}
+
+// EffectiveRetentionDays 计算实际生效的保留天数，小于 0 表示永久保留
+func EffectiveRetentionDays(groupDays, defaultDays int) int {
+	if groupDays != 0 {
+		return groupDays
+	}
+	return defaultDays
+}
+
+// 频道所有权的验证方式
+const (
+	OwnershipAdmin = "admin" // 认领人是频道的管理员
+	OwnershipCode  = "code"  // 频道在群组中发送一次性验证码
+	OwnershipBot   = "bot"   // 认领人将机器人添加到频道
+)
+
+// OwnershipMethods 全部所有权验证方式，按显示顺序排列
+var OwnershipMethods = []string{OwnershipAdmin, OwnershipCode, OwnershipBot}
+
+// OwnershipPolicy 群组要求的频道所有权验证方式，需要全部通过
+type OwnershipPolicy []string
+
+// ParseOwnershipPolicy 解析以逗号分隔的验证方式，忽略无法识别的方式，结果按 OwnershipMethods 的顺序排列
+func ParseOwnershipPolicy(value string) OwnershipPolicy {
+	methods := make(map[string]bool)
+	for _, method := range strings.Split(value, ",") {
+		methods[strings.TrimSpace(method)] = true
+	}
+
+	var policy OwnershipPolicy
+	for _, method := range OwnershipMethods {
+		if methods[method] {
+			policy = append(policy, method)
+		}
+	}
+	return policy
+}
+
+// Has 判断是否要求指定的验证方式
+func (p OwnershipPolicy) Has(method string) bool {
+	for _, m := range p {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// String 返回以逗号分隔的验证方式，用于存储
+func (p OwnershipPolicy) String() string {
+	return strings.Join(p, ",")
+}
+
+// ApplicationExpiresAt 计算待处理申请按群组设置的过期时间：未认领的申请从申请时开始计时，
+// 已认领的申请从认领时开始计时，对应的超时时间未设置时返回零值
+func ApplicationExpiresAt(settings GroupSettings, app ChannelApplication) time.Time {
+	if app.UserID == 0 {
+		if settings.UnclaimedTimeout <= 0 {
+			return time.Time{}
+		}
+		return app.AppliedAt.Add(settings.UnclaimedTimeout)
+	}
+
+	if settings.UnreviewedTimeout <= 0 {
+		return time.Time{}
+	}
+	// 旧版本认领的申请没有认领时间，从申请时开始计时
+	since := app.ClaimedAt
+	if since.IsZero() {
+		since = app.AppliedAt
+	}
+	return since.Add(settings.UnreviewedTimeout)
+}
+
+// SenderChatBan 记录群组中被封禁的频道
+type SenderChatBan struct {
+	ID        int64     `db:"id"`
+	ChatID    int64     `db:"chat_id"`    // 群组ID
+	ChannelID int64     `db:"channel_id"` // 频道ID
+	BannedBy  int64     `db:"banned_by"`  // 封禁者ID，0 表示机器人自动封禁
+	BannedAt  time.Time `db:"banned_at"`  // 封禁时间
+	Reason    string    `db:"reason"`     // 封禁原因
+	ExpiresAt time.Time `db:"expires_at"` // 解封时间，零值表示永久封禁
+}
+
+// IsTemporary 判断封禁是否设置了解封时间
+func (b SenderChatBan) IsTemporary() bool {
+	return !b.ExpiresAt.IsZero()
+}
+
+// 处罚升级的处理方式
+const (
+	EscalationWarn   = "warn"   // 删除消息并发送警告
+	EscalationDelete = "delete" // 静默删除消息
+	EscalationBan    = "ban"    // 删除消息并封禁频道
+)
+
+// EscalationStep 群组处罚升级规则中的一级：时间窗口内第 Threshold 次违规起采用 Action
+type EscalationStep struct {
+	ChatID    int64         `db:"chat_id"`          // 群组ID
+	Threshold int           `db:"threshold"`        // 触发的违规次数
+	Action    string        `db:"action"`           // 处理方式：warn, delete, ban
+	Duration  time.Duration `db:"duration_seconds"` // 封禁时长，0 表示永久封禁，仅 ban 使用
+}
+
+// MatchEscalationStep 返回违规次数 count 适用的规则，即触发次数不超过 count 的最高一级。
+// steps 需按触发次数升序排列，没有适用的规则时返回 false
+func MatchEscalationStep(steps []EscalationStep, count int) (EscalationStep, bool) {
+	var matched EscalationStep
+	found := false
+	for _, step := range steps {
+		if step.Threshold > count {
+			break
+		}
+		matched = step
+		found = true
+	}
+	return matched, found
 }
 
 // ChannelApplication 存储频道申请信息
@@ -48,8 +300,89 @@ type ChannelApplication struct {
 	UserID          int64     `db:"user_id"`          // 申请用户ID
 	Reason          string    `db:"reason"`           // 申请理由
 	AppliedAt       time.Time `db:"applied_at"`       // 申请时间
-	Status          string    `db:"status"`           // 状态：pending, approved, rejected
+	Status          string    `db:"status"`           // 状态：pending, approved, rejected, expired
 	VerifiedChannel bool      `db:"verified_channel"` // 是否已验证频道所有权
 	LastPromptDate  time.Time `db:"last_prompt_date"` // 最后一次提示日期
 	PromptedToday   bool      `db:"prompted_today"`   // 今日是否已提示过
+	ReviewerID      int64     `db:"reviewer_id"`      // 审核人ID，未审核时为 0
+	DecidedAt       time.Time `db:"decided_at"`       // 审核时间，未审核时为零值
+	DecisionNote    string    `db:"decision_note"`    // 审核备注
+	ClaimantID      int64     `db:"claimant_id"`      // 审核时的认领人ID
+	ClaimedAt       time.Time `db:"claimed_at"`       // 认领时间，未认领时为零值
+}
+
+// 管理员对申请的投票
+const (
+	VoteApprove = "approve" // 批准
+	VoteReject  = "reject"  // 拒绝
+)
+
+// ApplicationVote 管理员对频道申请的投票，每位管理员对同一申请只保留最后一票
+type ApplicationVote struct {
+	ApplicationID int64     `db:"application_id"`
+	VoterID       int64     `db:"voter_id"` // 投票的管理员ID
+	Vote          string    `db:"vote"`     // approve 或 reject
+	Note          string    `db:"note"`     // 投票时附带的审核备注
+	VotedAt       time.Time `db:"voted_at"`
+}
+
+// ApplicationNotice 发送给管理员的申请通知消息，用于更新投票进度
+type ApplicationNotice struct {
+	ApplicationID int64 `db:"application_id"`
+	ChatID        int64 `db:"chat_id"`    // 接收通知的私聊ID
+	MessageID     int   `db:"message_id"` // 通知消息ID
+}
+
+// OwnershipCheck 认领人对申请进行的一项频道所有权验证
+type OwnershipCheck struct {
+	ApplicationID int64     `db:"application_id"`
+	Method        string    `db:"method"`    // 验证方式
+	Code          string    `db:"code"`      // 一次性验证码，只用于 code 方式
+	PassedAt      time.Time `db:"passed_at"` // 通过验证的时间，未通过时为零值
+}
+
+// Passed 判断是否已通过验证
+func (c OwnershipCheck) Passed() bool {
+	return !c.PassedAt.IsZero()
+}
+
+// 审计日志的操作类型
+const (
+	AuditActionWhitelistAdd       = "whitelist_add"       // 添加白名单
+	AuditActionWhitelistRemove    = "whitelist_remove"    // 移除白名单
+	AuditActionEnable             = "enable"              // 启用机器人
+	AuditActionDisable            = "disable"             // 禁用机器人
+	AuditActionSettingsUpdate     = "settings_update"     // 修改群组设置
+	AuditActionApplicationApprove = "application_approve" // 批准申请
+	AuditActionApplicationReject  = "application_reject"  // 拒绝申请
+	AuditActionApplicationExpire  = "application_expire"  // 申请过期
+	AuditActionImport             = "import"              // 导入群组配置
+	AuditActionSenderBan          = "sender_ban"          // 封禁频道
+	AuditActionSenderUnban        = "sender_unban"        // 解除频道封禁
+	AuditActionSetSubscribe       = "set_subscribe"       // 订阅共享白名单
+	AuditActionSetUnsubscribe     = "set_unsubscribe"     // 取消订阅共享白名单
+	AuditActionQuotaUpdate        = "quota_update"        // 修改频道发言配额
+	AuditActionPolicyUpdate       = "policy_update"       // 修改频道内容限制
+	AuditActionGlobalListAdd      = "global_list_add"     // 添加到全局名单
+	AuditActionGlobalListRemove   = "global_list_remove"  // 从全局名单移除
+	AuditActionObserveWarn        = "observe_warn"        // 观察模式下本应警告频道
+	AuditActionObserveBan         = "observe_ban"         // 观察模式下本应封禁频道
+)
+
+// AuditEntry 记录一次管理操作
+type AuditEntry struct {
+	ID          int64     `db:"id"`
+	ChatID      int64     `db:"chat_id"`      // 群组ID
+	ActorID     int64     `db:"actor_id"`     // 操作者ID，0 表示机器人自动操作
+	Action      string    `db:"action"`       // 操作类型
+	TargetID    int64     `db:"target_id"`    // 操作对象ID，通常为频道ID
+	BeforeValue string    `db:"before_value"` // 操作前的值
+	AfterValue  string    `db:"after_value"`  // 操作后的值
+	CreatedAt   time.Time `db:"created_at"`   // 操作时间
+}
+
+// AuditFilter 审计日志的查询条件，零值表示不过滤
+type AuditFilter struct {
+	Action   string // 操作类型
+	TargetID int64  // 操作对象ID
 }
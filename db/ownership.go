@@ -0,0 +1,54 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// AddOwnershipCheck 为申请添加一项所有权验证，已存在时保留原有的验证码和验证结果
+func (db *DB) AddOwnershipCheck(check models.OwnershipCheck) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO ownership_checks (application_id, method, code, passed_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(application_id, method) DO NOTHING
+	`, check.ApplicationID, check.Method, check.Code, nullTime(check.PassedAt))
+	return err
+}
+
+// GetOwnershipChecks 获取申请的全部所有权验证
+func (db *DB) GetOwnershipChecks(applicationID int64) ([]models.OwnershipCheck, error) {
+	rows, err := db.conn.Query(`
+		SELECT application_id, method, code, passed_at
+		FROM ownership_checks
+		WHERE application_id = ?
+		ORDER BY method
+	`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []models.OwnershipCheck
+	for rows.Next() {
+		var check models.OwnershipCheck
+		var passedAt sql.NullTime
+		if err := rows.Scan(&check.ApplicationID, &check.Method, &check.Code, &passedAt); err != nil {
+			return nil, err
+		}
+		check.PassedAt = passedAt.Time
+		checks = append(checks, check)
+	}
+	return checks, rows.Err()
+}
+
+// PassOwnershipCheck 将申请的一项所有权验证标记为已通过
+func (db *DB) PassOwnershipCheck(applicationID int64, method string) error {
+	_, err := db.conn.Exec(`
+		UPDATE ownership_checks
+		SET passed_at = ?
+		WHERE application_id = ? AND method = ? AND passed_at IS NULL
+	`, time.Now(), applicationID, method)
+	return err
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresInitialSchema PostgreSQL 的初始表结构，与 SQLite 的初始结构及其后续补丁保持一致
+var postgresInitialSchema = []string{
+	// 白名单频道表
+	`CREATE TABLE IF NOT EXISTS whitelisted_channels (
+		id BIGSERIAL PRIMARY KEY,
+		chat_id BIGINT NOT NULL,
+		channel_id BIGINT NOT NULL,
+		added_by BIGINT NOT NULL,
+		added_at TIMESTAMP NOT NULL,
+		description TEXT,
+		UNIQUE(chat_id, channel_id)
+	)`,
+	// 被阻止的消息表
+	`CREATE TABLE IF NOT EXISTS blocked_messages (
+		id BIGSERIAL PRIMARY KEY,
+		chat_id BIGINT NOT NULL,
+		channel_id BIGINT NOT NULL,
+		message_id INTEGER NOT NULL,
+		blocked_at TIMESTAMP NOT NULL,
+		message_text TEXT
+	)`,
+	// 群组设置表
+	`CREATE TABLE IF NOT EXISTS group_settings (
+		chat_id BIGINT PRIMARY KEY,
+		admin_only BOOLEAN NOT NULL DEFAULT TRUE,
+		log_channel_id BIGINT NOT NULL DEFAULT 0,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE
+	)`,
+	// 频道申请表
+	`CREATE TABLE IF NOT EXISTS channel_applications (
+		id BIGSERIAL PRIMARY KEY,
+		chat_id BIGINT NOT NULL,
+		channel_id BIGINT NOT NULL,
+		user_id BIGINT NOT NULL,
+		reason TEXT,
+		applied_at TIMESTAMP NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		verified_channel BOOLEAN NOT NULL DEFAULT FALSE,
+		last_prompt_date DATE,
+		prompted_today BOOLEAN NOT NULL DEFAULT FALSE,
+		UNIQUE(chat_id, channel_id, user_id)
+	)`,
+	// 用户状态表
+	`CREATE TABLE IF NOT EXISTS user_states (
+		user_id BIGINT PRIMARY KEY,
+		state TEXT,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// 每日频道提示记录表
+	`CREATE TABLE IF NOT EXISTS channel_daily_prompts (
+		id BIGSERIAL PRIMARY KEY,
+		chat_id BIGINT NOT NULL,
+		channel_id BIGINT NOT NULL,
+		prompt_type TEXT NOT NULL,
+		prompt_date DATE NOT NULL,
+		UNIQUE(chat_id, channel_id, prompt_type, prompt_date)
+	)`,
+}
+
+// NewPostgres 创建一个新的 PostgreSQL 数据库连接，并执行所有未执行的结构迁移
+func NewPostgres(dsn string) (*DB, error) {
+	db, err := OpenPostgres(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenPostgres 打开 PostgreSQL 数据库连接但不执行迁移
+func OpenPostgres(dsn string) (*DB, error) {
+	sqlDB, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// 多个机器人进程共享同一个数据库，使用适中的连接池
+	sqlDB.SetMaxOpenConns(10)
+	sqlDB.SetMaxIdleConns(5)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	return &DB{conn: &conn{DB: sqlDB, dialect: dialectPostgres}}, nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// GetWhitelistEntry 获取群组白名单中的频道条目，不存在时返回 ID 为 0 的条目。
+// 只查询群组自己的白名单，不包括共享白名单和全局白名单
+func (db *DB) GetWhitelistEntry(chatID, channelID int64) (models.WhitelistedChannel, error) {
+	channel, err := scanWhitelistedChannel(db.conn.QueryRow(`
+		SELECT `+whitelistColumns+`
+		FROM whitelisted_channels
+		WHERE chat_id = ? AND channel_id = ?
+	`, chatID, channelID))
+	if err == sql.ErrNoRows {
+		return models.WhitelistedChannel{}, nil
+	}
+	return channel, err
+}
+
+// SetChannelQuota 设置白名单频道的发言配额，posts 为 0 时取消配额
+func (db *DB) SetChannelQuota(chatID, channelID int64, posts int, window time.Duration) error {
+	_, err := db.conn.Exec(`
+		UPDATE whitelisted_channels
+		SET quota_posts = ?, quota_window_seconds = ?
+		WHERE chat_id = ? AND channel_id = ?
+	`, posts, int64(window/time.Second), chatID, channelID)
+	return err
+}
+
+// SetChannelContentPolicy 设置白名单频道的内容限制，policy 为空时取消限制
+func (db *DB) SetChannelContentPolicy(chatID, channelID int64, policy models.ContentPolicy) error {
+	_, err := db.conn.Exec(`
+		UPDATE whitelisted_channels
+		SET content_policy = ?
+		WHERE chat_id = ? AND channel_id = ?
+	`, policy.String(), chatID, channelID)
+	return err
+}
+
+// RecordChannelPost 记录设置了发言配额的频道发送的一条消息
+func (db *DB) RecordChannelPost(chatID, channelID int64, postedAt time.Time) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO channel_posts (chat_id, channel_id, posted_at)
+		VALUES (?, ?, ?)
+	`, chatID, channelID, postedAt)
+	return err
+}
+
+// CountChannelPostsSince 统计频道在群组中自 since 以来发送的消息数量
+func (db *DB) CountChannelPostsSince(chatID, channelID int64, since time.Time) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM channel_posts
+		WHERE chat_id = ? AND channel_id = ? AND posted_at >= ?
+	`, chatID, channelID, since).Scan(&count)
+	return count, err
+}
+
+// PruneChannelPosts 删除 before 之前的发言记录，返回删除的行数
+func (db *DB) PruneChannelPosts(before time.Time) (int64, error) {
+	result, err := db.conn.Exec(`DELETE FROM channel_posts WHERE posted_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
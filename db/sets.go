@@ -0,0 +1,169 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// whitelistSetColumns 共享白名单表的查询列
+const whitelistSetColumns = `id, name, owner_id, description, created_at`
+
+// CreateWhitelistSet 创建共享白名单并返回创建的记录
+func (db *DB) CreateWhitelistSet(name string, ownerID int64, description string) (models.WhitelistSet, error) {
+	_, err := db.conn.Exec(`
+		INSERT INTO whitelist_sets (name, owner_id, description, created_at)
+		VALUES (?, ?, ?, ?)
+	`, name, ownerID, description, time.Now())
+	if err != nil {
+		return models.WhitelistSet{}, err
+	}
+	return db.GetWhitelistSet(name)
+}
+
+// GetWhitelistSet 按名称获取共享白名单，不存在时返回 ID 为 0 的零值
+func (db *DB) GetWhitelistSet(name string) (models.WhitelistSet, error) {
+	var set models.WhitelistSet
+	err := db.conn.QueryRow(`SELECT `+whitelistSetColumns+` FROM whitelist_sets WHERE name = ?`, name).
+		Scan(&set.ID, &set.Name, &set.OwnerID, &set.Description, &set.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.WhitelistSet{}, nil
+	}
+	return set, err
+}
+
+// GetWhitelistSets 获取 ownerID 拥有的共享白名单，ownerID 为 0 时返回全部，按名称排序
+func (db *DB) GetWhitelistSets(ownerID int64) ([]models.WhitelistSet, error) {
+	if ownerID == 0 {
+		return db.queryWhitelistSets(`SELECT ` + whitelistSetColumns + ` FROM whitelist_sets ORDER BY name`)
+	}
+	return db.queryWhitelistSets(`SELECT `+whitelistSetColumns+` FROM whitelist_sets WHERE owner_id = ? ORDER BY name`, ownerID)
+}
+
+// DeleteWhitelistSet 删除共享白名单及其频道和全部订阅
+func (db *DB) DeleteWhitelistSet(setID int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DELETE FROM whitelist_set_subscriptions WHERE set_id = ?`,
+		`DELETE FROM whitelist_set_channels WHERE set_id = ?`,
+		`DELETE FROM whitelist_sets WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, setID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// AddChannelToSet 将频道加入共享白名单，已存在时保持不变
+func (db *DB) AddChannelToSet(setID, channelID, addedBy int64) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO whitelist_set_channels (set_id, channel_id, added_by, added_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(set_id, channel_id) DO NOTHING
+	`, setID, channelID, addedBy, time.Now())
+	return err
+}
+
+// RemoveChannelFromSet 将频道从共享白名单中移除
+func (db *DB) RemoveChannelFromSet(setID, channelID int64) error {
+	_, err := db.conn.Exec(`
+		DELETE FROM whitelist_set_channels
+		WHERE set_id = ? AND channel_id = ?
+	`, setID, channelID)
+	return err
+}
+
+// GetSetChannels 获取共享白名单中的全部频道，按添加时间倒序
+func (db *DB) GetSetChannels(setID int64) ([]models.WhitelistSetChannel, error) {
+	rows, err := db.conn.Query(`
+		SELECT set_id, channel_id, added_by, added_at
+		FROM whitelist_set_channels
+		WHERE set_id = ?
+		ORDER BY added_at DESC
+	`, setID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []models.WhitelistSetChannel
+	for rows.Next() {
+		var channel models.WhitelistSetChannel
+		if err := rows.Scan(&channel.SetID, &channel.ChannelID, &channel.AddedBy, &channel.AddedAt); err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+
+	return channels, rows.Err()
+}
+
+// SubscribeToSet 群组订阅共享白名单，已订阅时保持不变
+func (db *DB) SubscribeToSet(chatID, setID, subscribedBy int64) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO whitelist_set_subscriptions (chat_id, set_id, subscribed_by, subscribed_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id, set_id) DO NOTHING
+	`, chatID, setID, subscribedBy, time.Now())
+	return err
+}
+
+// UnsubscribeFromSet 群组取消订阅共享白名单
+func (db *DB) UnsubscribeFromSet(chatID, setID int64) error {
+	_, err := db.conn.Exec(`
+		DELETE FROM whitelist_set_subscriptions
+		WHERE chat_id = ? AND set_id = ?
+	`, chatID, setID)
+	return err
+}
+
+// GetSubscribedSets 获取群组订阅的共享白名单，按名称排序
+func (db *DB) GetSubscribedSets(chatID int64) ([]models.WhitelistSet, error) {
+	return db.queryWhitelistSets(`
+		SELECT s.id, s.name, s.owner_id, s.description, s.created_at
+		FROM whitelist_sets s
+		JOIN whitelist_set_subscriptions sub ON sub.set_id = s.id
+		WHERE sub.chat_id = ?
+		ORDER BY s.name
+	`, chatID)
+}
+
+// GetSubscribedSetsContaining 获取群组订阅的共享白名单中包含该频道的名单，按名称排序
+func (db *DB) GetSubscribedSetsContaining(chatID, channelID int64) ([]models.WhitelistSet, error) {
+	return db.queryWhitelistSets(`
+		SELECT s.id, s.name, s.owner_id, s.description, s.created_at
+		FROM whitelist_sets s
+		JOIN whitelist_set_subscriptions sub ON sub.set_id = s.id
+		JOIN whitelist_set_channels c ON c.set_id = s.id
+		WHERE sub.chat_id = ? AND c.channel_id = ?
+		ORDER BY s.name
+	`, chatID, channelID)
+}
+
+// queryWhitelistSets 执行查询并扫描共享白名单
+func (db *DB) queryWhitelistSets(query string, args ...interface{}) ([]models.WhitelistSet, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sets []models.WhitelistSet
+	for rows.Next() {
+		var set models.WhitelistSet
+		if err := rows.Scan(&set.ID, &set.Name, &set.OwnerID, &set.Description, &set.CreatedAt); err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+
+	return sets, rows.Err()
+}
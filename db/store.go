@@ -0,0 +1,123 @@
+package db
+
+import (
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// Store 定义机器人所需的全部存储操作，不同的数据库后端各自实现
+type Store interface {
+	// 白名单
+	AddChannelToWhitelist(chatID, channelID, addedBy int64, username, description string, expiresAt time.Time) error
+	RemoveChannelFromWhitelist(chatID, channelID int64) error
+	IsChannelWhitelisted(chatID, channelID int64) (bool, error)
+	GetWhitelistedChannels(chatID int64) ([]models.WhitelistedChannel, error)
+	RemoveExpiredWhitelistEntries(now time.Time) ([]models.WhitelistedChannel, error)
+	GetWhitelistEntry(chatID, channelID int64) (models.WhitelistedChannel, error)
+
+	// 发言配额和内容限制
+	SetChannelQuota(chatID, channelID int64, posts int, window time.Duration) error
+	SetChannelContentPolicy(chatID, channelID int64, policy models.ContentPolicy) error
+	RecordChannelPost(chatID, channelID int64, postedAt time.Time) error
+	CountChannelPostsSince(chatID, channelID int64, since time.Time) (int, error)
+	PruneChannelPosts(before time.Time) (int64, error)
+
+	// 共享白名单
+	CreateWhitelistSet(name string, ownerID int64, description string) (models.WhitelistSet, error)
+	GetWhitelistSet(name string) (models.WhitelistSet, error)
+	GetWhitelistSets(ownerID int64) ([]models.WhitelistSet, error)
+	DeleteWhitelistSet(setID int64) error
+	AddChannelToSet(setID, channelID, addedBy int64) error
+	RemoveChannelFromSet(setID, channelID int64) error
+	GetSetChannels(setID int64) ([]models.WhitelistSetChannel, error)
+	SubscribeToSet(chatID, setID, subscribedBy int64) error
+	UnsubscribeFromSet(chatID, setID int64) error
+	GetSubscribedSets(chatID int64) ([]models.WhitelistSet, error)
+	GetSubscribedSetsContaining(chatID, channelID int64) ([]models.WhitelistSet, error)
+
+	// 全局白名单和黑名单
+	SetGlobalListEntry(listType string, channelID, addedBy int64, description string) error
+	RemoveGlobalListEntry(listType string, channelID int64) error
+	GetGlobalListType(channelID int64) (string, error)
+	GetGlobalListEntries(listType string) ([]models.GlobalListEntry, error)
+
+	// 被阻止的消息
+	LogBlockedMessage(chatID, channelID int64, messageID int, messageText, reason string, observed bool) error
+	LogBlockedMessagesBatch(messages []models.BlockedMessageInfo) error
+	GetBlockedMessagesStats(chatID int64) (int, error)
+	CountBlockedMessagesSince(chatID, channelID int64, since time.Time) (int, error)
+	CountObservedMessagesSince(chatID, channelID int64, since time.Time) (int, error)
+	GetObservedMessageStats(chatID int64, since time.Time) ([]models.ObservedMessageStat, error)
+	PruneBlockedMessages(defaultRetentionDays, batchSize int) (int64, error)
+	Vacuum() error
+
+	// 被封禁的频道
+	AddSenderChatBan(chatID, channelID, bannedBy int64, reason string, expiresAt time.Time) error
+	RemoveSenderChatBan(chatID, channelID int64) error
+	GetSenderChatUnbanTime(chatID, channelID int64) (time.Time, error)
+	IsSenderChatBanned(chatID, channelID int64) (bool, error)
+	GetSenderChatBans(chatID int64) ([]models.SenderChatBan, error)
+	GetExpiredSenderChatBans(now time.Time) ([]models.SenderChatBan, error)
+
+	// 处罚升级规则
+	GetEscalationSteps(chatID int64) ([]models.EscalationStep, error)
+	SetEscalationSteps(chatID int64, steps []models.EscalationStep) error
+
+	// 群组设置
+	GetOrCreateGroupSettings(chatID int64) (models.GroupSettings, error)
+	UpdateGroupSettings(settings models.GroupSettings) error
+	UpdateLinkedChannelID(chatID, linkedChannelID int64) error
+	GetAllGroupSettings() ([]models.GroupSettings, error)
+
+	// 频道申请
+	CreateChannelApplication(chatID, channelID, userID int64, reason string) error
+	GetReapplyTime(chatID, channelID int64) (time.Time, error)
+	GetChannelApplication(chatID, channelID, userID int64) (models.ChannelApplication, error)
+	ReviewChannelApplication(applicationID int64, status string, reviewerID int64, note string) error
+	ExpireStaleApplications(now time.Time) ([]models.ChannelApplication, error)
+	GetChannelApplicationHistory(chatID, channelID int64) ([]models.ChannelApplication, error)
+	VerifyChannelOwnership(chatID, channelID, userID int64) error
+	UpdateLastPromptDate(chatID, channelID int64) error
+	GetPendingApplications() ([]models.ChannelApplication, error)
+	GetPendingApplicationsPage(chatID int64, limit, offset int) ([]models.ChannelApplication, int, error)
+	GetChannelApplicationByID(applicationID int64) (models.ChannelApplication, error)
+	RecordApplicationVote(vote models.ApplicationVote) error
+	GetApplicationVotes(applicationID int64) ([]models.ApplicationVote, error)
+	AddApplicationNotice(notice models.ApplicationNotice) error
+	GetApplicationNotices(applicationID int64) ([]models.ApplicationNotice, error)
+	AddOwnershipCheck(check models.OwnershipCheck) error
+	GetOwnershipChecks(applicationID int64) ([]models.OwnershipCheck, error)
+	PassOwnershipCheck(applicationID int64, method string) error
+	GetChannelApplicationByDate(chatID, channelID int64, date string) (bool, error)
+	UpdateChannelApplicationUser(chatID, channelID, userID int64) error
+	UpdateChannelApplicationReason(chatID, channelID int64, reason string) error
+	GetPendingChannelApplication(chatID, channelID int64) (models.ChannelApplication, error)
+	HasPendingApplication(chatID, channelID int64) (bool, error)
+
+	// 用户状态
+	SetUserState(userID int64, state string) error
+	GetUserState(userID int64) (string, error)
+	ClearUserState(userID int64) error
+	ClearUserStatesByValue(state string) error
+
+	// 每日提示
+	HasChannelDailyPrompt(chatID, channelID int64, promptType string) (bool, error)
+	RecordChannelDailyPrompt(chatID, channelID int64, promptType string) error
+	HasPromptedToday(chatID, channelID int64) (bool, error)
+	HasPendingNoticeToday(chatID, channelID int64) (bool, error)
+	RecordPrompt(chatID, channelID int64) error
+	RecordPendingNotice(chatID, channelID int64) error
+	ResetDailyPrompts() error
+
+	// 审计日志
+	AddAuditLog(entry models.AuditEntry) error
+	GetAuditLogs(chatID int64, filter models.AuditFilter, limit, offset int) ([]models.AuditEntry, int, error)
+
+	// 维护
+	Backup(path string) error
+	Close() error
+}
+
+// 确保 DB 实现了 Store 接口
+var _ Store = (*DB)(nil)
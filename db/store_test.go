@@ -0,0 +1,369 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// forEachStore 对 MemoryStore 和 SQLite 数据库分别执行同一组断言，确保两种实现的行为一致
+func forEachStore(t *testing.T, fn func(t *testing.T, s Store)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		database, err := New(filepath.Join(t.TempDir(), "bot.db"))
+		if err != nil {
+			t.Fatalf("打开数据库失败: %v", err)
+		}
+		defer database.Close()
+		fn(t, database)
+	})
+}
+
+// expectError 断言 err 不为空且包含 want
+func expectError(t *testing.T, err error, want string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("期望错误「%s」，实际没有错误", want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("期望错误包含「%s」，实际为「%s」", want, err.Error())
+	}
+}
+
+func TestPendingApplicationUnique(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		const chatID, channelID = -100, -1001
+
+		if err := s.CreateChannelApplication(chatID, channelID, 0, "理由"); err != nil {
+			t.Fatalf("创建申请失败: %v", err)
+		}
+		expectError(t, s.CreateChannelApplication(chatID, channelID, 0, "理由"), "已有待审核的申请")
+
+		// 其他群组的同一频道不受影响
+		if err := s.CreateChannelApplication(chatID-1, channelID, 0, "理由"); err != nil {
+			t.Fatalf("在其他群组创建申请失败: %v", err)
+		}
+
+		// 申请处理后可以再次申请
+		app, err := s.GetPendingChannelApplication(chatID, channelID)
+		if err != nil || app.ID == 0 {
+			t.Fatalf("获取待处理申请失败: %v", err)
+		}
+		if err := s.ReviewChannelApplication(app.ID, "approved", 1, ""); err != nil {
+			t.Fatalf("审核申请失败: %v", err)
+		}
+		if err := s.CreateChannelApplication(chatID, channelID, 0, "理由"); err != nil {
+			t.Fatalf("审核后再次申请失败: %v", err)
+		}
+	})
+}
+
+func TestClaimedApplication(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		const chatID, channelID = -100, -1001
+
+		if err := s.CreateChannelApplication(chatID, channelID, 0, "理由"); err != nil {
+			t.Fatalf("创建申请失败: %v", err)
+		}
+		if err := s.UpdateChannelApplicationUser(chatID, channelID, 7); err != nil {
+			t.Fatalf("认领申请失败: %v", err)
+		}
+		// 同一用户重复认领不报错
+		if err := s.UpdateChannelApplicationUser(chatID, channelID, 7); err != nil {
+			t.Fatalf("重复认领申请失败: %v", err)
+		}
+
+		app, err := s.GetPendingChannelApplication(chatID, channelID)
+		if err != nil {
+			t.Fatalf("获取待处理申请失败: %v", err)
+		}
+		if app.UserID != 7 || app.ClaimedAt.IsZero() {
+			t.Fatalf("认领人为 %d，认领时间为 %v，期望认领人 7 且记录认领时间", app.UserID, app.ClaimedAt)
+		}
+	})
+}
+
+func TestUnverifiedClaimReplaced(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		const chatID, channelID = -100, -1001
+
+		if err := s.CreateChannelApplication(chatID, channelID, 0, "理由"); err != nil {
+			t.Fatalf("创建申请失败: %v", err)
+		}
+		if err := s.UpdateChannelApplicationUser(chatID, channelID, 7); err != nil {
+			t.Fatalf("认领申请失败: %v", err)
+		}
+		app, err := s.GetPendingChannelApplication(chatID, channelID)
+		if err != nil {
+			t.Fatalf("获取待处理申请失败: %v", err)
+		}
+		if err := s.AddOwnershipCheck(models.OwnershipCheck{ApplicationID: app.ID, Method: models.OwnershipCode, Code: "ABCD2345"}); err != nil {
+			t.Fatalf("添加所有权验证失败: %v", err)
+		}
+
+		// 认领人尚未通过验证时，其他用户可以重新认领，原认领人的验证记录被清除
+		if err := s.UpdateChannelApplicationUser(chatID, channelID, 8); err != nil {
+			t.Fatalf("重新认领未验证的申请失败: %v", err)
+		}
+		if checks, err := s.GetOwnershipChecks(app.ID); err != nil || len(checks) != 0 {
+			t.Fatalf("重新认领后仍有 %d 项验证记录（%v），期望清除", len(checks), err)
+		}
+
+		// 原认领人的验证不再生效，新认领人可以完成验证
+		if err := s.VerifyChannelOwnership(chatID, channelID, 7); err != nil {
+			t.Fatalf("验证所有权失败: %v", err)
+		}
+		if app, err = s.GetPendingChannelApplication(chatID, channelID); err != nil || app.VerifiedChannel {
+			t.Fatalf("原认领人的验证标记了所有权（%v）", err)
+		}
+		if err := s.VerifyChannelOwnership(chatID, channelID, 8); err != nil {
+			t.Fatalf("验证所有权失败: %v", err)
+		}
+		if app, err = s.GetPendingChannelApplication(chatID, channelID); err != nil || app.UserID != 8 || !app.VerifiedChannel {
+			t.Fatalf("认领人为 %d，已验证为 %v（%v），期望认领人 8 已通过验证", app.UserID, app.VerifiedChannel, err)
+		}
+
+		// 通过验证后不能再被其他人认领
+		expectError(t, s.UpdateChannelApplicationUser(chatID, channelID, 9), "已被认领")
+	})
+}
+
+func TestReviewApplicationOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		const chatID, channelID = -100, -1001
+
+		if err := s.CreateChannelApplication(chatID, channelID, 7, "理由"); err != nil {
+			t.Fatalf("创建申请失败: %v", err)
+		}
+		app, err := s.GetPendingChannelApplication(chatID, channelID)
+		if err != nil {
+			t.Fatalf("获取待处理申请失败: %v", err)
+		}
+		if err := s.ReviewChannelApplication(app.ID, "approved", 1, ""); err != nil {
+			t.Fatalf("批准申请失败: %v", err)
+		}
+		// 另一个进程同时达到拒绝条件时，后到的审核不能覆盖已作出的决定
+		expectError(t, s.ReviewChannelApplication(app.ID, "rejected", 2, ""), "已被处理")
+
+		app, err = s.GetChannelApplicationByID(app.ID)
+		if err != nil {
+			t.Fatalf("获取申请失败: %v", err)
+		}
+		if app.Status != "approved" || app.ReviewerID != 1 {
+			t.Fatalf("申请状态为 %s，审核人为 %d，期望保持第一次审核的结果", app.Status, app.ReviewerID)
+		}
+	})
+}
+
+func TestReapplyCooldown(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		const chatID, channelID = -100, -1001
+
+		settings, err := s.GetOrCreateGroupSettings(chatID)
+		if err != nil {
+			t.Fatalf("获取群组设置失败: %v", err)
+		}
+		settings.ReapplyCooldown = 2 * time.Hour
+		if err := s.UpdateGroupSettings(settings); err != nil {
+			t.Fatalf("更新群组设置失败: %v", err)
+		}
+
+		if err := s.CreateChannelApplication(chatID, channelID, 0, "理由"); err != nil {
+			t.Fatalf("创建申请失败: %v", err)
+		}
+		app, err := s.GetPendingChannelApplication(chatID, channelID)
+		if err != nil {
+			t.Fatalf("获取待处理申请失败: %v", err)
+		}
+		if err := s.ReviewChannelApplication(app.ID, "rejected", 1, "不符合要求"); err != nil {
+			t.Fatalf("拒绝申请失败: %v", err)
+		}
+
+		reapplyAt, err := s.GetReapplyTime(chatID, channelID)
+		if err != nil {
+			t.Fatalf("获取重新申请时间失败: %v", err)
+		}
+		if wait := time.Until(reapplyAt); wait < time.Hour || wait > 2*time.Hour {
+			t.Fatalf("重新申请还需等待 %v，期望约 2 小时", wait)
+		}
+		expectError(t, s.CreateChannelApplication(chatID, channelID, 0, "理由"), "之后才能再次申请")
+
+		// 取消冷却后可以立即重新申请
+		settings.ReapplyCooldown = 0
+		if err := s.UpdateGroupSettings(settings); err != nil {
+			t.Fatalf("更新群组设置失败: %v", err)
+		}
+		if err := s.CreateChannelApplication(chatID, channelID, 0, "理由"); err != nil {
+			t.Fatalf("取消冷却后重新申请失败: %v", err)
+		}
+	})
+}
+
+func TestUpdateLinkedChannelID(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		const chatID = -100
+
+		stale, err := s.GetOrCreateGroupSettings(chatID)
+		if err != nil {
+			t.Fatalf("获取群组设置失败: %v", err)
+		}
+
+		// 管理员在关联频道检查期间修改了设置
+		updated := stale
+		updated.BanThreshold = 5
+		updated.ObserveMode = true
+		if err := s.UpdateGroupSettings(updated); err != nil {
+			t.Fatalf("更新群组设置失败: %v", err)
+		}
+
+		if err := s.UpdateLinkedChannelID(stale.ChatID, -1001); err != nil {
+			t.Fatalf("更新关联频道失败: %v", err)
+		}
+
+		settings, err := s.GetOrCreateGroupSettings(chatID)
+		if err != nil {
+			t.Fatalf("获取群组设置失败: %v", err)
+		}
+		if settings.LinkedChannelID != -1001 {
+			t.Fatalf("关联频道为 %d，期望 -1001", settings.LinkedChannelID)
+		}
+		if settings.BanThreshold != 5 || !settings.ObserveMode {
+			t.Fatalf("更新关联频道覆盖了其他设置: 自动封禁 %d，观察模式 %v", settings.BanThreshold, settings.ObserveMode)
+		}
+	})
+}
+
+func TestCountObservedMessagesSince(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		const chatID, channelID = -100, -1001
+
+		since := time.Now().Add(-time.Minute)
+		records := []struct {
+			reason   string
+			observed bool
+		}{
+			{models.BlockReasonNotWhitelisted, true},
+			{models.BlockReasonBlacklisted, true},
+			{models.BlockReasonQuota, true},
+			{models.BlockReasonNotWhitelisted, false},
+			{models.BlockReasonBlacklisted, false},
+			{models.BlockReasonQuota, false},
+		}
+		for i, record := range records {
+			if err := s.LogBlockedMessage(chatID, channelID, i+1, "消息", record.reason, record.observed); err != nil {
+				t.Fatalf("记录被阻止的消息失败: %v", err)
+			}
+		}
+
+		// 只统计观察模式下不在白名单或在全局黑名单中的记录
+		observed, err := s.CountObservedMessagesSince(chatID, channelID, since)
+		if err != nil {
+			t.Fatalf("统计观察记录失败: %v", err)
+		}
+		if observed != 2 {
+			t.Fatalf("观察记录为 %d 条，期望 2 条", observed)
+		}
+
+		blocked, err := s.CountBlockedMessagesSince(chatID, channelID, since)
+		if err != nil {
+			t.Fatalf("统计被阻止的消息失败: %v", err)
+		}
+		if blocked != 2 {
+			t.Fatalf("被阻止的消息为 %d 条，期望 2 条", blocked)
+		}
+	})
+}
+
+func TestSenderChatUnbanTime(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		const chatID, channelID = -100, -1001
+
+		if err := s.AddSenderChatBan(chatID, channelID, 0, "测试", time.Time{}); err != nil {
+			t.Fatalf("封禁频道失败: %v", err)
+		}
+		if unbannedAt, err := s.GetSenderChatUnbanTime(chatID, channelID); err != nil || !unbannedAt.IsZero() {
+			t.Fatalf("封禁中的频道解封时间为 %v（%v），期望零值", unbannedAt, err)
+		}
+
+		before := time.Now().Add(-time.Second)
+		if err := s.RemoveSenderChatBan(chatID, channelID); err != nil {
+			t.Fatalf("解除封禁失败: %v", err)
+		}
+		if banned, err := s.IsSenderChatBanned(chatID, channelID); err != nil || banned {
+			t.Fatalf("解封后仍处于封禁中（%v）", err)
+		}
+		bans, err := s.GetSenderChatBans(chatID)
+		if err != nil || len(bans) != 0 {
+			t.Fatalf("解封后封禁列表有 %d 条（%v），期望为空", len(bans), err)
+		}
+		unbannedAt, err := s.GetSenderChatUnbanTime(chatID, channelID)
+		if err != nil || unbannedAt.Before(before) {
+			t.Fatalf("解封时间为 %v（%v），期望为刚才", unbannedAt, err)
+		}
+
+		// 再次封禁后清除解封时间
+		if err := s.AddSenderChatBan(chatID, channelID, 0, "测试", time.Time{}); err != nil {
+			t.Fatalf("再次封禁频道失败: %v", err)
+		}
+		if banned, err := s.IsSenderChatBanned(chatID, channelID); err != nil || !banned {
+			t.Fatalf("再次封禁后没有处于封禁中（%v）", err)
+		}
+	})
+}
+
+// racingStore 在白名单查询返回前执行 beforeReturn，模拟查询过程中其他调用写入了白名单
+type racingStore struct {
+	Store
+	beforeReturn func()
+}
+
+func (s *racingStore) IsChannelWhitelisted(chatID, channelID int64) (bool, error) {
+	whitelisted, err := s.Store.IsChannelWhitelisted(chatID, channelID)
+	if s.beforeReturn != nil {
+		hook := s.beforeReturn
+		s.beforeReturn = nil
+		hook()
+	}
+	return whitelisted, err
+}
+
+func TestCachedStoreStaleness(t *testing.T) {
+	const chatID, channelID = -100, -1001
+
+	t.Run("temporary entry", func(t *testing.T) {
+		c := NewCachedStore(NewMemoryStore(), time.Hour)
+		if err := c.AddChannelToWhitelist(chatID, channelID, 1, "", "", time.Now().Add(50*time.Millisecond)); err != nil {
+			t.Fatalf("添加临时白名单失败: %v", err)
+		}
+		if whitelisted, err := c.IsChannelWhitelisted(chatID, channelID); err != nil || !whitelisted {
+			t.Fatalf("临时白名单到期前不在白名单中（%v）", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if whitelisted, err := c.IsChannelWhitelisted(chatID, channelID); err != nil || whitelisted {
+			t.Fatalf("临时白名单到期后缓存仍返回在白名单中（%v）", err)
+		}
+	})
+
+	t.Run("invalidated during read", func(t *testing.T) {
+		racing := &racingStore{Store: NewMemoryStore()}
+		c := NewCachedStore(racing, time.Hour)
+		racing.beforeReturn = func() {
+			if err := c.AddChannelToWhitelist(chatID, channelID, 1, "", "", time.Time{}); err != nil {
+				t.Fatalf("添加白名单失败: %v", err)
+			}
+		}
+
+		// 查询开始时频道不在白名单中，查询期间被添加，过时的结果不能写入缓存
+		if whitelisted, err := c.IsChannelWhitelisted(chatID, channelID); err != nil || whitelisted {
+			t.Fatalf("第一次查询结果为 %v（%v），期望不在白名单中", whitelisted, err)
+		}
+		if whitelisted, err := c.IsChannelWhitelisted(chatID, channelID); err != nil || !whitelisted {
+			t.Fatalf("添加白名单后缓存仍返回不在白名单中（%v）", err)
+		}
+	})
+}
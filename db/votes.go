@@ -0,0 +1,81 @@
+package db
+
+import (
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// RecordApplicationVote 记录管理员对申请的投票，同一管理员再次投票时覆盖之前的投票
+func (db *DB) RecordApplicationVote(vote models.ApplicationVote) error {
+	if vote.VotedAt.IsZero() {
+		vote.VotedAt = time.Now()
+	}
+
+	_, err := db.conn.Exec(`
+		INSERT INTO application_votes (application_id, voter_id, vote, note, voted_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(application_id, voter_id) DO UPDATE SET
+		vote = ?, note = ?, voted_at = ?
+	`, vote.ApplicationID, vote.VoterID, vote.Vote, vote.Note, vote.VotedAt, vote.Vote, vote.Note, vote.VotedAt)
+	return err
+}
+
+// GetApplicationVotes 按投票先后获取申请的全部投票
+func (db *DB) GetApplicationVotes(applicationID int64) ([]models.ApplicationVote, error) {
+	rows, err := db.conn.Query(`
+		SELECT application_id, voter_id, vote, note, voted_at
+		FROM application_votes
+		WHERE application_id = ?
+		ORDER BY voted_at ASC, voter_id ASC
+	`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var votes []models.ApplicationVote
+	for rows.Next() {
+		var vote models.ApplicationVote
+		if err := rows.Scan(&vote.ApplicationID, &vote.VoterID, &vote.Vote, &vote.Note, &vote.VotedAt); err != nil {
+			return nil, err
+		}
+		votes = append(votes, vote)
+	}
+	return votes, rows.Err()
+}
+
+// AddApplicationNotice 记录发送给管理员的申请通知消息，同一私聊只保留最新的一条
+func (db *DB) AddApplicationNotice(notice models.ApplicationNotice) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO application_notices (application_id, chat_id, message_id)
+		VALUES (?, ?, ?)
+		ON CONFLICT(application_id, chat_id) DO UPDATE SET
+		message_id = ?
+	`, notice.ApplicationID, notice.ChatID, notice.MessageID, notice.MessageID)
+	return err
+}
+
+// GetApplicationNotices 获取申请的全部通知消息
+func (db *DB) GetApplicationNotices(applicationID int64) ([]models.ApplicationNotice, error) {
+	rows, err := db.conn.Query(`
+		SELECT application_id, chat_id, message_id
+		FROM application_notices
+		WHERE application_id = ?
+		ORDER BY chat_id
+	`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notices []models.ApplicationNotice
+	for rows.Next() {
+		var notice models.ApplicationNotice
+		if err := rows.Scan(&notice.ApplicationID, &notice.ChatID, &notice.MessageID); err != nil {
+			return nil, err
+		}
+		notices = append(notices, notice)
+	}
+	return notices, rows.Err()
+}
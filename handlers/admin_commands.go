@@ -2,12 +2,34 @@ package handlers
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/anhe/tg-whitelist-bot/db"
+	"github.com/anhe/tg-whitelist-bot/db/models"
 	"github.com/anhe/tg-whitelist-bot/utils"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-// HandleAddChannel 添加频道到白名单，支持回复消息或提供频道ID
+// splitDurationArg 从参数末尾取出可选的有效期，如 "-100123 7d" 或 "12h"
+func splitDurationArg(args string) (string, time.Duration, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return "", 0, nil
+	}
+
+	last := fields[len(fields)-1]
+	if !utils.IsDuration(last) {
+		return strings.Join(fields, " "), 0, nil
+	}
+
+	duration, err := utils.ParseDuration(last)
+	return strings.Join(fields[:len(fields)-1], " "), duration, err
+}
+
+// HandleAddChannel 添加频道到白名单，支持回复消息或提供频道ID，可附带有效期创建临时白名单
 func (h *Handler) HandleAddChannel(message *tgbotapi.Message, args string) error {
 	// 只在群组中工作
 	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
@@ -35,7 +57,23 @@ func (h *Handler) HandleAddChannel(message *tgbotapi.Message, args string) error
 		return err
 	}
 
+	// 解析可选的内容限制和有效期
+	args, policy, err := splitContentPolicyFlags(args)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	args, duration, err := splitDurationArg(args)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
 	var channelID int64
+	var username string
 
 	// 检查是否是回复消息
 	if message.ReplyToMessage != nil {
@@ -48,18 +86,19 @@ func (h *Handler) HandleAddChannel(message *tgbotapi.Message, args string) error
 
 		// 获取频道ID
 		channelID = utils.GetChannelID(message.ReplyToMessage)
+		username = message.ReplyToMessage.SenderChat.UserName
 	} else if args != "" {
-		// 尝试从参数中解析频道ID
+		// 从参数中解析频道ID、@用户名或链接
 		var err error
-		channelID, err = utils.ParseChannelID(args)
+		channelID, username, err = h.resolveChannel(args)
 		if err != nil {
-			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的频道ID: %s", err.Error()))
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的频道: %s", err.Error()))
 			_, _ = h.Bot.Send(msg)
 			return err
 		}
 	} else {
 		// 既没有回复消息也没有提供参数
-		msg := tgbotapi.NewMessage(message.Chat.ID, "请回复一条频道消息或提供频道ID来将该频道添加到白名单")
+		msg := tgbotapi.NewMessage(message.Chat.ID, "请回复一条频道消息或提供频道ID、@用户名或 t.me 链接来将该频道添加到白名单，可在最后附带有效期和内容限制，如 /wl @频道用户名 7d --no-links")
 		_, err := h.Bot.Send(msg)
 		return err
 	}
@@ -73,23 +112,63 @@ func (h *Handler) HandleAddChannel(message *tgbotapi.Message, args string) error
 	}
 
 	if isWhitelisted {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "该频道已在白名单中")
+		text := "该频道已在白名单中"
+		if len(policy) > 0 {
+			text += "，可以使用 /policy 修改内容限制"
+		}
+		if sets, err := h.DB.GetSubscribedSetsContaining(message.Chat.ID, channelID); err == nil && len(sets) > 0 {
+			text = fmt.Sprintf("该频道已在本群订阅的共享白名单「%s」中", formatSetNames(sets))
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
 		_, err := h.Bot.Send(msg)
 		return err
 	}
 
 	// 添加频道到白名单
-	err = h.DB.AddChannelToWhitelist(message.Chat.ID, channelID, message.From.ID, "")
+	var expiresAt time.Time
+	if duration > 0 {
+		expiresAt = time.Now().Add(duration)
+	}
+	err = h.DB.AddChannelToWhitelist(message.Chat.ID, channelID, message.From.ID, username, "", expiresAt)
 	if err != nil {
 		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("添加频道到白名单失败: %s", err.Error()))
 		_, _ = h.Bot.Send(msg)
 		return err
 	}
 
+	if len(policy) > 0 {
+		if err := h.DB.SetChannelContentPolicy(message.Chat.ID, channelID, policy); err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("设置内容限制失败: %s", err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+	}
+
+	// 加入白名单的频道如果之前被封禁，同时解除封禁
+	h.liftSenderBanQuietly(message.Chat.ID, channelID, message.From.ID)
+
 	// 获取频道名称
 	channelName := h.getChannelName(channelID)
 
-	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已将频道「%s」添加到白名单", channelName))
+	text := fmt.Sprintf("已将频道「%s」添加到白名单", channelName)
+	var details []string
+	if duration > 0 {
+		details = append(details, "有效期至 "+expiresAt.Format("2006-01-02 15:04"))
+		text += fmt.Sprintf("，有效期 %s（至 %s），到期后自动移除", utils.FormatDuration(duration), expiresAt.Format("2006-01-02 15:04"))
+	}
+	if len(policy) > 0 {
+		details = append(details, "内容限制: "+formatContentPolicy(policy))
+		text += fmt.Sprintf("\n内容限制: %s", formatContentPolicy(policy))
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionWhitelistAdd, channelID, "", strings.Join(details, ", "))
+
+	if settings.UseGlobalLists {
+		if listType, err := h.DB.GetGlobalListType(channelID); err == nil && listType == models.GlobalListBlacklist {
+			text += "\n\n⚠️ 该频道在全局黑名单中，本群应用全局名单时仍会被阻止，可使用 /globallists off 关闭"
+		}
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	_, err = h.Bot.Send(msg)
 	return err
 }
@@ -136,23 +215,23 @@ func (h *Handler) HandleUnwhitelist(message *tgbotapi.Message, args string) erro
 		// 获取频道ID
 		channelID = utils.GetChannelID(message.ReplyToMessage)
 	} else if args != "" {
-		// 尝试从参数中解析频道ID
+		// 从参数中解析频道ID、@用户名或链接
 		var err error
-		channelID, err = utils.ParseChannelID(args)
+		channelID, _, err = h.resolveChannel(args)
 		if err != nil {
-			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的频道ID: %s", err.Error()))
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的频道: %s", err.Error()))
 			_, _ = h.Bot.Send(msg)
 			return err
 		}
 	} else {
 		// 既没有回复消息也没有提供参数
-		msg := tgbotapi.NewMessage(message.Chat.ID, "请回复一条频道消息或提供频道ID来将该频道从白名单移除")
+		msg := tgbotapi.NewMessage(message.Chat.ID, "请回复一条频道消息或提供频道ID、@用户名或 t.me 链接来将该频道从白名单移除")
 		_, err := h.Bot.Send(msg)
 		return err
 	}
 
-	// 检查频道是否在白名单中
-	isWhitelisted, err := h.DB.IsChannelWhitelisted(message.Chat.ID, channelID)
+	// 检查频道是否在群组自己的白名单中，共享白名单中的频道无法在这里移除
+	isWhitelisted, err := h.inGroupWhitelist(message.Chat.ID, channelID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("检查频道白名单状态失败: %s", err.Error()))
 		_, _ = h.Bot.Send(msg)
@@ -160,7 +239,11 @@ func (h *Handler) HandleUnwhitelist(message *tgbotapi.Message, args string) erro
 	}
 
 	if !isWhitelisted {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "该频道不在白名单中")
+		text := "该频道不在白名单中"
+		if sets, err := h.DB.GetSubscribedSetsContaining(message.Chat.ID, channelID); err == nil && len(sets) > 0 {
+			text = fmt.Sprintf("该频道不在本群白名单中，而是来自本群订阅的共享白名单「%s」。如需阻止，请联系名单所有者或使用 /unsubscribe 取消订阅", formatSetNames(sets))
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
 		_, err := h.Bot.Send(msg)
 		return err
 	}
@@ -172,11 +255,17 @@ func (h *Handler) HandleUnwhitelist(message *tgbotapi.Message, args string) erro
 		_, _ = h.Bot.Send(msg)
 		return err
 	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionWhitelistRemove, channelID, "", "")
 
 	// 获取频道名称
 	channelName := h.getChannelName(channelID)
 
-	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已将频道「%s」从白名单移除", channelName))
+	text := fmt.Sprintf("已将频道「%s」从白名单移除", channelName)
+	if sets, err := h.DB.GetSubscribedSetsContaining(message.Chat.ID, channelID); err == nil && len(sets) > 0 {
+		text += fmt.Sprintf("\n\n⚠️ 该频道仍在本群订阅的共享白名单「%s」中，可以继续发言。如需阻止，请联系名单所有者或使用 /unsubscribe 取消订阅", formatSetNames(sets))
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	_, err = h.Bot.Send(msg)
 	return err
 }
@@ -211,6 +300,7 @@ func (h *Handler) HandleEnable(message *tgbotapi.Message, _ string) error {
 	}
 
 	// 启用机器人
+	before := formatEnabled(settings.Enabled)
 	settings.Enabled = true
 	err = h.DB.UpdateGroupSettings(settings)
 	if err != nil {
@@ -218,6 +308,7 @@ func (h *Handler) HandleEnable(message *tgbotapi.Message, _ string) error {
 		_, _ = h.Bot.Send(msg)
 		return err
 	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionEnable, 0, before, formatEnabled(settings.Enabled))
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, "机器人已启用")
 	_, err = h.Bot.Send(msg)
@@ -254,6 +345,7 @@ func (h *Handler) HandleDisable(message *tgbotapi.Message, _ string) error {
 	}
 
 	// 禁用机器人
+	before := formatEnabled(settings.Enabled)
 	settings.Enabled = false
 	err = h.DB.UpdateGroupSettings(settings)
 	if err != nil {
@@ -261,6 +353,7 @@ func (h *Handler) HandleDisable(message *tgbotapi.Message, _ string) error {
 		_, _ = h.Bot.Send(msg)
 		return err
 	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionDisable, 0, before, formatEnabled(settings.Enabled))
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, "机器人已禁用")
 	_, err = h.Bot.Send(msg)
@@ -282,6 +375,11 @@ func (h *Handler) HandleSettings(message *tgbotapi.Message, _ string) error {
 		return err
 	}
 
+	steps, err := h.DB.GetEscalationSteps(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
 	// 格式化设置信息
 	enabledStatus := "启用"
 	if !settings.Enabled {
@@ -301,9 +399,172 @@ func (h *Handler) HandleSettings(message *tgbotapi.Message, _ string) error {
 	text := fmt.Sprintf("⚙️ 当前设置:\n\n"+
 		"状态: %s\n"+
 		"管理权限: %s\n"+
-		"日志频道: %s\n", enabledStatus, adminOnlyStatus, logChannelText)
+		"日志频道: %s\n"+
+		"消息记录保留: %s\n"+
+		"全局白名单和黑名单: %s\n"+
+		"自动封禁: %s\n"+
+		"处罚升级: %s（统计窗口 %s）\n"+
+		"关联频道身份: %s\n"+
+		"关联频道变更: %s\n"+
+		"观察模式: %s\n"+
+		"申请审核: %s\n"+
+		"重新申请冷却: %s\n"+
+		"申请超时: %s\n"+
+		"所有权验证: %s\n", enabledStatus, adminOnlyStatus, logChannelText, h.formatRetention(settings),
+		formatUseGlobalLists(settings.UseGlobalLists), formatBanThreshold(settings.BanThreshold),
+		formatEscalationSteps(steps), utils.FormatDuration(escalationWindow(settings)),
+		formatLinkedChannelAdmin(settings.LinkedChannelAdmin), formatLinkedChannelAuto(settings.LinkedChannelAuto),
+		formatObserveMode(settings.ObserveMode), formatReviewQuorum(reviewQuorum(settings)),
+		formatReapplyCooldown(settings.ReapplyCooldown), formatApplicationTimeouts(settings),
+		formatOwnershipPolicy(settings.OwnershipPolicy))
+
+	// 观察模式下显示本次观察期间本应删除的消息
+	if settings.ObserveMode {
+		summary, err := h.observeSummary(settings)
+		if err != nil {
+			return err
+		}
+		text += "\n" + summary
+	}
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	_, err = h.Bot.Send(msg)
 	return err
 }
+
+// HandleBackup 立即备份数据库并将快照以文件形式发送给全局管理员
+func (h *Handler) HandleBackup(message *tgbotapi.Message, _ string) error {
+	// 只允许私聊使用
+	if message.Chat.Type != "private" {
+		return nil
+	}
+
+	// 检查是否是全局管理员
+	if !utils.IsGlobalAdmin(h.Config.AdminUsers, message.From.ID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "只有全局管理员可以备份数据库")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	path, err := db.CreateBackup(h.DB, h.Config.BackupDir, h.Config.BackupKeep)
+	if err != nil && path == "" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("备份数据库失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	doc := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FilePath(path))
+	doc.Caption = fmt.Sprintf("数据库备份: %s", filepath.Base(path))
+	if _, sendErr := h.Bot.Send(doc); sendErr != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("备份已保存到 %s，但发送文件失败: %s", path, sendErr.Error()))
+		_, _ = h.Bot.Send(msg)
+		return sendErr
+	}
+
+	// 快照已生成，清理旧备份失败只需记录
+	return err
+}
+
+// requireGroupAdmin 检查消息发送者是否是群组管理员或全局管理员，不是时回复提示
+func (h *Handler) requireGroupAdmin(message *tgbotapi.Message) (bool, error) {
+	if utils.IsGlobalAdmin(h.Config.AdminUsers, message.From.ID) {
+		return true, nil
+	}
+
+	// 匿名管理员发送的修改类命令在确认后以确认人的身份执行，这里只会遇到只读命令
+	if h.isIdentityAdmin(message) {
+		return true, nil
+	}
+
+	isAdmin, err := utils.IsAdmin(h.Bot, message.Chat.ID, message.From.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if !isAdmin {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "只有群组管理员可以使用此命令")
+		_, err := h.Bot.Send(msg)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// formatRetention 格式化群组的被阻止消息保留期限
+func (h *Handler) formatRetention(settings models.GroupSettings) string {
+	days := models.EffectiveRetentionDays(settings.RetentionDays, h.Config.BlockedMessageRetentionDays)
+
+	var text string
+	if days < 0 {
+		text = "永久保留"
+	} else {
+		text = fmt.Sprintf("%d 天", days)
+	}
+
+	if settings.RetentionDays == 0 {
+		text += "（全局默认）"
+	}
+	return text
+}
+
+// HandleRetention 查看或设置被阻止消息的保留天数
+func (h *Handler) HandleRetention(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	// 获取群组设置
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	args = strings.ToLower(strings.TrimSpace(args))
+
+	// 没有参数时显示当前设置
+	if args == "" {
+		text := fmt.Sprintf("被阻止消息的保留期限: %s\n\n"+
+			"使用 /retention 天数 修改保留天数\n"+
+			"使用 /retention default 恢复全局默认值\n"+
+			"使用 /retention off 永久保留", h.formatRetention(settings))
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	before := "保留期限: " + h.formatRetention(settings)
+	switch args {
+	case "default":
+		settings.RetentionDays = 0
+	case "off":
+		settings.RetentionDays = -1
+	default:
+		days, err := strconv.Atoi(strings.TrimSuffix(args, "d"))
+		if err != nil || days <= 0 || days > 3650 {
+			msg := tgbotapi.NewMessage(message.Chat.ID, "请提供 1 到 3650 之间的天数，格式：/retention 30")
+			_, err := h.Bot.Send(msg)
+			return err
+		}
+		settings.RetentionDays = days
+	}
+
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新保留期限失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0, before, "保留期限: "+h.formatRetention(settings))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("被阻止消息的保留期限已设置为: %s", h.formatRetention(settings)))
+	_, err = h.Bot.Send(msg)
+	return err
+}
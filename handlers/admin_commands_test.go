@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+func TestWhitelistCommands(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	if err := h.HandleAddChannel(commandMessage(groupChat(), testGroupAdminID, "/wl -1001"), "-1001"); err != nil {
+		t.Fatalf("添加白名单失败: %v", err)
+	}
+	if whitelisted, err := h.DB.IsChannelWhitelisted(testChatID, testChannelID); err != nil || !whitelisted {
+		t.Fatalf("/wl 后频道不在白名单中（%v）", err)
+	}
+
+	if err := h.HandleUnwhitelist(commandMessage(groupChat(), testGroupAdminID, "/unwl -1001"), "-1001"); err != nil {
+		t.Fatalf("移除白名单失败: %v", err)
+	}
+	if whitelisted, err := h.DB.IsChannelWhitelisted(testChatID, testChannelID); err != nil || whitelisted {
+		t.Fatalf("/unwl 后频道仍在白名单中（%v）", err)
+	}
+	expectSent(t, fake, testChatID, "从白名单移除")
+
+	entries, total, err := h.DB.GetAuditLogs(testChatID, models.AuditFilter{}, 10, 0)
+	if err != nil || total != 2 {
+		t.Fatalf("审计日志有 %d 条（%v），期望添加和移除各一条", total, err)
+	}
+	for _, entry := range entries {
+		if entry.ActorID != testGroupAdminID || entry.TargetID != testChannelID {
+			t.Fatalf("审计日志 %+v 的操作人或频道不正确", entry)
+		}
+	}
+
+	// 频道已不在白名单中时不会再次记录移除
+	if err := h.HandleUnwhitelist(commandMessage(groupChat(), testGroupAdminID, "/unwl -1001"), "-1001"); err != nil {
+		t.Fatalf("移除白名单失败: %v", err)
+	}
+	expectSent(t, fake, testChatID, "该频道不在白名单中")
+}
+
+func TestWhitelistCommandsRequireAdmin(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	if err := h.HandleAddChannel(commandMessage(groupChat(), testOwnerID, "/wl -1001"), "-1001"); err != nil {
+		t.Fatalf("添加白名单失败: %v", err)
+	}
+	if whitelisted, err := h.DB.IsChannelWhitelisted(testChatID, testChannelID); err != nil || whitelisted {
+		t.Fatalf("非管理员的 /wl 添加了白名单（%v）", err)
+	}
+	expectSent(t, fake, testChatID, "只有群组管理员可以管理白名单")
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxApplicationTimeout 待处理申请允许设置的最长超时时间
+const maxApplicationTimeout = 90 * 24 * time.Hour
+
+// formatApplicationTimeout 格式化待处理申请的超时时间
+func formatApplicationTimeout(timeout time.Duration) string {
+	if timeout <= 0 {
+		return "不过期"
+	}
+	return utils.FormatDuration(timeout)
+}
+
+// formatApplicationTimeouts 格式化群组的申请超时设置
+func formatApplicationTimeouts(settings models.GroupSettings) string {
+	return fmt.Sprintf("无人认领 %s，认领后未审核 %s",
+		formatApplicationTimeout(settings.UnclaimedTimeout), formatApplicationTimeout(settings.UnreviewedTimeout))
+}
+
+// expireStaleApplications 将超时的待处理申请标记为已过期，通知群组和认领人，并清除相关的等待状态
+func (h *Handler) expireStaleApplications() {
+	expired, err := h.DB.ExpireStaleApplications(time.Now())
+	if err != nil {
+		fmt.Printf("处理过期的申请失败: %s\n", err.Error())
+	}
+
+	for _, app := range expired {
+		h.audit(app.ChatID, 0, models.AuditActionApplicationExpire, app.ChannelID, "pending", "expired")
+
+		// 清除等待认领人补充申请理由和等待管理员填写拒绝理由的状态
+		if err := h.DB.ClearUserStatesByValue(fmt.Sprintf("waiting_reason:%d:%d", app.ChatID, app.ChannelID)); err != nil {
+			fmt.Printf("清除申请 #%d 的用户状态失败: %s\n", app.ID, err.Error())
+		}
+		if err := h.DB.ClearUserStatesByValue(fmt.Sprintf("%s%d", rejectReasonState, app.ID)); err != nil {
+			fmt.Printf("清除申请 #%d 的用户状态失败: %s\n", app.ID, err.Error())
+		}
+
+		channelName := h.getChannelName(app.ChannelID)
+		cause := "长时间无人认领"
+		if app.UserID != 0 {
+			cause = "长时间未审核"
+		}
+
+		// 通知群组
+		groupMsg := tgbotapi.NewMessage(app.ChatID, fmt.Sprintf("频道「%s」的发言申请因%s已过期，如需发言请重新申请", channelName, cause))
+		_, _ = h.Bot.Send(groupMsg)
+
+		if app.UserID == 0 {
+			continue
+		}
+
+		// 通知认领人
+		notifyMsg := tgbotapi.NewMessage(app.UserID, fmt.Sprintf("您对频道「%s」的发言申请因%s已过期，如需发言请在群组中重新申请", channelName, cause))
+		_, _ = h.Bot.Send(notifyMsg)
+
+		// 更新发送给管理员的申请通知，移除审核按钮
+		votes, err := h.DB.GetApplicationVotes(app.ID)
+		if err != nil {
+			fmt.Printf("获取申请 #%d 的投票失败: %s\n", app.ID, err.Error())
+			continue
+		}
+		settings, err := h.DB.GetOrCreateGroupSettings(app.ChatID)
+		if err != nil {
+			fmt.Printf("获取群组 %d 的设置失败: %s\n", app.ChatID, err.Error())
+			continue
+		}
+		h.refreshApplicationNotices(app, channelName, votes, reviewQuorum(settings), "⌛ 申请已过期")
+	}
+}
+
+// HandleAppTimeout 查看或设置待处理申请的超时时间，超时的申请会被标记为已过期
+func (h *Handler) HandleAppTimeout(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(strings.ToLower(args))
+	if len(fields) == 0 {
+		text := fmt.Sprintf("申请超时: %s\n\n"+
+			"使用 /apptimeout unclaimed 时长（如 24h、3d）设置申请无人认领多久后过期\n"+
+			"使用 /apptimeout unreviewed 时长 设置申请认领后多久未审核则过期\n"+
+			"时长最长 %s，使用 off 取消对应的超时", formatApplicationTimeouts(settings), utils.FormatDuration(maxApplicationTimeout))
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	if len(fields) != 2 || (fields[0] != "unclaimed" && fields[0] != "unreviewed") {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "格式：/apptimeout unclaimed|unreviewed 时长|off")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	var timeout time.Duration
+	if fields[1] != "off" {
+		timeout, err = utils.ParseDuration(fields[1])
+		if err == nil && timeout > maxApplicationTimeout {
+			err = fmt.Errorf("超时时间最长为 %s", utils.FormatDuration(maxApplicationTimeout))
+		}
+		if err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+	}
+
+	before := formatApplicationTimeouts(settings)
+	if fields[0] == "unclaimed" {
+		settings.UnclaimedTimeout = timeout
+	} else {
+		settings.UnreviewedTimeout = timeout
+	}
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新设置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0,
+		"申请超时: "+before, "申请超时: "+formatApplicationTimeouts(settings))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("申请超时已设置为: %s", formatApplicationTimeouts(settings)))
+	_, err = h.Bot.Send(msg)
+	return err
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpireUnclaimedApplication(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	settings, err := h.DB.GetOrCreateGroupSettings(testChatID)
+	if err != nil {
+		t.Fatalf("获取群组设置失败: %v", err)
+	}
+	settings.UnclaimedTimeout = time.Millisecond
+	if err := h.DB.UpdateGroupSettings(settings); err != nil {
+		t.Fatalf("更新群组设置失败: %v", err)
+	}
+
+	if err := h.DB.CreateChannelApplication(testChatID, testChannelID, 0, "理由"); err != nil {
+		t.Fatalf("创建申请失败: %v", err)
+	}
+	app, err := h.DB.GetPendingChannelApplication(testChatID, testChannelID)
+	if err != nil {
+		t.Fatalf("获取待处理申请失败: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	h.expireStaleApplications()
+
+	if app, err = h.DB.GetChannelApplicationByID(app.ID); err != nil || app.Status != "expired" {
+		t.Fatalf("超时后申请状态为 %s（%v），期望 expired", app.Status, err)
+	}
+	expectSent(t, fake, testChatID, "长时间无人认领")
+
+	// 过期后可以立即重新申请
+	if err := h.DB.CreateChannelApplication(testChatID, testChannelID, 0, "理由"); err != nil {
+		t.Fatalf("过期后重新申请失败: %v", err)
+	}
+}
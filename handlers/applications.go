@@ -2,6 +2,10 @@ package handlers
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/anhe/tg-whitelist-bot/db/models"
 	"github.com/anhe/tg-whitelist-bot/utils"
@@ -37,6 +41,15 @@ func (h *Handler) HandleApply(message *tgbotapi.Message, args string) error {
 
 	// 如果有待处理的申请
 	if pendingApp.ID != 0 {
+		// 观察模式下不删除消息也不提示
+		settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+		if err != nil {
+			return err
+		}
+		if settings.ObserveMode {
+			return nil
+		}
+
 		// 检查今天是否已经提示过
 		hasNoticed, err := h.DB.HasPendingNoticeToday(message.Chat.ID, channelID)
 		if err != nil {
@@ -115,11 +128,10 @@ func (h *Handler) HandleApply(message *tgbotapi.Message, args string) error {
 
 // HandleClaim 认领频道申请
 func (h *Handler) HandleClaim(message *tgbotapi.Message, args string) error {
-	// 解析频道ID
-	var channelID int64
-	_, err := fmt.Sscanf(args, "%d", &channelID)
+	// 解析频道ID、@用户名或链接
+	channelID, err := h.channelFromFirstArg(args)
 	if err != nil || channelID == 0 {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "请提供有效的频道ID，格式：/claim 频道ID")
+		msg := tgbotapi.NewMessage(message.Chat.ID, "请提供有效的频道ID、@用户名或 t.me 链接，格式：/claim 频道")
 		_, err := h.Bot.Send(msg)
 		return err
 	}
@@ -135,7 +147,7 @@ func (h *Handler) HandleClaim(message *tgbotapi.Message, args string) error {
 	var targetApp models.ChannelApplication
 	var targetChatID int64
 	for _, app := range applications {
-		if app.ChannelID == channelID && app.UserID == 0 { // UserID为0表示尚未认领
+		if app.ChannelID == channelID && !app.VerifiedChannel { // 尚未认领或认领人尚未通过所有权验证
 			targetApp = app
 			targetChatID = app.ChatID
 			break
@@ -143,7 +155,7 @@ func (h *Handler) HandleClaim(message *tgbotapi.Message, args string) error {
 	}
 
 	if targetApp.ID == 0 {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "未找到该频道的待处理申请，或申请已被其他人认领")
+		msg := tgbotapi.NewMessage(message.Chat.ID, "未找到该频道的待处理申请，或申请已被其他人认领并通过验证")
 		_, err := h.Bot.Send(msg)
 		return err
 	}
@@ -229,20 +241,23 @@ func (h *Handler) HandleClaim(message *tgbotapi.Message, args string) error {
 		return err
 	} else {
 		// 不需要验证，直接更新申请
-		err = h.DB.UpdateChannelApplicationUser(targetChatID, targetApp.ChannelID, message.From.ID)
+		err = h.claimApplication(targetChatID, targetApp.ChannelID, message.From.ID)
 		if err != nil {
 			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("认领申请失败: %s", err.Error()))
 			_, _ = h.Bot.Send(msg)
 			return err
 		}
 
-		// 验证频道所有权
-		err = h.DB.VerifyChannelOwnership(targetChatID, targetApp.ChannelID, message.From.ID)
+		// 按群组设置验证频道所有权，未全部通过时已发送验证说明，通过后才通知管理员
+		verified, err := h.beginOwnershipVerification(targetChatID, targetApp.ChannelID, message.From.ID, message.Chat.ID)
 		if err != nil {
 			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("验证频道所有权失败: %s", err.Error()))
 			_, _ = h.Bot.Send(msg)
 			return err
 		}
+		if !verified {
+			return nil
+		}
 
 		// 获取频道名称
 		channelName := "未知频道"
@@ -258,7 +273,7 @@ func (h *Handler) HandleClaim(message *tgbotapi.Message, args string) error {
 		}
 
 		// 通知管理员
-		err = h.notifyAdminsAboutApplication(targetChatID, targetApp.ChannelID, message.From.ID, channelName, targetApp.Reason)
+		err = h.notifyAdminsAboutApplication(targetChatID, targetApp.ChannelID, channelName)
 		if err != nil {
 			return err
 		}
@@ -286,178 +301,312 @@ func (h *Handler) HandleClaim(message *tgbotapi.Message, args string) error {
 	}
 }
 
-// HandleApprove 批准频道申请
-func (h *Handler) HandleApprove(message *tgbotapi.Message, args string) error {
-	// 只允许私聊使用
-	if message.Chat.Type != "private" {
-		return nil
+// applicationIDPattern 匹配管理员通知中的申请ID
+var applicationIDPattern = regexp.MustCompile(`申请ID: (\d+)`)
+
+// canReviewApplication 检查用户能否审核群组的申请，全局管理员和群组管理员可以审核
+func (h *Handler) canReviewApplication(userID, chatID int64) (bool, error) {
+	if utils.IsGlobalAdmin(h.Config.AdminUsers, userID) {
+		return true, nil
 	}
+	return utils.IsAdmin(h.Bot, chatID, userID)
+}
 
-	// 检查是否是管理员
-	isGlobalAdmin := utils.IsGlobalAdmin(h.Config.AdminUsers, message.From.ID)
-	if !isGlobalAdmin {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "只有管理员可以批准申请")
-		_, err := h.Bot.Send(msg)
-		return err
+// checkReviewableApplication 检查申请能否被用户审核，不能审核时返回原因
+func (h *Handler) checkReviewableApplication(app models.ChannelApplication, userID int64) error {
+	if app.Status != "pending" {
+		return fmt.Errorf("申请 #%d %s，无需再次审核", app.ID, formatApplicationStatus(app.Status))
+	}
+	if !app.VerifiedChannel {
+		return fmt.Errorf("申请 #%d 尚未经过认领验证", app.ID)
+	}
+	ok, err := h.canReviewApplication(userID, app.ChatID)
+	if err != nil {
+		return fmt.Errorf("检查管理员权限失败: %s", err.Error())
+	}
+	if !ok {
+		return fmt.Errorf("您不是申请 #%d 所在群组的管理员", app.ID)
+	}
+	return nil
+}
+
+// findApplicationToReview 根据 /approve、/reject 的参数找到要审核的申请并返回审核备注。
+// 回复申请通知时参数全部作为备注；否则第一个参数为申请ID或频道，其余为备注。返回的错误可以直接提示给用户
+func (h *Handler) findApplicationToReview(message *tgbotapi.Message, args, command string) (models.ChannelApplication, string, error) {
+	usage := fmt.Errorf("格式：/%s 申请ID [备注]、/%s 频道 [备注]，或回复申请通知发送 /%s [备注]", command, command, command)
+
+	// 回复机器人发送的申请通知
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && reply.From.ID == h.Bot.Self.ID {
+		match := applicationIDPattern.FindStringSubmatch(reply.Text)
+		if match == nil {
+			return models.ChannelApplication{}, "", fmt.Errorf("回复的消息中没有申请ID\n\n%s", usage.Error())
+		}
+		id, _ := strconv.ParseInt(match[1], 10, 64)
+		app, err := h.applicationForReview(id, message.From.ID)
+		return app, strings.TrimSpace(args), err
 	}
 
-	// 解析频道ID
-	var channelID int64
-	_, err := fmt.Sscanf(args, "%d", &channelID)
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return models.ChannelApplication{}, "", usage
+	}
+	note := decisionNoteFromArgs(args)
+
+	// 正整数为申请ID，频道ID总是负数
+	if id, err := strconv.ParseInt(strings.TrimPrefix(fields[0], "#"), 10, 64); err == nil && id > 0 {
+		app, err := h.applicationForReview(id, message.From.ID)
+		return app, note, err
+	}
+
+	channelID, err := h.channelFromFirstArg(args)
 	if err != nil || channelID == 0 {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "请提供有效的频道ID，格式：/approve 频道ID")
-		_, err := h.Bot.Send(msg)
-		return err
+		return models.ChannelApplication{}, "", usage
 	}
 
-	// 获取所有待处理的申请
 	applications, err := h.DB.GetPendingApplications()
 	if err != nil {
-		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("获取申请失败: %s", err.Error()))
-		_, _ = h.Bot.Send(msg)
-		return err
+		return models.ChannelApplication{}, "", fmt.Errorf("获取申请失败: %s", err.Error())
 	}
 
-	// 查找对应的申请
-	var targetApp models.ChannelApplication
+	// 频道可能同时在多个群组中申请，只考虑用户有权审核的申请
+	var matched []models.ChannelApplication
 	for _, app := range applications {
-		if app.ChannelID == channelID && app.VerifiedChannel {
-			targetApp = app
-			break
+		if app.ChannelID != channelID || !app.VerifiedChannel {
+			continue
+		}
+		if ok, err := h.canReviewApplication(message.From.ID, app.ChatID); err == nil && ok {
+			matched = append(matched, app)
 		}
 	}
 
-	if targetApp.ID == 0 {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "未找到该频道的待处理申请或该申请未经过认领验证")
-		_, err := h.Bot.Send(msg)
-		return err
+	switch len(matched) {
+	case 0:
+		return models.ChannelApplication{}, "", fmt.Errorf("未找到该频道的待处理申请或该申请未经过认领验证")
+	case 1:
+		return matched[0], note, nil
 	}
 
+	var sb strings.Builder
+	sb.WriteString("该频道在多个群组中有待处理的申请，请使用申请ID:\n")
+	for _, app := range matched {
+		sb.WriteString(fmt.Sprintf("#%d 群组 %d\n", app.ID, app.ChatID))
+	}
+	sb.WriteString(fmt.Sprintf("\n格式：/%s 申请ID [备注]", command))
+	return models.ChannelApplication{}, "", fmt.Errorf("%s", sb.String())
+}
+
+// applicationForReview 根据申请ID获取申请，并检查用户能否审核
+func (h *Handler) applicationForReview(applicationID, userID int64) (models.ChannelApplication, error) {
+	app, err := h.DB.GetChannelApplicationByID(applicationID)
+	if err != nil {
+		return app, fmt.Errorf("获取申请失败: %s", err.Error())
+	}
+	if app.ID == 0 {
+		return app, fmt.Errorf("未找到申请 #%d", applicationID)
+	}
+	return app, h.checkReviewableApplication(app, userID)
+}
+
+// approveApplication 批准申请：将频道加入白名单、更新申请状态并通知申请人和群组，返回频道名称
+func (h *Handler) approveApplication(app models.ChannelApplication, reviewerID int64, note string) (string, error) {
+	// 先更新申请状态：只有仍处于待处理状态的申请会被更新，多个进程同时达到票数时只有一个能继续
+	err := h.DB.ReviewChannelApplication(app.ID, "approved", reviewerID, note)
+	if err != nil {
+		return "", fmt.Errorf("更新申请状态失败: %s", err.Error())
+	}
+	h.audit(app.ChatID, reviewerID, models.AuditActionApplicationApprove, app.ChannelID, app.Status, "approved")
+
 	// 添加频道到白名单
-	err = h.DB.AddChannelToWhitelist(targetApp.ChatID, targetApp.ChannelID, targetApp.UserID, targetApp.Reason)
+	err = h.DB.AddChannelToWhitelist(app.ChatID, app.ChannelID, app.UserID,
+		h.getChannelUsername(app.ChannelID), app.Reason, time.Time{})
 	if err != nil {
-		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("添加频道到白名单失败: %s", err.Error()))
-		_, _ = h.Bot.Send(msg)
-		return err
+		return "", fmt.Errorf("申请已批准，但添加频道到白名单失败，请使用 /wl 手动添加: %s", err.Error())
 	}
 
+	// 申请通过的频道如果之前被封禁，同时解除封禁
+	h.liftSenderBanQuietly(app.ChatID, app.ChannelID, reviewerID)
+
+	channelName := h.getChannelName(app.ChannelID)
+
+	// 通知申请人
+	notifyText := fmt.Sprintf("您对频道「%s」的发言申请已被批准", channelName) + formatDecisionNote(note)
+	notifyMsg := tgbotapi.NewMessage(app.UserID, notifyText)
+	_, _ = h.Bot.Send(notifyMsg)
+
+	// 通知群组
+	groupNotifyText := fmt.Sprintf("频道「%s」的发言申请已被批准", channelName) + formatDecisionNote(note)
+	groupMsg := tgbotapi.NewMessage(app.ChatID, groupNotifyText)
+	_, _ = h.Bot.Send(groupMsg)
+
+	return channelName, nil
+}
+
+// rejectApplication 拒绝申请：更新申请状态，并将拒绝理由和可以重新申请的时间通知申请人和群组，返回频道名称
+func (h *Handler) rejectApplication(app models.ChannelApplication, reviewerID int64, reason string) (string, error) {
 	// 更新申请状态
-	err = h.DB.UpdateChannelApplicationStatus(targetApp.ChatID, targetApp.ChannelID, targetApp.UserID, "approved")
+	err := h.DB.ReviewChannelApplication(app.ID, "rejected", reviewerID, reason)
 	if err != nil {
-		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新申请状态失败: %s", err.Error()))
-		_, _ = h.Bot.Send(msg)
-		return err
+		return "", fmt.Errorf("更新申请状态失败: %s", err.Error())
 	}
+	h.audit(app.ChatID, reviewerID, models.AuditActionApplicationReject, app.ChannelID, app.Status, "rejected")
 
-	// 获取频道名称
-	channelName := "未知频道"
-	channelChat, err := h.Bot.GetChat(tgbotapi.ChatInfoConfig{
-		ChatConfig: tgbotapi.ChatConfig{
-			ChatID: targetApp.ChannelID,
-		},
-	})
-	if err == nil && channelChat.Title != "" {
-		channelName = channelChat.Title
-	} else {
-		channelName = fmt.Sprintf("ID: %d", targetApp.ChannelID)
+	channelName := h.getChannelName(app.ChannelID)
+
+	// 群组设置了冷却时间时，说明多久之后可以重新申请
+	details := formatRejectReason(reason)
+	if reapplyAt, err := h.DB.GetReapplyTime(app.ChatID, app.ChannelID); err == nil && !reapplyAt.IsZero() {
+		details += fmt.Sprintf("\n%s 之后可以重新申请", reapplyAt.Format("2006-01-02 15:04"))
 	}
 
 	// 通知申请人
-	notifyText := fmt.Sprintf("您对频道「%s」的发言申请已被批准", channelName)
-	notifyMsg := tgbotapi.NewMessage(targetApp.UserID, notifyText)
+	notifyText := fmt.Sprintf("您对频道「%s」的发言申请已被拒绝", channelName) + details
+	notifyMsg := tgbotapi.NewMessage(app.UserID, notifyText)
 	_, _ = h.Bot.Send(notifyMsg)
 
 	// 通知群组
-	groupNotifyText := fmt.Sprintf("频道「%s」的发言申请已被批准", channelName)
-	groupMsg := tgbotapi.NewMessage(targetApp.ChatID, groupNotifyText)
+	groupNotifyText := fmt.Sprintf("频道「%s」的发言申请已被拒绝", channelName) + details
+	groupMsg := tgbotapi.NewMessage(app.ChatID, groupNotifyText)
 	_, _ = h.Bot.Send(groupMsg)
 
-	// 回复管理员
-	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已批准频道「%s」的发言申请", channelName))
-	_, err = h.Bot.Send(msg)
-	return err
+	return channelName, nil
 }
 
-// HandleReject 拒绝频道申请
-func (h *Handler) HandleReject(message *tgbotapi.Message, args string) error {
+// HandleApprove 投票批准频道申请，支持申请ID、频道或回复申请通知
+func (h *Handler) HandleApprove(message *tgbotapi.Message, args string) error {
 	// 只允许私聊使用
 	if message.Chat.Type != "private" {
-		return nil
-	}
-
-	// 检查是否是管理员
-	isGlobalAdmin := utils.IsGlobalAdmin(h.Config.AdminUsers, message.From.ID)
-	if !isGlobalAdmin {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "只有管理员可以拒绝申请")
+		msg := tgbotapi.NewMessage(message.Chat.ID, "请在私聊中使用 /approve 审核申请")
 		_, err := h.Bot.Send(msg)
 		return err
 	}
 
-	// 解析频道ID
-	var channelID int64
-	_, err := fmt.Sscanf(args, "%d", &channelID)
-	if err != nil || channelID == 0 {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "请提供有效的频道ID，格式：/reject 频道ID")
+	app, note, err := h.findApplicationToReview(message, args, "approve")
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
 		_, err := h.Bot.Send(msg)
 		return err
 	}
 
-	// 获取所有待处理的申请
-	applications, err := h.DB.GetPendingApplications()
+	result, err := h.voteOnApplication(app, message.From.ID, models.VoteApprove, note)
 	if err != nil {
-		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("获取申请失败: %s", err.Error()))
+		msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
 		_, _ = h.Bot.Send(msg)
 		return err
 	}
 
-	// 查找对应的申请
-	var targetApp models.ChannelApplication
-	for _, app := range applications {
-		if app.ChannelID == channelID && app.VerifiedChannel {
-			targetApp = app
-			break
-		}
+	// 回复管理员
+	msg := tgbotapi.NewMessage(message.Chat.ID, result)
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// HandleReject 投票拒绝频道申请，支持申请ID、频道或回复申请通知，备注作为拒绝理由，没有备注时选择理由
+func (h *Handler) HandleReject(message *tgbotapi.Message, args string) error {
+	// 只允许私聊使用
+	if message.Chat.Type != "private" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "请在私聊中使用 /reject 审核申请")
+		_, err := h.Bot.Send(msg)
+		return err
 	}
 
-	if targetApp.ID == 0 {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "未找到该频道的待处理申请或该申请未经过认领验证")
+	app, note, err := h.findApplicationToReview(message, args, "reject")
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
 		_, err := h.Bot.Send(msg)
 		return err
 	}
 
-	// 更新申请状态
-	err = h.DB.UpdateChannelApplicationStatus(targetApp.ChatID, targetApp.ChannelID, targetApp.UserID, "rejected")
+	// 没有附带理由时发送拒绝理由选择菜单
+	if note == "" {
+		return h.sendRejectReasonPicker(message.From.ID, app)
+	}
+
+	result, err := h.voteOnApplication(app, message.From.ID, models.VoteReject, note)
 	if err != nil {
-		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新申请状态失败: %s", err.Error()))
+		msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
 		_, _ = h.Bot.Send(msg)
 		return err
 	}
 
-	// 获取频道名称
-	channelName := "未知频道"
-	channelChat, err := h.Bot.GetChat(tgbotapi.ChatInfoConfig{
-		ChatConfig: tgbotapi.ChatConfig{
-			ChatID: targetApp.ChannelID,
-		},
-	})
-	if err == nil && channelChat.Title != "" {
-		channelName = channelChat.Title
-	} else {
-		channelName = fmt.Sprintf("ID: %d", targetApp.ChannelID)
+	// 回复管理员
+	msg := tgbotapi.NewMessage(message.Chat.ID, result)
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// handleReviewCallback 处理申请通知中的批准和拒绝按钮，按钮作为管理员的投票。
+// 回调数据为 approve:申请ID 或 reject:申请ID，旧版本的通知为 approve:群组ID:频道ID
+func (h *Handler) handleReviewCallback(query *tgbotapi.CallbackQuery) error {
+	parts := strings.Split(query.Data, ":")
+	isApprove := parts[0] == "approve"
+
+	var app models.ChannelApplication
+	switch len(parts) {
+	case 2:
+		applicationID, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		app, err = h.DB.GetChannelApplicationByID(applicationID)
+		if err != nil {
+			callback := tgbotapi.NewCallback(query.ID, "获取申请失败")
+			_, _ = h.Bot.Request(callback)
+			return err
+		}
+	case 3:
+		chatID, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		channelID, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		app, err = h.DB.GetPendingChannelApplication(chatID, channelID)
+		if err != nil {
+			callback := tgbotapi.NewCallback(query.ID, "获取申请失败")
+			_, _ = h.Bot.Request(callback)
+			return err
+		}
+	default:
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
 	}
 
-	// 通知申请人
-	notifyText := fmt.Sprintf("您对频道「%s」的发言申请已被拒绝", channelName)
-	notifyMsg := tgbotapi.NewMessage(targetApp.UserID, notifyText)
-	_, _ = h.Bot.Send(notifyMsg)
+	if app.ID == 0 {
+		callback := tgbotapi.NewCallback(query.ID, "未找到该频道的待处理申请")
+		_, _ = h.Bot.Request(callback)
+		return nil
+	}
+	if err := h.checkReviewableApplication(app, query.From.ID); err != nil {
+		callback := tgbotapi.NewCallback(query.ID, err.Error())
+		_, _ = h.Bot.Request(callback)
+		return nil
+	}
 
-	// 通知群组
-	groupNotifyText := fmt.Sprintf("频道「%s」的发言申请已被拒绝", channelName)
-	groupMsg := tgbotapi.NewMessage(targetApp.ChatID, groupNotifyText)
-	_, _ = h.Bot.Send(groupMsg)
+	// 旧版本的通知没有被记录，记录下来以便显示投票进度
+	_ = h.DB.AddApplicationNotice(models.ApplicationNotice{ApplicationID: app.ID, ChatID: query.Message.Chat.ID, MessageID: query.Message.MessageID})
 
-	// 回复管理员
-	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已拒绝频道「%s」的发言申请", channelName))
-	_, err = h.Bot.Send(msg)
+	// 拒绝前先私聊选择拒绝理由
+	if !isApprove {
+		if err := h.sendRejectReasonPicker(query.From.ID, app); err != nil {
+			callback := tgbotapi.NewCallback(query.ID, "无法发送私聊消息，请先私聊机器人")
+			_, _ = h.Bot.Request(callback)
+			return err
+		}
+		callback := tgbotapi.NewCallback(query.ID, "请在私聊中选择拒绝理由")
+		_, err := h.Bot.Request(callback)
+		return err
+	}
+
+	result, err := h.voteOnApplication(app, query.From.ID, models.VoteApprove, "")
+	if err != nil {
+		callback := tgbotapi.NewCallback(query.ID, err.Error())
+		_, _ = h.Bot.Request(callback)
+		return err
+	}
+
+	// 回复管理员，通知消息已在投票后更新
+	callback := tgbotapi.NewCallback(query.ID, result)
+	_, err = h.Bot.Request(callback)
 	return err
 }
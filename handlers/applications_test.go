@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// claimCallback 构造认领人在私聊中点击确认认领按钮的回调
+func claimCallback(userID int64, chatID, channelID int64) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID:      "1",
+		From:    &tgbotapi.User{ID: userID, FirstName: "用户"},
+		Message: &tgbotapi.Message{MessageID: 1, Chat: privateChat(userID)},
+		Data:    fmt.Sprintf("confirm_channel:%d:%d", chatID, channelID),
+	}
+}
+
+func TestApplyAndApprove(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	// 频道在群组中发送 /apply
+	if err := h.HandleMessage(channelMessage(10, testChannelID, "/apply 分享技术文章")); err != nil {
+		t.Fatalf("处理 /apply 失败: %v", err)
+	}
+	app, err := h.DB.GetPendingChannelApplication(testChatID, testChannelID)
+	if err != nil || app.ID == 0 || app.Reason != "分享技术文章" {
+		t.Fatalf("待处理申请为 %+v（%v），期望创建带理由的申请", app, err)
+	}
+	expectSent(t, fake, testChatID, "正在申请发言权限")
+
+	// 未经认领验证的申请不能审核
+	if err := h.HandleApprove(commandMessage(privateChat(testGlobalAdminID), testGlobalAdminID, "/approve"), fmt.Sprint(app.ID)); err != nil {
+		t.Fatalf("处理 /approve 失败: %v", err)
+	}
+	if whitelisted, _ := h.DB.IsChannelWhitelisted(testChatID, testChannelID); whitelisted {
+		t.Fatal("未经认领验证的申请被批准")
+	}
+
+	// 频道所有者认领后，群组管理员收到申请通知
+	if err := h.HandleCallbackQuery(claimCallback(testOwnerID, testChatID, testChannelID)); err != nil {
+		t.Fatalf("认领申请失败: %v", err)
+	}
+	if app, err = h.DB.GetChannelApplicationByID(app.ID); err != nil || app.UserID != testOwnerID || !app.VerifiedChannel {
+		t.Fatalf("认领后申请为 %+v（%v），期望认领人 %d 且已验证", app, err, testOwnerID)
+	}
+	expectSent(t, fake, testGroupAdminID, "分享技术文章")
+
+	if err := h.HandleApprove(commandMessage(privateChat(testGlobalAdminID), testGlobalAdminID, "/approve"), fmt.Sprint(app.ID)); err != nil {
+		t.Fatalf("处理 /approve 失败: %v", err)
+	}
+	if app, err = h.DB.GetChannelApplicationByID(app.ID); err != nil || app.Status != "approved" || app.ReviewerID != testGlobalAdminID {
+		t.Fatalf("批准后申请为 %+v（%v），期望由 %d 批准", app, err, testGlobalAdminID)
+	}
+	if whitelisted, err := h.DB.IsChannelWhitelisted(testChatID, testChannelID); err != nil || !whitelisted {
+		t.Fatalf("批准后频道不在白名单中（%v）", err)
+	}
+	expectSent(t, fake, testOwnerID, "已被批准")
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// auditPageSize 审计日志每页显示的条数
+const auditPageSize = 10
+
+// auditActionNames 审计操作类型的显示名称
+var auditActionNames = map[string]string{
+	models.AuditActionWhitelistAdd:       "添加白名单",
+	models.AuditActionWhitelistRemove:    "移除白名单",
+	models.AuditActionEnable:             "启用机器人",
+	models.AuditActionDisable:            "禁用机器人",
+	models.AuditActionSettingsUpdate:     "修改设置",
+	models.AuditActionApplicationApprove: "批准申请",
+	models.AuditActionApplicationReject:  "拒绝申请",
+	models.AuditActionApplicationExpire:  "申请过期",
+	models.AuditActionImport:             "导入配置",
+	models.AuditActionSenderBan:          "封禁频道",
+	models.AuditActionSenderUnban:        "解除封禁",
+	models.AuditActionSetSubscribe:       "订阅共享白名单",
+	models.AuditActionSetUnsubscribe:     "取消订阅",
+	models.AuditActionQuotaUpdate:        "修改发言配额",
+	models.AuditActionPolicyUpdate:       "修改内容限制",
+	models.AuditActionGlobalListAdd:      "添加全局名单",
+	models.AuditActionGlobalListRemove:   "移除全局名单",
+	models.AuditActionObserveWarn:        "本应警告",
+	models.AuditActionObserveBan:         "本应封禁",
+}
+
+// audit 记录一条审计日志，写入失败只打印错误，不影响操作本身
+func (h *Handler) audit(chatID, actorID int64, action string, targetID int64, before, after string) {
+	err := h.DB.AddAuditLog(models.AuditEntry{
+		ChatID:      chatID,
+		ActorID:     actorID,
+		Action:      action,
+		TargetID:    targetID,
+		BeforeValue: before,
+		AfterValue:  after,
+	})
+	if err != nil {
+		fmt.Printf("记录审计日志失败 (群组 %d, 操作 %s): %s\n", chatID, action, err.Error())
+	}
+}
+
+// formatSettingsForAudit 将群组设置格式化为审计日志中的前后值
+func formatSettingsForAudit(settings models.GroupSettings) string {
+	return fmt.Sprintf("白名单管理: %s, 机器人: %s, 保留天数: %d, 全局名单: %s, 自动封禁: %s, 关联频道身份: %s, 关联频道变更: %s, 观察模式: %s, 申请审核: %s, 重新申请冷却: %s, 申请超时: %s, 所有权验证: %s",
+		formatAdminOnly(settings.AdminOnly), formatEnabled(settings.Enabled), settings.RetentionDays,
+		formatUseGlobalLists(settings.UseGlobalLists), formatBanThreshold(settings.BanThreshold),
+		formatLinkedChannelAdmin(settings.LinkedChannelAdmin), formatLinkedChannelAuto(settings.LinkedChannelAuto),
+		formatObserveMode(settings.ObserveMode), formatReviewQuorum(reviewQuorum(settings)),
+		formatReapplyCooldown(settings.ReapplyCooldown), formatApplicationTimeouts(settings),
+		formatOwnershipPolicy(settings.OwnershipPolicy))
+}
+
+// parseAuditFilter 解析 /audit 命令的参数，参数可以是操作类型和/或频道（频道ID、@用户名或链接）
+func (h *Handler) parseAuditFilter(args string) (models.AuditFilter, error) {
+	var filter models.AuditFilter
+	for _, field := range strings.Fields(args) {
+		if _, ok := auditActionNames[field]; ok {
+			filter.Action = field
+			continue
+		}
+
+		channelID, _, err := h.resolveChannel(field)
+		if err != nil {
+			return filter, fmt.Errorf("无法识别的筛选条件: %s", field)
+		}
+		filter.TargetID = channelID
+	}
+	return filter, nil
+}
+
+// HandleAudit 分页查看群组的审计日志，可按操作类型或频道筛选。
+// 全局管理员在私聊中使用时查看全局名单的审计日志（群组ID记为 0）
+func (h *Handler) HandleAudit(message *tgbotapi.Message, args string) error {
+	chatID := message.Chat.ID
+	if message.Chat.Type == "private" && utils.IsGlobalAdmin(h.Config.AdminUsers, message.From.ID) {
+		chatID = 0
+	} else {
+		// 只在群组中工作
+		if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+			msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+			_, err := h.Bot.Send(msg)
+			return err
+		}
+
+		// 检查权限
+		if ok, err := h.requireGroupAdmin(message); !ok {
+			return err
+		}
+	}
+
+	filter, err := h.parseAuditFilter(args)
+	if err != nil {
+		actions := make([]string, 0, len(auditActionNames))
+		for action := range auditActionNames {
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+		text := fmt.Sprintf("%s\n\n用法：/audit [操作类型] [频道]\n可用的操作类型：%s", err.Error(), strings.Join(actions, ", "))
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	text, markup, err := h.buildAuditPage(chatID, filter, 0)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("获取审计日志失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// buildAuditPage 生成指定页的审计日志文本和翻页按钮
+func (h *Handler) buildAuditPage(chatID int64, filter models.AuditFilter, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	entries, total, err := h.DB.GetAuditLogs(chatID, filter, auditPageSize, page*auditPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if total == 0 {
+		return "没有找到符合条件的审计日志", nil, nil
+	}
+
+	pages := (total + auditPageSize - 1) / auditPageSize
+	text := fmt.Sprintf("📜 审计日志（第 %d/%d 页，共 %d 条）:\n\n", page+1, pages, total)
+	for _, entry := range entries {
+		actor := "系统"
+		if entry.ActorID != 0 {
+			actor = fmt.Sprintf("%d", entry.ActorID)
+		}
+
+		actionName, ok := auditActionNames[entry.Action]
+		if !ok {
+			actionName = entry.Action
+		}
+
+		text += fmt.Sprintf("#%d %s %s\n操作人: %s", entry.ID, entry.CreatedAt.Format("2006-01-02 15:04"), actionName, actor)
+		if entry.TargetID != 0 {
+			text += fmt.Sprintf("\n频道: %s", h.getChannelName(entry.TargetID))
+		}
+		if entry.BeforeValue != "" || entry.AfterValue != "" {
+			text += fmt.Sprintf("\n变更: %s → %s", entry.BeforeValue, entry.AfterValue)
+		}
+		text += "\n\n"
+	}
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("⬅️ 上一页", auditCallbackData(chatID, filter, page-1)))
+	}
+	if page+1 < pages {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("下一页 ➡️", auditCallbackData(chatID, filter, page+1)))
+	}
+	if len(buttons) == 0 {
+		return text, nil, nil
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(buttons)
+	return text, &markup, nil
+}
+
+// auditCallbackData 生成审计日志翻页按钮的回调数据
+func auditCallbackData(chatID int64, filter models.AuditFilter, page int) string {
+	return fmt.Sprintf("audit:%d:%d:%s:%d", chatID, page, filter.Action, filter.TargetID)
+}
+
+// handleAuditCallback 处理审计日志的翻页按钮
+func (h *Handler) handleAuditCallback(query *tgbotapi.CallbackQuery) error {
+	parts := strings.Split(query.Data, ":")
+	if len(parts) != 5 {
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
+	}
+
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	page, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return err
+	}
+	targetID, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return err
+	}
+	filter := models.AuditFilter{Action: parts[3], TargetID: targetID}
+
+	// 只有群组管理员可以翻页，全局名单的审计日志只有全局管理员可以查看
+	if !utils.IsGlobalAdmin(h.Config.AdminUsers, query.From.ID) {
+		if chatID == 0 {
+			callback := tgbotapi.NewCallback(query.ID, "只有全局管理员可以查看全局名单的审计日志")
+			_, _ = h.Bot.Request(callback)
+			return nil
+		}
+		isAdmin, err := utils.IsAdmin(h.Bot, chatID, query.From.ID)
+		if err != nil {
+			return err
+		}
+		if !isAdmin {
+			callback := tgbotapi.NewCallback(query.ID, "只有群组管理员可以查看审计日志")
+			_, _ = h.Bot.Request(callback)
+			return nil
+		}
+	}
+
+	text, markup, err := h.buildAuditPage(chatID, filter, page)
+	if err != nil {
+		callback := tgbotapi.NewCallback(query.ID, "获取审计日志失败")
+		_, _ = h.Bot.Request(callback)
+		return err
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, "")
+	_, _ = h.Bot.Request(callback)
+
+	var editMsg tgbotapi.EditMessageTextConfig
+	if markup != nil {
+		editMsg = tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, *markup)
+	} else {
+		editMsg = tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
+	}
+	_, err = h.Bot.Send(editMsg)
+	return err
+}
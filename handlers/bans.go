@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// banSenderChat 在群组中封禁频道并记录，actorID 为 0 表示自动封禁，expiresAt 为零值时永久封禁
+func (h *Handler) banSenderChat(chatID, channelID, actorID int64, reason string, expiresAt time.Time) error {
+	_, err := h.Bot.Request(tgbotapi.BanChatSenderChatConfig{
+		ChatID:       chatID,
+		SenderChatID: channelID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := h.DB.AddSenderChatBan(chatID, channelID, actorID, reason, expiresAt); err != nil {
+		return err
+	}
+
+	after := reason
+	if !expiresAt.IsZero() {
+		after = strings.TrimSpace(fmt.Sprintf("%s 解封时间 %s", reason, expiresAt.Format("2006-01-02 15:04")))
+	}
+	h.audit(chatID, actorID, models.AuditActionSenderBan, channelID, "", after)
+	return nil
+}
+
+// liftSenderBan 如果频道在群组中有封禁记录，解除封禁并删除记录，返回是否解除了封禁
+func (h *Handler) liftSenderBan(chatID, channelID, actorID int64) (bool, error) {
+	banned, err := h.DB.IsSenderChatBanned(chatID, channelID)
+	if err != nil || !banned {
+		return false, err
+	}
+
+	_, err = h.Bot.Request(tgbotapi.UnbanChatSenderChatConfig{
+		ChatID:       chatID,
+		SenderChatID: channelID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if err := h.DB.RemoveSenderChatBan(chatID, channelID); err != nil {
+		return false, err
+	}
+	h.audit(chatID, actorID, models.AuditActionSenderUnban, channelID, "", "")
+	return true, nil
+}
+
+// liftSenderBanQuietly 在频道获准发言时解除封禁，失败只打印错误
+func (h *Handler) liftSenderBanQuietly(chatID, channelID, actorID int64) {
+	if _, err := h.liftSenderBan(chatID, channelID, actorID); err != nil {
+		fmt.Printf("解除频道 %d 在群组 %d 的封禁失败: %s\n", channelID, chatID, err.Error())
+	}
+}
+
+// liftExpiredSenderBans 解除已到期的临时封禁，并通知群组
+func (h *Handler) liftExpiredSenderBans() {
+	expired, err := h.DB.GetExpiredSenderChatBans(time.Now())
+	if err != nil {
+		fmt.Printf("获取到期的频道封禁失败: %s\n", err.Error())
+		return
+	}
+
+	for _, ban := range expired {
+		lifted, err := h.liftSenderBan(ban.ChatID, ban.ChannelID, 0)
+		if err != nil {
+			fmt.Printf("解除频道 %d 在群组 %d 的封禁失败: %s\n", ban.ChannelID, ban.ChatID, err.Error())
+			continue
+		}
+		if lifted {
+			notifyText := fmt.Sprintf("频道「%s」的临时封禁已到期，已自动解除", h.getChannelName(ban.ChannelID))
+			_, _ = h.Bot.Send(tgbotapi.NewMessage(ban.ChatID, notifyText))
+		}
+	}
+}
+
+// enforceBanThreshold 检查刚记录的被阻止消息，频道达到群组设置的数量时自动封禁，观察模式下只记录本应执行的封禁
+func (h *Handler) enforceBanThreshold(messages []blockedMessageInfo) {
+	checked := make(map[[2]int64]bool)
+	for _, msg := range messages {
+		// 超出发言配额和违反内容限制的白名单频道不参与自动封禁
+		if !countsAsViolation(msg.Reason) {
+			continue
+		}
+
+		key := [2]int64{msg.ChatID, msg.ChannelID}
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		settings, err := h.DB.GetOrCreateGroupSettings(msg.ChatID)
+		if err != nil || settings.BanThreshold <= 0 {
+			continue
+		}
+
+		banned, err := h.DB.IsSenderChatBanned(msg.ChatID, msg.ChannelID)
+		if err != nil || banned {
+			continue
+		}
+
+		// 解除封禁之前的记录不再计入，否则解封后的第一条消息就会再次触发封禁
+		since, err := h.DB.GetSenderChatUnbanTime(msg.ChatID, msg.ChannelID)
+		if err != nil {
+			fmt.Printf("获取频道 %d 的解封时间失败: %s\n", msg.ChannelID, err.Error())
+			continue
+		}
+
+		// 观察记录只统计本次观察期间的消息，达到数量时记录本应执行的封禁
+		if msg.Observed {
+			if since.Before(settings.ObserveSince) {
+				since = settings.ObserveSince
+			}
+			count, err := h.DB.CountObservedMessagesSince(msg.ChatID, msg.ChannelID, since)
+			if err == nil && count >= settings.BanThreshold {
+				h.recordObservedBan(msg.ChatID, msg.ChannelID, since, fmt.Sprintf("自动封禁：被阻止的消息达到 %d 条", count))
+			}
+			continue
+		}
+
+		count, err := h.DB.CountBlockedMessagesSince(msg.ChatID, msg.ChannelID, since)
+		if err != nil || count < settings.BanThreshold {
+			continue
+		}
+
+		reason := fmt.Sprintf("被阻止的消息达到 %d 条", count)
+		if err := h.banSenderChat(msg.ChatID, msg.ChannelID, 0, reason, time.Time{}); err != nil {
+			fmt.Printf("自动封禁频道 %d 失败: %s\n", msg.ChannelID, err.Error())
+			continue
+		}
+
+		notifyText := fmt.Sprintf("频道「%s」%s，已自动封禁。\n\n管理员可以使用 /sunban %d 解除封禁",
+			h.getChannelName(msg.ChannelID), reason, msg.ChannelID)
+		_, _ = h.Bot.Send(tgbotapi.NewMessage(msg.ChatID, notifyText))
+	}
+}
+
+// parseChannelTarget 从回复的频道消息或第一个参数（频道ID、@用户名或链接）中获取频道ID，返回频道ID和剩余参数
+func (h *Handler) parseChannelTarget(message *tgbotapi.Message, args string) (int64, string, error) {
+	if message.ReplyToMessage != nil && utils.IsChannelMessage(message.ReplyToMessage) {
+		return utils.GetChannelID(message.ReplyToMessage), strings.TrimSpace(args), nil
+	}
+
+	fields := strings.SplitN(strings.TrimSpace(args), " ", 2)
+	if fields[0] == "" {
+		return 0, "", fmt.Errorf("请回复一条频道消息或提供频道ID、@用户名或 t.me 链接")
+	}
+
+	channelID, _, err := h.resolveChannel(fields[0])
+	if err != nil {
+		return 0, "", err
+	}
+
+	rest := ""
+	if len(fields) == 2 {
+		rest = strings.TrimSpace(fields[1])
+	}
+	return channelID, rest, nil
+}
+
+// HandleSenderBan 封禁频道，支持回复频道消息或提供频道ID，可附带封禁原因
+func (h *Handler) HandleSenderBan(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	channelID, reason, err := h.parseChannelTarget(message, args)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("%s，格式：/sban 频道 [原因]", err.Error()))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	channelName := h.getChannelName(channelID)
+
+	banned, err := h.DB.IsSenderChatBanned(message.Chat.ID, channelID)
+	if err != nil {
+		return err
+	}
+	if banned {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("频道「%s」已被封禁", channelName))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	if err := h.banSenderChat(message.Chat.ID, channelID, message.From.ID, reason, time.Time{}); err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("封禁频道失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已封禁频道「%s」", channelName))
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// HandleSenderUnban 解除频道封禁，支持回复频道消息或提供频道ID
+func (h *Handler) HandleSenderUnban(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	channelID, _, err := h.parseChannelTarget(message, args)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("%s，格式：/sunban 频道", err.Error()))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	channelName := h.getChannelName(channelID)
+
+	lifted, err := h.liftSenderBan(message.Chat.ID, channelID, message.From.ID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("解除封禁失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	text := fmt.Sprintf("已解除频道「%s」的封禁", channelName)
+	if !lifted {
+		text = fmt.Sprintf("频道「%s」没有被封禁", channelName)
+	} else if settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID); err == nil && settings.BanThreshold > 0 {
+		text += fmt.Sprintf("\n\n本群的自动封禁仍然开启：该频道解封后再有 %d 条消息被阻止时会被再次封禁。"+
+			"如需允许其发言，请将其加入白名单", settings.BanThreshold)
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// HandleSenderBanList 列出群组中被封禁的频道
+func (h *Handler) HandleSenderBanList(message *tgbotapi.Message, _ string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	bans, err := h.DB.GetSenderChatBans(message.Chat.ID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("获取封禁列表失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	if len(bans) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "本群没有被封禁的频道")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	text := fmt.Sprintf("🚫 被封禁的频道（共 %d 个）:\n\n", len(bans))
+	for i, ban := range bans {
+		text += fmt.Sprintf("%d. 频道「%s」(ID: %d)\n    封禁时间: %s\n    操作人: %s\n",
+			i+1, h.getChannelName(ban.ChannelID), ban.ChannelID, ban.BannedAt.Format("2006-01-02 15:04:05"),
+			formatUserID(ban.BannedBy, "系统"))
+		if ban.Reason != "" {
+			text += fmt.Sprintf("    原因: %s\n", ban.Reason)
+		}
+		if ban.IsTemporary() {
+			text += fmt.Sprintf("    解封时间: %s\n", ban.ExpiresAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// formatBanThreshold 返回自动封禁设置的显示文本
+func formatBanThreshold(threshold int) string {
+	if threshold <= 0 {
+		return "关闭"
+	}
+	return fmt.Sprintf("被阻止 %d 条消息后封禁", threshold)
+}
+
+// HandleAutoBan 查看或设置频道被阻止多少条消息后自动封禁
+func (h *Handler) HandleAutoBan(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	args = strings.ToLower(strings.TrimSpace(args))
+	if args == "" {
+		text := fmt.Sprintf("自动封禁: %s\n\n"+
+			"使用 /autoban 次数 设置被阻止多少条消息后自动封禁频道\n"+
+			"使用 /autoban off 关闭自动封禁", formatBanThreshold(settings.BanThreshold))
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	before := formatBanThreshold(settings.BanThreshold)
+	if args == "off" {
+		settings.BanThreshold = 0
+	} else {
+		threshold, err := strconv.Atoi(args)
+		if err != nil || threshold <= 0 || threshold > 1000 {
+			msg := tgbotapi.NewMessage(message.Chat.ID, "请提供 1 到 1000 之间的次数，格式：/autoban 5")
+			_, err := h.Bot.Send(msg)
+			return err
+		}
+		settings.BanThreshold = threshold
+	}
+
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新自动封禁设置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0,
+		"自动封禁: "+before, "自动封禁: "+formatBanThreshold(settings.BanThreshold))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("自动封禁已设置为: %s", formatBanThreshold(settings.BanThreshold)))
+	_, err = h.Bot.Send(msg)
+	return err
+}
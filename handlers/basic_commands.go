@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/anhe/tg-whitelist-bot/db"
 	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -30,14 +33,14 @@ func (h *Handler) HandleStart(message *tgbotapi.Message, args string) error {
 
 				var targetApp models.ChannelApplication
 				for _, app := range applications {
-					if app.ChatID == chatID && app.ChannelID == channelID && app.UserID == 0 {
+					if app.ChatID == chatID && app.ChannelID == channelID && !app.VerifiedChannel {
 						targetApp = app
 						break
 					}
 				}
 
 				if targetApp.ID == 0 {
-					msg := tgbotapi.NewMessage(message.Chat.ID, "未找到该频道的待处理申请，或申请已被其他人认领")
+					msg := tgbotapi.NewMessage(message.Chat.ID, "未找到该频道的待处理申请，或申请已被其他人认领并通过验证")
 					_, err := h.Bot.Send(msg)
 					return err
 				}
@@ -131,15 +134,46 @@ func (h *Handler) HandleHelp(message *tgbotapi.Message, _ string) error {
 		"申请命令（由频道直接发送）:\n" +
 		"/apply [理由] - 申请频道发言权限（必须提供理由才能在群内认领）\n\n" +
 		"认领命令（由个人账号发送）:\n" +
-		"/claim [频道ID] - 认领频道申请\n\n" +
+		"/claim [频道] - 认领频道申请\n\n" +
+		"共享白名单命令（仅私聊）:\n" +
+		"/wlset [create|delete|show|add|remove 名称 ...] - 创建和管理共享白名单\n\n" +
 		"管理员命令:\n" +
-		"/whitelist 或 /wl - 将频道添加到白名单\n" +
-		"/unwhitelist 或 /unwl - 将频道从白名单移除\n" +
-		"/approve [频道ID] - 批准频道申请\n" +
-		"/reject [频道ID] - 拒绝频道申请\n" +
+		"/whitelist 或 /wl [频道] [有效期] [--限制] - 将频道添加到白名单，附带有效期（如 12h、7d）时为临时白名单，附带 --no-links 等时设置内容限制\n" +
+		"/unwhitelist 或 /unwl [频道] - 将频道从白名单移除\n" +
+		"/approve [申请ID|频道] [备注] - 批准频道申请（也可回复申请通知使用）\n" +
+		"/reject [申请ID|频道] [理由] - 拒绝频道申请（也可回复申请通知使用），不填理由时可选择理由\n" +
+		"（[频道] 可以是频道ID、@用户名或 t.me 链接）\n" +
+		"/pending [群组ID] - 查看待处理的申请，可直接批准或拒绝\n" +
+		"/quorum [票数] - 设置批准申请所需的管理员票数\n" +
+		"/cooldown [时长|off] - 设置申请被拒绝后重新申请的冷却时间\n" +
+		"/apptimeout [unclaimed|unreviewed 时长|off] - 设置待处理申请无人认领或未审核多久后过期\n" +
+		"/ownership [admin|code|bot ...|off] - 设置认领申请时需要通过的频道所有权验证\n" +
+		"/history [频道] - 查看频道的申请记录\n" +
 		"/enable - 启用机器人\n" +
 		"/disable - 禁用机器人\n" +
-		"/settings - 配置群组设置\n\n" +
+		"/settings - 配置群组设置\n" +
+		"/retention [天数|default|off] - 查看/设置被阻止消息的保留天数\n" +
+		"/export - 导出群组白名单、设置和待处理申请（私聊发送）\n" +
+		"/import - 导入群组配置（发送导出文件时附带此命令，或回复导出文件）\n" +
+		"/audit [操作类型] [频道] - 查看管理操作审计日志\n" +
+		"/globallists [on|off] - 设置本群是否应用全局白名单和黑名单\n" +
+		"/linkedadmin [on|off] - 设置是否将以关联频道身份发送的消息视为管理员\n" +
+		"/linkedchannel [auto|confirm|check] - 查看关联频道，设置变更时自动更新白名单或由管理员确认\n" +
+		"/observe [on|off] - 设置观察模式，只记录本应删除的消息，不删除也不提示\n" +
+		"/sban [频道] [原因] - 封禁频道\n" +
+		"/sunban [频道] - 解除频道封禁\n" +
+		"/sbanlist - 列出被封禁的频道\n" +
+		"/autoban [次数|off] - 设置频道被阻止多少条消息后自动封禁\n" +
+		"/escalation - 设置违规处罚升级规则\n" +
+		"/quota [频道] [次数/时长|off] - 查看/设置白名单频道的发言配额，如 5/24h\n" +
+		"/policy [频道] [--限制...|off] - 查看/设置白名单频道的内容限制\n" +
+		"/subscribe [名称] - 查看/订阅共享白名单\n" +
+		"/unsubscribe 名称 - 取消订阅共享白名单\n\n" +
+		"全局管理员命令（仅私聊）:\n" +
+		"/backup - 立即备份数据库并发送备份文件\n" +
+		"/gwl [add|remove 频道] - 管理全局白名单\n" +
+		"/gbl [add|remove 频道] - 管理全局黑名单\n" +
+		"/audit [操作类型] [频道] - 查看全局名单的审计日志\n\n" +
 		"📌 项目地址: https://github.com/younvapp/Telegram-Seer-Bot"
 
 	plainMsg := tgbotapi.NewMessage(message.Chat.ID, plainText)
@@ -185,13 +219,32 @@ func (h *Handler) HandleListChannels(message *tgbotapi.Message, _ string) error
 				channelName = channelChat.Title
 			}
 
-			text += fmt.Sprintf("%d. 频道「%s」(ID: %d)\n    添加时间: %s\n",
-				i+1, channelName, channel.ChannelID, addTime)
+			text += fmt.Sprintf("%d. 频道「%s」(ID: %d)\n", i+1, channelName, channel.ChannelID)
+			if channel.Username != "" {
+				text += fmt.Sprintf("    用户名: @%s\n", channel.Username)
+			}
+			text += fmt.Sprintf("    添加时间: %s\n", addTime)
 
 			if channel.Description != "" {
 				text += fmt.Sprintf("    描述: %s\n", channel.Description)
 			}
 
+			if channel.IsTemporary() {
+				text += fmt.Sprintf("    临时白名单，剩余: %s\n", utils.FormatDuration(time.Until(channel.ExpiresAt)))
+			}
+
+			if len(channel.ContentPolicy) > 0 {
+				text += fmt.Sprintf("    内容限制: %s\n", formatContentPolicy(channel.ContentPolicy))
+			}
+
+			if channel.HasQuota() {
+				used, err := h.channelQuotaUsage(channel)
+				if err != nil {
+					return err
+				}
+				text += fmt.Sprintf("    发言配额: %s，当前已发送 %d 条\n", formatQuota(channel), used)
+			}
+
 			// 添加分隔符
 			if i < len(channels)-1 {
 				text += "\n"
@@ -199,6 +252,19 @@ func (h *Handler) HandleListChannels(message *tgbotapi.Message, _ string) error
 		}
 	}
 
+	// 附加订阅的共享白名单
+	sets, err := h.DB.GetSubscribedSets(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+	setsText, err := h.formatSubscribedSets(sets)
+	if err != nil {
+		return err
+	}
+	if setsText != "" {
+		text += "\n\n" + setsText
+	}
+
 	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	_, err = h.Bot.Send(msg)
 	return err
@@ -232,6 +298,25 @@ func (h *Handler) HandleStats(message *tgbotapi.Message, _ string) error {
 		"白名单频道数量: %d\n"+
 		"已阻止消息数量: %d\n", len(channelsList), blockedCount)
 
+	// 观察模式下显示本次观察期间本应删除的消息
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+	if settings.ObserveMode {
+		summary, err := h.observeSummary(settings)
+		if err != nil {
+			return err
+		}
+		text += "\n👀 观察模式\n" + summary
+	}
+
+	// 显示白名单缓存命中情况
+	if cached, ok := h.DB.(*db.CachedStore); ok {
+		stats := cached.WhitelistCacheStats()
+		text += fmt.Sprintf("\n白名单缓存: 命中 %d / 未命中 %d（条目 %d）\n", stats.Hits, stats.Misses, stats.Entries)
+	}
+
 	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	_, err = h.Bot.Send(msg)
 	return err
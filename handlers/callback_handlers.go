@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	"github.com/anhe/tg-whitelist-bot/db/models"
-	"github.com/anhe/tg-whitelist-bot/utils"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -41,14 +40,14 @@ func (h *Handler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
 
 		var targetApp models.ChannelApplication
 		for _, app := range applications {
-			if app.ChatID == chatID && app.ChannelID == channelID && app.UserID == 0 {
+			if app.ChatID == chatID && app.ChannelID == channelID && !app.VerifiedChannel {
 				targetApp = app
 				break
 			}
 		}
 
 		if targetApp.ID == 0 {
-			callback := tgbotapi.NewCallback(query.ID, "未找到该频道的待处理申请，或申请已被其他人认领")
+			callback := tgbotapi.NewCallback(query.ID, "未找到该频道的待处理申请，或申请已被其他人认领并通过验证")
 			_, _ = h.Bot.Request(callback)
 			return nil
 		}
@@ -170,7 +169,7 @@ func (h *Handler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
 		}
 
 		// 更新申请的用户ID
-		err = h.DB.UpdateChannelApplicationUser(chatID, channelID, query.From.ID)
+		err = h.claimApplication(chatID, channelID, query.From.ID)
 		if err != nil {
 			// 发送错误消息
 			callback := tgbotapi.NewCallback(query.ID, "认领申请失败: "+err.Error())
@@ -186,14 +185,26 @@ func (h *Handler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
 			return err
 		}
 
-		// 验证频道所有权
-		err = h.DB.VerifyChannelOwnership(chatID, channelID, query.From.ID)
+		// 按群组设置验证频道所有权，未全部通过时已发送验证说明，通过后才通知管理员
+		verified, err := h.beginOwnershipVerification(chatID, channelID, query.From.ID, query.Message.Chat.ID)
 		if err != nil {
 			// 发送错误消息
 			callback := tgbotapi.NewCallback(query.ID, "验证频道所有权失败")
 			_, _ = h.Bot.Request(callback)
 			return err
 		}
+		if !verified {
+			callback := tgbotapi.NewCallback(query.ID, "请按照说明完成频道所有权验证")
+			_, _ = h.Bot.Request(callback)
+
+			editMsg := tgbotapi.NewEditMessageText(
+				query.Message.Chat.ID,
+				query.Message.MessageID,
+				fmt.Sprintf("您已认领频道「%s」的申请，请按照下方说明完成频道所有权验证", h.getChannelName(channelID)),
+			)
+			_, err := h.Bot.Send(editMsg)
+			return err
+		}
 
 		// 获取频道名称
 		channelName := "未知频道"
@@ -231,17 +242,8 @@ func (h *Handler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
 			userInfo += " @" + query.From.UserName
 		}
 
-		// 获取申请信息
-		app, err := h.DB.GetChannelApplication(chatID, channelID, query.From.ID)
-		if err != nil {
-			// 发送错误消息
-			callback := tgbotapi.NewCallback(query.ID, "获取申请信息失败")
-			_, _ = h.Bot.Request(callback)
-			return err
-		}
-
 		// 通知管理员
-		err = h.notifyAdminsAboutApplication(chatID, channelID, query.From.ID, channelName, app.Reason)
+		err = h.notifyAdminsAboutApplication(chatID, channelID, channelName)
 		if err != nil {
 			// 发送错误消息
 			callback := tgbotapi.NewCallback(query.ID, "通知管理员失败")
@@ -300,7 +302,7 @@ func (h *Handler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
 		}
 
 		// 更新申请的用户ID
-		err = h.DB.UpdateChannelApplicationUser(chatID, channelID, query.From.ID)
+		err = h.claimApplication(chatID, channelID, query.From.ID)
 		if err != nil {
 			// 发送错误消息
 			callback := tgbotapi.NewCallback(query.ID, "认领申请失败")
@@ -308,21 +310,24 @@ func (h *Handler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
 			return err
 		}
 
-		// 验证频道所有权
-		err = h.DB.VerifyChannelOwnership(chatID, channelID, query.From.ID)
+		// 按群组设置验证频道所有权，未全部通过时已发送验证说明，通过后才通知管理员
+		verified, err := h.beginOwnershipVerification(chatID, channelID, query.From.ID, query.Message.Chat.ID)
 		if err != nil {
 			// 发送错误消息
 			callback := tgbotapi.NewCallback(query.ID, "验证频道所有权失败")
 			_, _ = h.Bot.Request(callback)
 			return err
 		}
-
-		// 获取申请信息
-		app, err := h.DB.GetChannelApplication(chatID, channelID, query.From.ID)
-		if err != nil {
-			// 发送错误消息
-			callback := tgbotapi.NewCallback(query.ID, "获取申请信息失败")
+		if !verified {
+			callback := tgbotapi.NewCallback(query.ID, "请按照说明完成频道所有权验证")
 			_, _ = h.Bot.Request(callback)
+
+			editMsg := tgbotapi.NewEditMessageText(
+				query.Message.Chat.ID,
+				query.Message.MessageID,
+				fmt.Sprintf("您已认领频道「%s」的申请，请按照下方说明完成频道所有权验证", h.getChannelName(channelID)),
+			)
+			_, err := h.Bot.Send(editMsg)
 			return err
 		}
 
@@ -350,7 +355,7 @@ func (h *Handler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
 		}
 
 		// 通知管理员
-		err = h.notifyAdminsAboutApplication(chatID, channelID, query.From.ID, channelName, app.Reason)
+		err = h.notifyAdminsAboutApplication(chatID, channelID, channelName)
 		if err != nil {
 			// 发送错误消息
 			callback := tgbotapi.NewCallback(query.ID, "通知管理员失败")
@@ -374,148 +379,33 @@ func (h *Handler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
 		_, err = h.Bot.Send(editMsg)
 
 		return err
+	} else if strings.HasPrefix(data, "audit:") {
+		// 处理审计日志翻页按钮
+		return h.handleAuditCallback(query)
+	} else if strings.HasPrefix(data, "esc:") {
+		// 处理处罚升级规则设置菜单
+		return h.handleEscalationCallback(query)
+	} else if strings.HasPrefix(data, "linked:") {
+		// 处理关联频道变更的确认和忽略
+		return h.handleLinkedChannelCallback(query)
+	} else if strings.HasPrefix(data, "idcmd:") {
+		// 处理匿名管理员命令的确认和取消
+		return h.handleIdentityCommandCallback(query)
+	} else if strings.HasPrefix(data, "import_confirm:") || strings.HasPrefix(data, "import_cancel:") {
+		// 处理群组配置导入的确认和取消
+		return h.handleImportCallback(query)
+	} else if strings.HasPrefix(data, "pending:") {
+		// 处理待处理申请列表的翻页和审核
+		return h.handlePendingCallback(query)
+	} else if strings.HasPrefix(data, "verify:") {
+		// 处理所有权验证的重新检查
+		return h.handleOwnershipCallback(query)
+	} else if strings.HasPrefix(data, "rejreason:") {
+		// 处理拒绝理由的选择
+		return h.handleRejectReasonCallback(query)
 	} else if strings.HasPrefix(data, "approve:") || strings.HasPrefix(data, "reject:") {
 		// 处理批准/拒绝申请
-		isApprove := strings.HasPrefix(data, "approve:")
-
-		// 解析数据
-		parts := strings.Split(data, ":")
-		if len(parts) != 3 {
-			return fmt.Errorf("无效的回调数据: %s", data)
-		}
-
-		// 解析群组ID和频道ID
-		chatID, err := strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			return err
-		}
-
-		channelID, err := strconv.ParseInt(parts[2], 10, 64)
-		if err != nil {
-			return err
-		}
-
-		// 检查是否是管理员
-		isGlobalAdmin := utils.IsGlobalAdmin(h.Config.AdminUsers, query.From.ID)
-		if !isGlobalAdmin {
-			isAdmin, err := utils.IsAdmin(h.Bot, chatID, query.From.ID)
-			if err != nil || !isAdmin {
-				callback := tgbotapi.NewCallback(query.ID, "您没有权限执行此操作")
-				_, _ = h.Bot.Request(callback)
-				return nil
-			}
-		}
-
-		// 获取所有待处理的申请
-		applications, err := h.DB.GetPendingApplications()
-		if err != nil {
-			callback := tgbotapi.NewCallback(query.ID, "获取申请失败")
-			_, _ = h.Bot.Request(callback)
-			return err
-		}
-
-		// 查找对应的申请
-		var targetApp models.ChannelApplication
-		for _, app := range applications {
-			if app.ChatID == chatID && app.ChannelID == channelID && app.VerifiedChannel {
-				targetApp = app
-				break
-			}
-		}
-
-		if targetApp.ID == 0 {
-			callback := tgbotapi.NewCallback(query.ID, "未找到该频道的待处理申请或该申请未经过认领验证")
-			_, _ = h.Bot.Request(callback)
-			return nil
-		}
-
-		// 获取频道名称
-		channelName := "未知频道"
-		channelChat, err := h.Bot.GetChat(tgbotapi.ChatInfoConfig{
-			ChatConfig: tgbotapi.ChatConfig{
-				ChatID: channelID,
-			},
-		})
-		if err == nil && channelChat.Title != "" {
-			channelName = channelChat.Title
-		} else {
-			channelName = fmt.Sprintf("ID: %d", channelID)
-		}
-
-		if isApprove {
-			// 添加频道到白名单
-			err = h.DB.AddChannelToWhitelist(targetApp.ChatID, targetApp.ChannelID, targetApp.UserID, targetApp.Reason)
-			if err != nil {
-				callback := tgbotapi.NewCallback(query.ID, "添加频道到白名单失败")
-				_, _ = h.Bot.Request(callback)
-				return err
-			}
-
-			// 更新申请状态
-			err = h.DB.UpdateChannelApplicationStatus(targetApp.ChatID, targetApp.ChannelID, targetApp.UserID, "approved")
-			if err != nil {
-				callback := tgbotapi.NewCallback(query.ID, "更新申请状态失败")
-				_, _ = h.Bot.Request(callback)
-				return err
-			}
-
-			// 通知申请人
-			notifyText := fmt.Sprintf("您对频道「%s」的发言申请已被批准", channelName)
-			notifyMsg := tgbotapi.NewMessage(targetApp.UserID, notifyText)
-			_, _ = h.Bot.Send(notifyMsg)
-
-			// 通知群组
-			groupNotifyText := fmt.Sprintf("频道「%s」的发言申请已被批准", channelName)
-			groupMsg := tgbotapi.NewMessage(targetApp.ChatID, groupNotifyText)
-			_, _ = h.Bot.Send(groupMsg)
-
-			// 回复管理员
-			callback := tgbotapi.NewCallback(query.ID, fmt.Sprintf("已批准频道「%s」的发言申请", channelName))
-			_, err = h.Bot.Request(callback)
-
-			// 更新消息
-			editMsg := tgbotapi.NewEditMessageText(
-				query.Message.Chat.ID,
-				query.Message.MessageID,
-				fmt.Sprintf("您已批准频道「%s」的发言申请", channelName),
-			)
-			_, _ = h.Bot.Send(editMsg)
-
-			return err
-		} else {
-			// 更新申请状态
-			err = h.DB.UpdateChannelApplicationStatus(targetApp.ChatID, targetApp.ChannelID, targetApp.UserID, "rejected")
-			if err != nil {
-				callback := tgbotapi.NewCallback(query.ID, "更新申请状态失败")
-				_, _ = h.Bot.Request(callback)
-				return err
-			}
-
-			// 通知申请人
-			notifyText := fmt.Sprintf("您对频道「%s」的发言申请已被拒绝", channelName)
-			notifyMsg := tgbotapi.NewMessage(targetApp.UserID, notifyText)
-			_, _ = h.Bot.Send(notifyMsg)
-
-			// 通知群组
-			groupNotifyText := fmt.Sprintf("频道「%s」的发言申请已被拒绝", channelName)
-			groupMsg := tgbotapi.NewMessage(targetApp.ChatID, groupNotifyText)
-			_, _ = h.Bot.Send(groupMsg)
-
-			// 回复管理员
-			callback := tgbotapi.NewCallback(query.ID, fmt.Sprintf("已拒绝频道「%s」的发言申请", channelName))
-			_, err = h.Bot.Request(callback)
-
-			// 更新消息
-			editMsg := tgbotapi.NewEditMessageText(
-				query.Message.Chat.ID,
-				query.Message.MessageID,
-				fmt.Sprintf("您已拒绝频道「%s」的发言申请", channelName),
-			)
-			_, _ = h.Bot.Send(editMsg)
-
-			return err
-		}
+		return h.handleReviewCallback(query)
 	}
-
 	return nil
 }
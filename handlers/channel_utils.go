@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -22,71 +25,93 @@ func (h *Handler) getChannelName(channelID int64) string {
 	return channelName
 }
 
-// notifyAdminsAboutApplication 通知管理员有新的申请
-func (h *Handler) notifyAdminsAboutApplication(chatID, channelID, userID int64, channelName, reason string) error {
-	// 获取群组管理员
-	admins, err := h.Bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{
+// resolveChannel 将命令参数中的频道引用解析为频道ID和用户名。
+// @用户名和 t.me 链接通过 GetChat 查询频道ID；数字ID和 t.me/c 链接尽量查询用户名，查询失败时用户名为空
+func (h *Handler) resolveChannel(arg string) (int64, string, error) {
+	ref, err := utils.ParseChannelRef(arg)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if ref.Username == "" {
+		return ref.ID, h.getChannelUsername(ref.ID), nil
+	}
+
+	chat, err := h.Bot.GetChat(tgbotapi.ChatInfoConfig{
 		ChatConfig: tgbotapi.ChatConfig{
-			ChatID: chatID,
+			SuperGroupUsername: "@" + ref.Username,
 		},
 	})
 	if err != nil {
-		return err
+		return 0, "", fmt.Errorf("找不到频道 @%s", ref.Username)
+	}
+	if !chat.IsChannel() {
+		return 0, "", fmt.Errorf("@%s 不是频道", ref.Username)
 	}
+	return chat.ID, chat.UserName, nil
+}
 
-	// 获取申请用户信息
-	userChatConfig := tgbotapi.ChatInfoConfig{
+// channelFromFirstArg 解析命令参数中第一个字段的频道引用，其余字段留给调用方处理
+func (h *Handler) channelFromFirstArg(args string) (int64, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("频道不能为空")
+	}
+	channelID, _, err := h.resolveChannel(fields[0])
+	return channelID, err
+}
+
+// getChannelUsername 获取频道的公开用户名，私有频道或查询失败时返回空字符串
+func (h *Handler) getChannelUsername(channelID int64) string {
+	channelChat, err := h.Bot.GetChat(tgbotapi.ChatInfoConfig{
 		ChatConfig: tgbotapi.ChatConfig{
-			ChatID: userID,
+			ChatID: channelID,
 		},
+	})
+	if err != nil {
+		return ""
 	}
-	user, err := h.Bot.GetChat(userChatConfig)
+	return channelChat.UserName
+}
+
+// notifyAdminsAboutApplication 通知管理员有新的申请，通知中包含申请ID，管理员可以点击按钮或回复通知审核。
+// 发送的通知会被记录下来，用于显示投票进度
+func (h *Handler) notifyAdminsAboutApplication(chatID, channelID int64, channelName string) error {
+	app, err := h.DB.GetPendingChannelApplication(chatID, channelID)
 	if err != nil {
 		return err
 	}
+	if app.ID == 0 {
+		return fmt.Errorf("未找到该频道的待处理申请")
+	}
 
-	// 获取群组信息
-	chatConfig := tgbotapi.ChatInfoConfig{
+	// 获取群组管理员
+	admins, err := h.Bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{
 		ChatConfig: tgbotapi.ChatConfig{
 			ChatID: chatID,
 		},
-	}
-	chat, err := h.Bot.GetChat(chatConfig)
+	})
 	if err != nil {
 		return err
 	}
 
-	// 构建通知消息
-	userName := user.FirstName
-	if user.LastName != "" {
-		userName += " " + user.LastName
+	notifyText, err := h.applicationNoticeText(app, channelName)
+	if err != nil {
+		return err
 	}
-	if user.UserName != "" {
-		userName += " (@" + user.UserName + ")"
+	if settings, err := h.DB.GetOrCreateGroupSettings(chatID); err == nil && reviewQuorum(settings) > 1 {
+		notifyText += "\n\n" + formatReviewQuorum(reviewQuorum(settings))
 	}
 
-	notifyText := fmt.Sprintf("新的频道发言申请:\n\n"+
-		"群组: %s\n"+
-		"频道: %s (ID: %d)\n"+
-		"申请人: %s\n"+
-		"申请人ID: %d\n"+
-		"申请理由: %s\n\n"+
-		"请点击下方按钮批准或拒绝此申请",
-		chat.Title, channelName, channelID, userName, userID, reason)
-
-	// 创建确认/拒绝按钮
-	approveButton := tgbotapi.NewInlineKeyboardButtonData("✅ 批准", fmt.Sprintf("approve:%d:%d", chatID, channelID))
-	rejectButton := tgbotapi.NewInlineKeyboardButtonData("❌ 拒绝", fmt.Sprintf("reject:%d:%d", chatID, channelID))
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(approveButton, rejectButton),
-	)
-
-	// 通知所有管理员
+	// 通知群组管理员
 	for _, admin := range admins {
 		if !admin.User.IsBot {
 			msg := tgbotapi.NewMessage(admin.User.ID, notifyText)
-			msg.ReplyMarkup = keyboard
-			_, _ = h.Bot.Send(msg)
+			msg.ReplyMarkup = applicationNoticeKeyboard(app.ID)
+			sent, err := h.Bot.Send(msg)
+			if err == nil {
+				_ = h.DB.AddApplicationNotice(models.ApplicationNotice{ApplicationID: app.ID, ChatID: admin.User.ID, MessageID: sent.MessageID})
+			}
 		}
 	}
 
@@ -103,10 +128,65 @@ func (h *Handler) notifyAdminsAboutApplication(chatID, channelID, userID int64,
 
 		if !alreadyNotified {
 			msg := tgbotapi.NewMessage(adminID, notifyText)
-			msg.ReplyMarkup = keyboard
-			_, _ = h.Bot.Send(msg)
+			msg.ReplyMarkup = applicationNoticeKeyboard(app.ID)
+			sent, err := h.Bot.Send(msg)
+			if err == nil {
+				_ = h.DB.AddApplicationNotice(models.ApplicationNotice{ApplicationID: app.ID, ChatID: adminID, MessageID: sent.MessageID})
+			}
 		}
 	}
 
 	return nil
 }
+
+// applicationNoticeText 生成发送给管理员的申请通知内容
+func (h *Handler) applicationNoticeText(app models.ChannelApplication, channelName string) (string, error) {
+	// 获取申请用户信息
+	user, err := h.Bot.GetChat(tgbotapi.ChatInfoConfig{
+		ChatConfig: tgbotapi.ChatConfig{
+			ChatID: app.UserID,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 获取群组信息
+	chat, err := h.Bot.GetChat(tgbotapi.ChatInfoConfig{
+		ChatConfig: tgbotapi.ChatConfig{
+			ChatID: app.ChatID,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 构建通知消息
+	userName := user.FirstName
+	if user.LastName != "" {
+		userName += " " + user.LastName
+	}
+	if user.UserName != "" {
+		userName += " (@" + user.UserName + ")"
+	}
+
+	return fmt.Sprintf("新的频道发言申请:\n\n"+
+		"申请ID: %d\n"+
+		"群组: %s\n"+
+		"频道: %s (ID: %d)\n"+
+		"申请人: %s\n"+
+		"申请人ID: %d\n"+
+		"申请理由: %s\n\n"+
+		"请点击下方按钮批准或拒绝此申请，也可以回复此消息发送 /approve 或 /reject 并附带审核备注",
+		app.ID, chat.Title, channelName, app.ChannelID, userName, app.UserID, app.Reason), nil
+}
+
+// applicationNoticeKeyboard 申请通知中的批准和拒绝按钮
+func applicationNoticeKeyboard(applicationID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ 批准", fmt.Sprintf("approve:%d", applicationID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ 拒绝", fmt.Sprintf("reject:%d", applicationID)),
+		),
+	)
+}
@@ -31,6 +31,10 @@ func (h *Handler) SetupCommands() {
 			Command:     "claim",
 			Description: "认领频道申请（由个人账号发送）",
 		},
+		{
+			Command:     "wlset",
+			Description: "管理共享白名单（仅私聊）",
+		},
 	}
 
 	// 管理员可见的命令
@@ -55,6 +59,78 @@ func (h *Handler) SetupCommands() {
 			Command:     "settings",
 			Description: "配置群组设置",
 		},
+		{
+			Command:     "retention",
+			Description: "查看/设置被阻止消息的保留天数",
+		},
+		{
+			Command:     "export",
+			Description: "导出群组白名单和设置",
+		},
+		{
+			Command:     "import",
+			Description: "导入群组白名单和设置（回复导出文件）",
+		},
+		{
+			Command:     "audit",
+			Description: "查看管理操作审计日志",
+		},
+		{
+			Command:     "globallists",
+			Description: "设置本群是否应用全局白名单和黑名单",
+		},
+		{
+			Command:     "linkedadmin",
+			Description: "设置是否将关联频道身份视为管理员",
+		},
+		{
+			Command:     "linkedchannel",
+			Description: "查看关联频道并设置变更时的处理方式",
+		},
+		{
+			Command:     "observe",
+			Description: "设置观察模式，只记录不删除",
+		},
+		{
+			Command:     "sban",
+			Description: "封禁频道",
+		},
+		{
+			Command:     "sunban",
+			Description: "解除频道封禁",
+		},
+		{
+			Command:     "sbanlist",
+			Description: "列出被封禁的频道",
+		},
+		{
+			Command:     "autoban",
+			Description: "设置频道多次违规后自动封禁",
+		},
+		{
+			Command:     "escalation",
+			Description: "设置违规处罚升级规则",
+		},
+		{
+			Command:     "quota",
+			Description: "设置白名单频道的发言配额",
+		},
+		{
+			Command:     "policy",
+			Description: "设置白名单频道的内容限制",
+		},
+		{
+			Command:     "subscribe",
+			Description: "查看/订阅共享白名单",
+		},
+		{
+			Command:     "unsubscribe",
+			Description: "取消订阅共享白名单",
+		},
+		{
+			Command:     "history",
+			Description: "查看频道的申请记录",
+		},
 		{
 			Command:     "approve",
 			Description: "批准频道申请",
@@ -63,6 +139,42 @@ func (h *Handler) SetupCommands() {
 			Command:     "reject",
 			Description: "拒绝频道申请",
 		},
+		{
+			Command:     "pending",
+			Description: "查看待处理的申请",
+		},
+		{
+			Command:     "quorum",
+			Description: "设置批准申请所需的管理员票数",
+		},
+		{
+			Command:     "cooldown",
+			Description: "设置申请被拒绝后重新申请的冷却时间",
+		},
+		{
+			Command:     "apptimeout",
+			Description: "设置待处理申请的超时时间",
+		},
+		{
+			Command:     "ownership",
+			Description: "设置认领申请时的频道所有权验证",
+		},
+	}
+
+	// 仅全局管理员可见的命令
+	globalAdminCommands := []tgbotapi.BotCommand{
+		{
+			Command:     "backup",
+			Description: "备份数据库（仅私聊）",
+		},
+		{
+			Command:     "gwl",
+			Description: "管理全局白名单（仅私聊）",
+		},
+		{
+			Command:     "gbl",
+			Description: "管理全局黑名单（仅私聊）",
+		},
 	}
 
 	// 设置所有用户可见的命令
@@ -75,6 +187,7 @@ func (h *Handler) SetupCommands() {
 
 	// 尝试为管理员单独设置命令，如果API支持的话
 	fullCommandList := append(publicCommands, adminCommands...)
+	fullCommandList = append(fullCommandList, globalAdminCommands...)
 	for _, adminID := range h.Config.AdminUsers {
 		adminScope := tgbotapi.NewBotCommandScopeChat(adminID)
 		adminCommandsConfig := tgbotapi.NewSetMyCommandsWithScope(adminScope, fullCommandList...)
@@ -92,24 +205,40 @@ func (h *Handler) HandleCommand(message *tgbotapi.Message) error {
 	command := message.Command()
 	args := message.CommandArguments()
 
+	// 匿名管理员或以关联频道身份发送的命令，只读命令直接执行，其余命令需要管理员确认
+	if h.isIdentityAdmin(message) {
+		return h.handleIdentityAdminCommand(message, command, args)
+	}
+
 	// 检查是否是频道消息
 	if utils.IsChannelMessage(message) && message.Chat.Type != "private" {
 		// 获取频道ID
 		channelID := utils.GetChannelID(message)
 
-		// 检查频道是否在白名单中
-		isWhitelisted, err := h.DB.IsChannelWhitelisted(message.Chat.ID, channelID)
+		settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
 		if err != nil {
 			return err
 		}
 
-		// 如果不在白名单中且不是apply命令，删除消息并返回
-		if !isWhitelisted && command != "apply" {
+		// 检查频道是否允许发言
+		verdict, err := h.checkChannel(message.Chat.ID, channelID, settings)
+		if err != nil {
+			return err
+		}
+
+		// 如果不允许发言且不是apply命令，或频道在全局黑名单中，删除消息并返回
+		if (verdict != verdictAllowed && command != "apply") || verdict == verdictBlacklisted {
+			// 观察模式下只记录本应删除的消息
+			if settings.ObserveMode {
+				go h.addObservedToMessageQueue(message.Chat.ID, channelID, message.MessageID, message.Text, verdict.blockReason())
+				return nil
+			}
+
 			// 删除消息
 			go h.deleteMessageWithTimeout(message.Chat.ID, message.MessageID)
 
-			// 记录被阻止的消息
-			go h.addToMessageQueue(message.Chat.ID, channelID, message.MessageID, message.Text)
+			// 记录被阻止的消息，与观察模式使用相同的原因
+			go h.addToMessageQueueWithReason(message.Chat.ID, channelID, message.MessageID, message.Text, verdict.blockReason())
 
 			return nil
 		}
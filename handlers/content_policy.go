@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anhe/tg-whitelist-bot/db"
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// contentRuleNames 内容限制的显示名称
+var contentRuleNames = map[string]string{
+	models.ContentTextOnly:   "仅文字",
+	models.ContentNoLinks:    "不允许链接",
+	models.ContentNoMedia:    "不允许媒体",
+	models.ContentNoForwards: "不允许转发",
+	models.ContentNoViaBot:   "不允许内联机器人",
+}
+
+// contentViolationNames 违反内容限制时提示的消息类型
+var contentViolationNames = map[string]string{
+	models.ContentTextOnly:   "非文字内容",
+	models.ContentNoLinks:    "链接",
+	models.ContentNoMedia:    "媒体",
+	models.ContentNoForwards: "转发内容",
+	models.ContentNoViaBot:   "内联机器人发送的内容",
+}
+
+// policyUsage /policy 命令的用法
+const policyUsage = "用法:\n" +
+	"/policy 频道 - 查看频道的内容限制\n" +
+	"/policy 频道 --no-links --no-media - 设置频道的内容限制，会替换原有的限制\n" +
+	"/policy 频道 off - 取消频道的内容限制\n\n" +
+	"可用的限制: --text-only（仅文字）、--no-links（不允许链接）、--no-media（不允许媒体）、" +
+	"--no-forwards（不允许转发）、--no-via-bot（不允许内联机器人）。也可以回复频道消息使用，" +
+	"添加白名单时同样可以附带，如 /wl @频道用户名 --no-links"
+
+// contentRuleFlag 返回内容限制对应的命令参数，如 no_links 对应 --no-links
+func contentRuleFlag(rule string) string {
+	return "--" + strings.ReplaceAll(rule, "_", "-")
+}
+
+// splitContentPolicyFlags 从参数中取出以 -- 开头的内容限制，返回剩余参数和限制
+func splitContentPolicyFlags(args string) (string, models.ContentPolicy, error) {
+	var rest, rules []string
+	for _, field := range strings.Fields(args) {
+		if !strings.HasPrefix(field, "--") {
+			rest = append(rest, field)
+			continue
+		}
+
+		rule := strings.ReplaceAll(strings.TrimPrefix(strings.ToLower(field), "--"), "-", "_")
+		if _, ok := contentRuleNames[rule]; !ok {
+			return "", nil, fmt.Errorf("无法识别的内容限制: %s", field)
+		}
+		rules = append(rules, rule)
+	}
+	return strings.Join(rest, " "), models.ParseContentPolicy(strings.Join(rules, ",")), nil
+}
+
+// formatContentPolicy 格式化频道的内容限制
+func formatContentPolicy(policy models.ContentPolicy) string {
+	if len(policy) == 0 {
+		return "不限制"
+	}
+
+	names := make([]string, 0, len(policy))
+	for _, rule := range policy {
+		names = append(names, contentRuleNames[rule])
+	}
+	return strings.Join(names, "、")
+}
+
+// hasLinkEntity 检查消息实体中是否包含链接
+func hasLinkEntity(entities []tgbotapi.MessageEntity) bool {
+	for _, entity := range entities {
+		if entity.Type == "url" || entity.Type == "text_link" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMedia 检查消息是否包含图片、视频、文件等媒体
+func hasMedia(message *tgbotapi.Message) bool {
+	return message.Photo != nil || message.Video != nil || message.Document != nil ||
+		message.Animation != nil || message.Audio != nil || message.Voice != nil || message.VideoNote != nil
+}
+
+// violatedContentRule 返回消息违反的第一条内容限制，没有违反时返回空字符串
+func violatedContentRule(message *tgbotapi.Message, policy models.ContentPolicy) string {
+	for _, rule := range policy {
+		var violated bool
+		switch rule {
+		case models.ContentTextOnly:
+			// 媒体消息的文字在 Caption 中，Text 为空
+			violated = message.Text == ""
+		case models.ContentNoLinks:
+			violated = hasLinkEntity(message.Entities) || hasLinkEntity(message.CaptionEntities)
+		case models.ContentNoMedia:
+			violated = hasMedia(message)
+		case models.ContentNoForwards:
+			// 关联频道自动转发到群组的消息不算转发
+			violated = !message.IsAutomaticForward && (message.ForwardFromChat != nil || message.ForwardFrom != nil ||
+				message.ForwardSenderName != "" || message.ForwardDate != 0)
+		case models.ContentNoViaBot:
+			violated = message.ViaBot != nil
+		}
+		if violated {
+			return rule
+		}
+	}
+	return ""
+}
+
+// enforceContentPolicy 检查白名单频道的消息是否违反内容限制。违反时删除消息，
+// 以违反内容限制为原因记录被阻止的消息，并每天提示一次，返回 true。
+// 观察模式下违反限制的消息只记录，不删除也不提示
+func (h *Handler) enforceContentPolicy(message *tgbotapi.Message, channel models.WhitelistedChannel, observe bool) (bool, error) {
+	rule := violatedContentRule(message, channel.ContentPolicy)
+	if rule == "" {
+		return false, nil
+	}
+
+	chatID := message.Chat.ID
+	if observe {
+		go h.addObservedToMessageQueue(chatID, channel.ChannelID, message.MessageID, message.Text, models.BlockReasonContentPolicy)
+		return true, nil
+	}
+
+	go h.deleteMessageWithTimeout(chatID, message.MessageID)
+	go h.addToMessageQueueWithReason(chatID, channel.ChannelID, message.MessageID, message.Text, models.BlockReasonContentPolicy)
+
+	// 每个频道每天只提示一次
+	noticed, err := h.DB.HasChannelDailyPrompt(chatID, channel.ChannelID, db.PromptTypeContentNotice)
+	if err != nil || noticed {
+		return true, err
+	}
+
+	noticeText := fmt.Sprintf("频道「%s」的消息包含%s，已删除。本群对该频道的内容限制: %s",
+		h.getChannelName(channel.ChannelID), contentViolationNames[rule], formatContentPolicy(channel.ContentPolicy))
+	if _, err := h.Bot.Send(tgbotapi.NewMessage(chatID, noticeText)); err == nil {
+		_ = h.DB.RecordChannelDailyPrompt(chatID, channel.ChannelID, db.PromptTypeContentNotice)
+	}
+	return true, nil
+}
+
+// HandlePolicy 查看或设置白名单频道的内容限制，支持回复频道消息或提供频道
+func (h *Handler) HandlePolicy(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	args, policy, err := splitContentPolicyFlags(args)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("%s\n\n%s", err.Error(), policyUsage))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	fields := strings.Fields(args)
+
+	var channelID int64
+	if message.ReplyToMessage != nil && utils.IsChannelMessage(message.ReplyToMessage) {
+		channelID = utils.GetChannelID(message.ReplyToMessage)
+	} else if len(fields) > 0 {
+		channelID, _, err = h.resolveChannel(fields[0])
+		if err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的频道: %s", err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+		fields = fields[1:]
+	} else {
+		msg := tgbotapi.NewMessage(message.Chat.ID, policyUsage)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	channel, err := h.DB.GetWhitelistEntry(message.Chat.ID, channelID)
+	if err != nil {
+		return err
+	}
+	if channel.ID == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "该频道不在本群的白名单中，只能为白名单频道设置内容限制")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	channelName := h.getChannelName(channelID)
+	clearPolicy := len(fields) > 0 && strings.ToLower(fields[0]) == "off"
+
+	// 查看当前限制
+	if len(policy) == 0 && !clearPolicy {
+		text := fmt.Sprintf("频道「%s」的内容限制: %s\n\n%s", channelName, formatContentPolicy(channel.ContentPolicy), policyUsage)
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	if err := h.DB.SetChannelContentPolicy(message.Chat.ID, channelID, policy); err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("设置内容限制失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionPolicyUpdate, channelID,
+		formatContentPolicy(channel.ContentPolicy), formatContentPolicy(policy))
+
+	var text string
+	if len(policy) > 0 {
+		text = fmt.Sprintf("已将频道「%s」的内容限制设置为: %s", channelName, formatContentPolicy(policy))
+	} else {
+		text = fmt.Sprintf("已取消频道「%s」的内容限制", channelName)
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// channelVerdict 频道在群组中发言的检查结果
+type channelVerdict int
+
+const (
+	verdictAllowed        channelVerdict = iota // 允许发言
+	verdictNotWhitelisted                       // 不在白名单中，可以申请
+	verdictBlacklisted                          // 在全局黑名单中，不允许申请
+)
+
+// blockReason 返回未获准发言的频道消息被阻止的原因
+func (v channelVerdict) blockReason() string {
+	if v == verdictBlacklisted {
+		return models.BlockReasonBlacklisted
+	}
+	return models.BlockReasonNotWhitelisted
+}
+
+// checkChannel 检查频道能否在群组中发言。
+// 群组未关闭全局名单时，全局黑名单优先于群组白名单，全局白名单等同于群组白名单
+func (h *Handler) checkChannel(chatID, channelID int64, settings models.GroupSettings) (channelVerdict, error) {
+	if settings.UseGlobalLists {
+		listType, err := h.DB.GetGlobalListType(channelID)
+		if err != nil {
+			return verdictNotWhitelisted, err
+		}
+		switch listType {
+		case models.GlobalListBlacklist:
+			return verdictBlacklisted, nil
+		case models.GlobalListWhitelist:
+			return verdictAllowed, nil
+		}
+	}
+
+	isWhitelisted, err := h.DB.IsChannelWhitelisted(chatID, channelID)
+	if err != nil {
+		return verdictNotWhitelisted, err
+	}
+	if isWhitelisted {
+		return verdictAllowed, nil
+	}
+	return verdictNotWhitelisted, nil
+}
+
+// inGroupWhitelist 检查频道是否在群组自己的白名单中，不包括共享白名单和全局白名单，已过期的条目视为不在白名单中。
+// 移除、导入等只操作群组自身条目的命令使用此检查，发言检查使用 checkChannel
+func (h *Handler) inGroupWhitelist(chatID, channelID int64) (bool, error) {
+	channel, err := h.DB.GetWhitelistEntry(chatID, channelID)
+	if err != nil || channel.ID == 0 {
+		return false, err
+	}
+	return !channel.IsTemporary() || channel.ExpiresAt.After(time.Now()), nil
+}
+
+// enforceWhitelistEntry 按群组白名单条目的内容限制和发言配额检查允许发言的频道消息，
+// 消息被删除（观察模式下为本应删除）时返回 true。频道不在群组自己的白名单中（如通过共享白名单放行）时不做限制
+func (h *Handler) enforceWhitelistEntry(message *tgbotapi.Message, channelID int64, settings models.GroupSettings) (bool, error) {
+	channel, err := h.DB.GetWhitelistEntry(message.Chat.ID, channelID)
+	if err != nil || channel.ID == 0 {
+		return false, err
+	}
+
+	// 先检查内容限制，被删除的消息不占用发言配额
+	if blocked, err := h.enforceContentPolicy(message, channel, settings.ObserveMode); err != nil || blocked {
+		return blocked, err
+	}
+	return h.enforceQuota(message, channel, settings.ObserveMode)
+}
@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxEscalationSteps 每个群组最多设置的处罚升级规则条数
+const maxEscalationSteps = 10
+
+// defaultEscalationWindowHours 群组未设置统计窗口时使用的小时数
+const defaultEscalationWindowHours = 24
+
+// escalationWindowChoices 设置菜单中可选的统计窗口（小时）
+var escalationWindowChoices = []int{1, 6, 24, 72, 168, 720}
+
+// escalationThresholdChoices 设置菜单中可选的触发次数
+var escalationThresholdChoices = []int{1, 2, 3, 5, 10, 15, 20, 30, 50, 100}
+
+// escalationActionChoice 设置菜单中可选的处理方式，Hours 为封禁时长，0 表示永久封禁
+type escalationActionChoice struct {
+	Action string
+	Hours  int
+}
+
+// escalationActionChoices 设置菜单中可选的处理方式
+var escalationActionChoices = []escalationActionChoice{
+	{Action: models.EscalationWarn},
+	{Action: models.EscalationDelete},
+	{Action: models.EscalationBan, Hours: 1},
+	{Action: models.EscalationBan, Hours: 24},
+	{Action: models.EscalationBan, Hours: 168},
+	{Action: models.EscalationBan},
+}
+
+// validEscalationAction 检查处理方式是否是菜单中提供的选项
+func validEscalationAction(action string, hours int) bool {
+	for _, choice := range escalationActionChoices {
+		if choice.Action == action && choice.Hours == hours {
+			return true
+		}
+	}
+	return false
+}
+
+// escalationWindow 返回群组统计违规次数的时间窗口
+func escalationWindow(settings models.GroupSettings) time.Duration {
+	hours := settings.EscalationWindowHours
+	if hours <= 0 {
+		hours = defaultEscalationWindowHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// formatEscalationAction 返回处理方式的显示文本
+func formatEscalationAction(action string, duration time.Duration) string {
+	switch action {
+	case models.EscalationWarn:
+		return "删除并警告"
+	case models.EscalationDelete:
+		return "静默删除"
+	case models.EscalationBan:
+		if duration <= 0 {
+			return "永久封禁"
+		}
+		return "封禁" + utils.FormatDuration(duration)
+	default:
+		return action
+	}
+}
+
+// formatEscalationSteps 将处罚升级规则格式化为一行文本，用于设置显示和审计日志
+func formatEscalationSteps(steps []models.EscalationStep) string {
+	if len(steps) == 0 {
+		return "未设置"
+	}
+
+	parts := make([]string, 0, len(steps))
+	for _, step := range steps {
+		parts = append(parts, fmt.Sprintf("第%d次%s", step.Threshold, formatEscalationAction(step.Action, step.Duration)))
+	}
+	return strings.Join(parts, "，")
+}
+
+// handleViolation 处理不在白名单中的频道发送的普通消息，调用前消息已被删除。
+// 群组没有设置处罚升级规则，或统计窗口内的违规次数还未达到第一级时，每天提示一次申请方法；
+// 否则按违规次数适用的规则处理
+func (h *Handler) handleViolation(message *tgbotapi.Message, channelID int64, settings models.GroupSettings) error {
+	chatID := message.Chat.ID
+
+	// 记录被阻止的消息
+	defer h.addToMessageQueue(chatID, channelID, message.MessageID, message.Text)
+
+	steps, err := h.DB.GetEscalationSteps(chatID)
+	if err != nil {
+		return err
+	}
+
+	window := escalationWindow(settings)
+	count := 0
+	if len(steps) > 0 {
+		// 解除封禁之前的记录不再计入，否则解封后的第一条消息就会再次触发封禁
+		since := time.Now().Add(-window)
+		unbannedAt, err := h.DB.GetSenderChatUnbanTime(chatID, channelID)
+		if err != nil {
+			return err
+		}
+		if unbannedAt.After(since) {
+			since = unbannedAt
+		}
+
+		count, err = h.DB.CountBlockedMessagesSince(chatID, channelID, since)
+		if err != nil {
+			return err
+		}
+		// 加上队列中尚未写入的消息和当前这条消息
+		count += h.queuedMessageCount(chatID, channelID, false) + 1
+	}
+
+	step, ok := models.MatchEscalationStep(steps, count)
+	if !ok {
+		return h.promptApplyOncePerDay(chatID, channelID)
+	}
+
+	channelName := h.getChannelName(channelID)
+	switch step.Action {
+	case models.EscalationWarn:
+		warnText := fmt.Sprintf("频道「%s」未在白名单中，已删除消息（%s内第 %d 次）。\n\n"+
+			"频道可以直接发送 /apply + 申请理由 命令申请允许发言。", channelName, utils.FormatDuration(window), count)
+		_, _ = h.Bot.Send(tgbotapi.NewMessage(chatID, warnText))
+	case models.EscalationBan:
+		banned, err := h.DB.IsSenderChatBanned(chatID, channelID)
+		if err != nil || banned {
+			return err
+		}
+
+		var expiresAt time.Time
+		if step.Duration > 0 {
+			expiresAt = time.Now().Add(step.Duration)
+		}
+		reason := fmt.Sprintf("%s内被阻止的消息达到 %d 条", utils.FormatDuration(window), count)
+		if err := h.banSenderChat(chatID, channelID, 0, reason, expiresAt); err != nil {
+			fmt.Printf("按处罚升级规则封禁频道 %d 失败: %s\n", channelID, err.Error())
+			return nil
+		}
+
+		notifyText := fmt.Sprintf("频道「%s」%s，已%s。\n\n管理员可以使用 /sunban %d 解除封禁",
+			channelName, reason, formatEscalationAction(step.Action, step.Duration), channelID)
+		_, _ = h.Bot.Send(tgbotapi.NewMessage(chatID, notifyText))
+	}
+
+	return nil
+}
+
+// promptApplyOncePerDay 提示频道如何申请发言，每个频道每天只提示一次
+func (h *Handler) promptApplyOncePerDay(chatID, channelID int64) error {
+	// 检查今天是否已经提示过"需要申请"
+	hasPrompted, _ := h.DB.HasPromptedToday(chatID, channelID)
+	if hasPrompted {
+		return nil
+	}
+
+	channelName := h.getChannelName(channelID)
+	promptText := fmt.Sprintf("频道「%s」未在白名单中，已删除消息。\n\n"+
+		"频道可以直接发送 /apply + 申请理由 命令申请允许发言。", channelName)
+	promptMsg := tgbotapi.NewMessage(chatID, promptText)
+	if _, err := h.Bot.Send(promptMsg); err == nil {
+		// 记录已提示过"需要申请"
+		h.DB.RecordPrompt(chatID, channelID)
+	}
+	return nil
+}
+
+// HandleEscalation 打开处罚升级规则的设置菜单
+func (h *Handler) HandleEscalation(message *tgbotapi.Message, _ string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	text, markup, err := h.buildEscalationMenu(message.Chat.ID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("获取处罚升级规则失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyMarkup = markup
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// buildEscalationMenu 生成处罚升级规则的主菜单
+func (h *Handler) buildEscalationMenu(chatID int64) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	settings, err := h.DB.GetOrCreateGroupSettings(chatID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	steps, err := h.DB.GetEscalationSteps(chatID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	text := fmt.Sprintf("⚖️ 处罚升级规则\n\n统计窗口: %s\n\n", utils.FormatDuration(escalationWindow(settings)))
+	if len(steps) == 0 {
+		text += "未设置规则，不在白名单中的频道发送的消息会被删除，每天提示一次申请方法。\n"
+	} else {
+		for i, step := range steps {
+			text += fmt.Sprintf("%d. 第 %d 次违规起: %s\n", i+1, step.Threshold, formatEscalationAction(step.Action, step.Duration))
+		}
+		text += "\n违规次数未达到第一条规则时，每天提示一次申请方法。\n"
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, step := range steps {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("🗑 删除第 %d 次的规则", step.Threshold), fmt.Sprintf("esc:%d:del:%d", chatID, step.Threshold))))
+	}
+
+	controls := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⏱ 统计窗口", fmt.Sprintf("esc:%d:win", chatID)),
+	)
+	if len(steps) < maxEscalationSteps {
+		controls = append([]tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData("➕ 添加规则", fmt.Sprintf("esc:%d:add", chatID)),
+		}, controls...)
+	}
+	rows = append(rows, controls)
+
+	if len(steps) > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("清空规则", fmt.Sprintf("esc:%d:clear", chatID))))
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// buildEscalationActionMenu 生成添加规则时选择处理方式的菜单
+func buildEscalationActionMenu(chatID int64) (string, tgbotapi.InlineKeyboardMarkup) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, choice := range escalationActionChoices {
+		label := formatEscalationAction(choice.Action, time.Duration(choice.Hours)*time.Hour)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label,
+			fmt.Sprintf("esc:%d:act:%s:%d", chatID, choice.Action, choice.Hours)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", fmt.Sprintf("esc:%d:view", chatID))))
+
+	return "➕ 添加规则\n\n请选择处理方式:", tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// buildEscalationThresholdMenu 生成添加规则时选择触发次数的菜单，已有规则的次数不再列出
+func (h *Handler) buildEscalationThresholdMenu(chatID int64, action string, hours int) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	steps, err := h.DB.GetEscalationSteps(chatID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	used := make(map[int]bool)
+	for _, step := range steps {
+		used[step.Threshold] = true
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, threshold := range escalationThresholdChoices {
+		if used[threshold] {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("第 %d 次", threshold),
+			fmt.Sprintf("esc:%d:new:%s:%d:%d", chatID, action, hours, threshold)))
+		if len(row) == 5 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", fmt.Sprintf("esc:%d:add", chatID))))
+
+	text := fmt.Sprintf("➕ 添加规则: %s\n\n请选择从统计窗口内的第几次违规起采用该处理方式:",
+		formatEscalationAction(action, time.Duration(hours)*time.Hour))
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// buildEscalationWindowMenu 生成选择统计窗口的菜单
+func buildEscalationWindowMenu(chatID int64) (string, tgbotapi.InlineKeyboardMarkup) {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, hours := range escalationWindowChoices {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(utils.FormatDuration(time.Duration(hours)*time.Hour),
+			fmt.Sprintf("esc:%d:setwin:%d", chatID, hours)))
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(
+		row[:3],
+		row[3:],
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ 返回", fmt.Sprintf("esc:%d:view", chatID))),
+	)
+	return "⏱ 统计窗口\n\n违规次数按最近多长时间内被阻止的消息计算:", markup
+}
+
+// handleEscalationCallback 处理处罚升级规则设置菜单的按钮
+func (h *Handler) handleEscalationCallback(query *tgbotapi.CallbackQuery) error {
+	parts := strings.Split(query.Data, ":")
+	if len(parts) < 3 {
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
+	}
+
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	// 只有群组管理员可以修改规则
+	if !utils.IsGlobalAdmin(h.Config.AdminUsers, query.From.ID) {
+		isAdmin, err := utils.IsAdmin(h.Bot, chatID, query.From.ID)
+		if err != nil {
+			return err
+		}
+		if !isAdmin {
+			callback := tgbotapi.NewCallback(query.ID, "只有群组管理员可以修改处罚升级规则")
+			_, _ = h.Bot.Request(callback)
+			return nil
+		}
+	}
+
+	// 解析第 index 个整数参数
+	intArg := func(index int) (int, error) {
+		if len(parts) <= index {
+			return 0, fmt.Errorf("无效的回调数据: %s", query.Data)
+		}
+		return strconv.Atoi(parts[index])
+	}
+
+	var text string
+	var markup tgbotapi.InlineKeyboardMarkup
+	notice := ""
+
+	switch parts[2] {
+	case "view":
+		text, markup, err = h.buildEscalationMenu(chatID)
+	case "add":
+		text, markup = buildEscalationActionMenu(chatID)
+	case "act":
+		hours, argErr := intArg(4)
+		if argErr != nil || !validEscalationAction(parts[3], hours) {
+			return fmt.Errorf("无效的回调数据: %s", query.Data)
+		}
+		text, markup, err = h.buildEscalationThresholdMenu(chatID, parts[3], hours)
+	case "new":
+		hours, argErr := intArg(4)
+		if argErr != nil || !validEscalationAction(parts[3], hours) {
+			return fmt.Errorf("无效的回调数据: %s", query.Data)
+		}
+		threshold, argErr := intArg(5)
+		if argErr != nil || threshold <= 0 {
+			return fmt.Errorf("无效的回调数据: %s", query.Data)
+		}
+		notice, err = h.updateEscalationSteps(chatID, query.From.ID, func(steps []models.EscalationStep) ([]models.EscalationStep, string) {
+			if len(steps) >= maxEscalationSteps {
+				return nil, fmt.Sprintf("最多只能设置 %d 条规则", maxEscalationSteps)
+			}
+			for _, step := range steps {
+				if step.Threshold == threshold {
+					return nil, fmt.Sprintf("第 %d 次的规则已存在", threshold)
+				}
+			}
+			return append(steps, models.EscalationStep{
+				ChatID:    chatID,
+				Threshold: threshold,
+				Action:    parts[3],
+				Duration:  time.Duration(hours) * time.Hour,
+			}), "已添加规则"
+		})
+		if err == nil {
+			text, markup, err = h.buildEscalationMenu(chatID)
+		}
+	case "del":
+		threshold, argErr := intArg(3)
+		if argErr != nil {
+			return argErr
+		}
+		notice, err = h.updateEscalationSteps(chatID, query.From.ID, func(steps []models.EscalationStep) ([]models.EscalationStep, string) {
+			for i, step := range steps {
+				if step.Threshold == threshold {
+					return append(steps[:i], steps[i+1:]...), "已删除规则"
+				}
+			}
+			return nil, "该规则不存在"
+		})
+		if err == nil {
+			text, markup, err = h.buildEscalationMenu(chatID)
+		}
+	case "clear":
+		notice, err = h.updateEscalationSteps(chatID, query.From.ID, func(steps []models.EscalationStep) ([]models.EscalationStep, string) {
+			return []models.EscalationStep{}, "已清空规则"
+		})
+		if err == nil {
+			text, markup, err = h.buildEscalationMenu(chatID)
+		}
+	case "win":
+		text, markup = buildEscalationWindowMenu(chatID)
+	case "setwin":
+		hours, argErr := intArg(3)
+		if argErr != nil {
+			return argErr
+		}
+		notice, err = h.setEscalationWindow(chatID, query.From.ID, hours)
+		if err == nil {
+			text, markup, err = h.buildEscalationMenu(chatID)
+		}
+	default:
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
+	}
+
+	if err != nil {
+		callback := tgbotapi.NewCallback(query.ID, "操作失败")
+		_, _ = h.Bot.Request(callback)
+		return err
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, notice)
+	_, _ = h.Bot.Request(callback)
+
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, markup)
+	_, err = h.Bot.Send(editMsg)
+	// 规则没有变化时菜单内容相同，忽略 Telegram 的未修改错误
+	if err != nil && strings.Contains(err.Error(), "message is not modified") {
+		return nil
+	}
+	return err
+}
+
+// updateEscalationSteps 读取群组的规则并交给 change 修改，change 返回 nil 表示不修改，
+// 第二个返回值为提示文本。修改后保存并记录审计日志
+func (h *Handler) updateEscalationSteps(chatID, actorID int64, change func([]models.EscalationStep) ([]models.EscalationStep, string)) (string, error) {
+	steps, err := h.DB.GetEscalationSteps(chatID)
+	if err != nil {
+		return "", err
+	}
+	before := formatEscalationSteps(steps)
+
+	updated, notice := change(append([]models.EscalationStep(nil), steps...))
+	if updated == nil {
+		return notice, nil
+	}
+
+	if err := h.DB.SetEscalationSteps(chatID, updated); err != nil {
+		return "", err
+	}
+
+	updated, err = h.DB.GetEscalationSteps(chatID)
+	if err != nil {
+		return "", err
+	}
+	h.audit(chatID, actorID, models.AuditActionSettingsUpdate, 0,
+		"处罚升级: "+before, "处罚升级: "+formatEscalationSteps(updated))
+	return notice, nil
+}
+
+// setEscalationWindow 修改群组统计违规次数的时间窗口并记录审计日志
+func (h *Handler) setEscalationWindow(chatID, actorID int64, hours int) (string, error) {
+	if hours <= 0 {
+		return "无效的统计窗口", nil
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(chatID)
+	if err != nil {
+		return "", err
+	}
+
+	before := utils.FormatDuration(escalationWindow(settings))
+	settings.EscalationWindowHours = hours
+	if err := h.DB.UpdateGroupSettings(settings); err != nil {
+		return "", err
+	}
+	h.audit(chatID, actorID, models.AuditActionSettingsUpdate, 0,
+		"处罚升级统计窗口: "+before, "处罚升级统计窗口: "+utils.FormatDuration(escalationWindow(settings)))
+	return "已修改统计窗口", nil
+}
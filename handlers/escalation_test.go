@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+func TestEscalationBan(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	steps := []models.EscalationStep{
+		{Threshold: 2, Action: models.EscalationWarn},
+		{Threshold: 3, Action: models.EscalationBan},
+	}
+	if err := h.DB.SetEscalationSteps(testChatID, steps); err != nil {
+		t.Fatalf("设置处罚升级规则失败: %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if err := h.HandleMessage(channelMessage(10+i, testChannelID, "广告")); err != nil {
+			t.Fatalf("处理第 %d 条消息失败: %v", i, err)
+		}
+		banned, err := h.DB.IsSenderChatBanned(testChatID, testChannelID)
+		if err != nil {
+			t.Fatalf("检查封禁状态失败: %v", err)
+		}
+		if banned != (i == 3) {
+			t.Fatalf("第 %d 条消息后封禁状态为 %v", i, banned)
+		}
+	}
+	expectSent(t, fake, testChatID, "第 2 次")
+	if calls := fake.callsTo("banChatSenderChat"); len(calls) != 1 {
+		t.Fatalf("调用了 %d 次封禁接口，期望 1 次", len(calls))
+	}
+
+	// 解除封禁后重新计数，下一条消息不会再次触发封禁
+	h.flushMessageQueue()
+	if lifted, err := h.liftSenderBan(testChatID, testChannelID, testGroupAdminID); err != nil || !lifted {
+		t.Fatalf("解除封禁失败（%v）", err)
+	}
+	if err := h.HandleMessage(channelMessage(20, testChannelID, "广告")); err != nil {
+		t.Fatalf("处理解封后的消息失败: %v", err)
+	}
+	if banned, err := h.DB.IsSenderChatBanned(testChatID, testChannelID); err != nil || banned {
+		t.Fatalf("解封后的第一条消息再次触发了封禁（%v）", err)
+	}
+}
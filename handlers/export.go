@@ -0,0 +1,843 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// exportVersion 导出文件的格式版本，格式发生不兼容变化时递增
+const exportVersion = 1
+
+// maxImportFileSize 导入文件的大小上限
+const maxImportFileSize = 1 << 20
+
+// maxDiffLines 导入预览中每一类变更最多列出的条数
+const maxDiffLines = 20
+
+// groupExport 群组配置的导出文件格式
+type groupExport struct {
+	Version      int                 `json:"version"`
+	ExportedAt   time.Time           `json:"exported_at"`
+	SourceChatID int64               `json:"source_chat_id"`
+	Whitelist    []exportedChannel   `json:"whitelist"`
+	Settings     exportedSettings    `json:"settings"`
+	Applications []exportedApplicant `json:"applications"`
+	// 订阅的共享白名单名称，导入时跳过目标环境中不存在的名单
+	Subscriptions []string `json:"subscriptions,omitempty"`
+}
+
+// exportedChannel 导出的白名单频道
+type exportedChannel struct {
+	ChannelID   int64     `json:"channel_id"`
+	Description string    `json:"description"`
+	AddedBy     int64     `json:"added_by"`
+	AddedAt     time.Time `json:"added_at"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+	Username    string    `json:"username,omitempty"`
+
+	QuotaPosts         int    `json:"quota_posts,omitempty"`
+	QuotaWindowSeconds int64  `json:"quota_window_seconds,omitempty"`
+	ContentPolicy      string `json:"content_policy,omitempty"`
+}
+
+// quota 返回导出的发言配额，超出允许范围的配额视为不限制
+func (c exportedChannel) quota() models.WhitelistedChannel {
+	window := time.Duration(c.QuotaWindowSeconds) * time.Second
+	if c.QuotaPosts <= 0 || c.QuotaPosts > maxQuotaPosts || window < time.Minute || window > maxQuotaWindow {
+		return models.WhitelistedChannel{}
+	}
+	return models.WhitelistedChannel{QuotaPosts: c.QuotaPosts, QuotaWindow: window}
+}
+
+// expired 判断临时白名单条目是否已经过期
+func (c exportedChannel) expired() bool {
+	return !c.ExpiresAt.IsZero() && !c.ExpiresAt.After(time.Now())
+}
+
+// exportedSettings 导出的群组设置
+type exportedSettings struct {
+	AdminOnly     bool  `json:"admin_only"`
+	LogChannelID  int64 `json:"log_channel_id"`
+	Enabled       bool  `json:"enabled"`
+	RetentionDays int   `json:"retention_days"`
+	// 旧版本的导出文件没有该字段，为空时保持当前设置
+	UseGlobalLists *bool `json:"use_global_lists,omitempty"`
+	BanThreshold   *int  `json:"ban_threshold,omitempty"`
+
+	LinkedChannelAdmin *bool `json:"linked_channel_admin,omitempty"`
+	LinkedChannelAuto  *bool `json:"linked_channel_auto,omitempty"`
+	ObserveMode        *bool `json:"observe_mode,omitempty"`
+	ReviewQuorum       *int  `json:"review_quorum,omitempty"`
+
+	ReapplyCooldownSeconds   *int64 `json:"reapply_cooldown_seconds,omitempty"`
+	UnclaimedTimeoutSeconds  *int64 `json:"unclaimed_timeout_seconds,omitempty"`
+	UnreviewedTimeoutSeconds *int64 `json:"unreviewed_timeout_seconds,omitempty"`
+	// 以逗号分隔的所有权验证方式，空字符串表示不验证
+	OwnershipPolicy *string `json:"ownership_policy,omitempty"`
+
+	EscalationWindowHours *int `json:"escalation_window_hours,omitempty"`
+	// 旧版本的导出文件没有该字段，为 nil 时保持当前规则，空列表表示清除规则
+	Escalation []exportedEscalationStep `json:"escalation"`
+}
+
+// exportedEscalationStep 导出的处罚升级规则
+type exportedEscalationStep struct {
+	Threshold       int    `json:"threshold"`
+	Action          string `json:"action"`
+	DurationSeconds int64  `json:"duration_seconds"`
+}
+
+// exportedApplicant 导出的待处理频道申请
+type exportedApplicant struct {
+	ChannelID       int64     `json:"channel_id"`
+	UserID          int64     `json:"user_id"`
+	Reason          string    `json:"reason"`
+	AppliedAt       time.Time `json:"applied_at"`
+	VerifiedChannel bool      `json:"verified_channel"`
+}
+
+// HandleExport 导出当前群组的白名单、设置和待处理申请
+func (h *Handler) HandleExport(message *tgbotapi.Message, _ string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	export, err := h.buildGroupExport(message.Chat.ID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("导出群组配置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// 导出文件包含申请理由等信息，只私聊发送给发起导出的管理员
+	doc := tgbotapi.NewDocument(message.From.ID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("seer-export-%d-%s.json", message.Chat.ID, time.Now().Format("20060102-150405")),
+		Bytes: data,
+	})
+	doc.Caption = fmt.Sprintf("群组「%s」的配置导出（格式版本 %d）：白名单 %d 个，待处理申请 %d 个。\n\n在目标群组中发送此文件并附带 /import 说明即可导入。",
+		message.Chat.Title, export.Version, len(export.Whitelist), len(export.Applications))
+	if _, err := h.Bot.Send(doc); err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "⚠️ 无法发送私聊消息，请先私聊机器人后再导出")
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, "群组配置已通过私聊发送给您")
+	msg.ReplyToMessageID = message.MessageID
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// buildGroupExport 收集群组的可导出数据
+func (h *Handler) buildGroupExport(chatID int64) (groupExport, error) {
+	export := groupExport{
+		Version:      exportVersion,
+		ExportedAt:   time.Now(),
+		SourceChatID: chatID,
+		Whitelist:    []exportedChannel{},
+		Applications: []exportedApplicant{},
+	}
+
+	channels, err := h.DB.GetWhitelistedChannels(chatID)
+	if err != nil {
+		return export, err
+	}
+	for _, channel := range channels {
+		export.Whitelist = append(export.Whitelist, exportedChannel{
+			ChannelID:   channel.ChannelID,
+			Description: channel.Description,
+			AddedBy:     channel.AddedBy,
+			AddedAt:     channel.AddedAt,
+			ExpiresAt:   channel.ExpiresAt,
+			Username:    channel.Username,
+
+			QuotaPosts:         channel.QuotaPosts,
+			QuotaWindowSeconds: int64(channel.QuotaWindow / time.Second),
+			ContentPolicy:      channel.ContentPolicy.String(),
+		})
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(chatID)
+	if err != nil {
+		return export, err
+	}
+	reapplyCooldownSeconds := int64(settings.ReapplyCooldown / time.Second)
+	unclaimedTimeoutSeconds := int64(settings.UnclaimedTimeout / time.Second)
+	unreviewedTimeoutSeconds := int64(settings.UnreviewedTimeout / time.Second)
+	ownershipPolicy := settings.OwnershipPolicy.String()
+	export.Settings = exportedSettings{
+		AdminOnly:      settings.AdminOnly,
+		LogChannelID:   settings.LogChannelID,
+		Enabled:        settings.Enabled,
+		RetentionDays:  settings.RetentionDays,
+		UseGlobalLists: &settings.UseGlobalLists,
+		BanThreshold:   &settings.BanThreshold,
+
+		LinkedChannelAdmin: &settings.LinkedChannelAdmin,
+		LinkedChannelAuto:  &settings.LinkedChannelAuto,
+		ObserveMode:        &settings.ObserveMode,
+		ReviewQuorum:       &settings.ReviewQuorum,
+
+		ReapplyCooldownSeconds:   &reapplyCooldownSeconds,
+		UnclaimedTimeoutSeconds:  &unclaimedTimeoutSeconds,
+		UnreviewedTimeoutSeconds: &unreviewedTimeoutSeconds,
+		OwnershipPolicy:          &ownershipPolicy,
+
+		EscalationWindowHours: &settings.EscalationWindowHours,
+		Escalation:            []exportedEscalationStep{},
+	}
+
+	steps, err := h.DB.GetEscalationSteps(chatID)
+	if err != nil {
+		return export, err
+	}
+	for _, step := range steps {
+		export.Settings.Escalation = append(export.Settings.Escalation, exportedEscalationStep{
+			Threshold:       step.Threshold,
+			Action:          step.Action,
+			DurationSeconds: int64(step.Duration / time.Second),
+		})
+	}
+
+	sets, err := h.DB.GetSubscribedSets(chatID)
+	if err != nil {
+		return export, err
+	}
+	for _, set := range sets {
+		export.Subscriptions = append(export.Subscriptions, set.Name)
+	}
+
+	applications, err := h.DB.GetPendingApplications()
+	if err != nil {
+		return export, err
+	}
+	for _, app := range applications {
+		if app.ChatID != chatID {
+			continue
+		}
+		export.Applications = append(export.Applications, exportedApplicant{
+			ChannelID:       app.ChannelID,
+			UserID:          app.UserID,
+			Reason:          app.Reason,
+			AppliedAt:       app.AppliedAt,
+			VerifiedChannel: app.VerifiedChannel,
+		})
+	}
+
+	return export, nil
+}
+
+// HandleImport 处理回复导出文件的 /import 命令
+func (h *Handler) HandleImport(message *tgbotapi.Message, _ string) error {
+	if message.ReplyToMessage == nil || message.ReplyToMessage.Document == nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "请发送导出文件并附带 /import 说明，或回复导出文件发送 /import")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	return h.handleImportDocument(message, message.ReplyToMessage.Document)
+}
+
+// isImportCaption 检查文件说明是否为 /import 命令
+func (h *Handler) isImportCaption(message *tgbotapi.Message) bool {
+	fields := strings.Fields(message.Caption)
+	if message.Document == nil || len(fields) == 0 {
+		return false
+	}
+	return fields[0] == "/import" || fields[0] == "/import@"+h.Bot.Self.UserName
+}
+
+// handleImportDocument 下载并解析导出文件，发送导入预览并等待管理员确认
+func (h *Handler) handleImportDocument(message *tgbotapi.Message, document *tgbotapi.Document) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	if document.FileSize > maxImportFileSize {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "导入文件过大")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	export, err := h.downloadGroupExport(document.FileID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("读取导入文件失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	diff, err := h.describeImport(message.Chat.ID, export)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("生成导入预览失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	// 记录待确认的导入，确认时重新下载文件
+	err = h.DB.SetUserState(message.From.ID, fmt.Sprintf("import:%d:%s", message.Chat.ID, document.FileID))
+	if err != nil {
+		return err
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ 确认导入", fmt.Sprintf("import_confirm:%d", message.Chat.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ 取消", fmt.Sprintf("import_cancel:%d", message.Chat.ID)),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, diff)
+	msg.ReplyToMessageID = message.MessageID
+	msg.ReplyMarkup = keyboard
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// downloadGroupExport 下载并解析导出文件
+func (h *Handler) downloadGroupExport(fileID string) (groupExport, error) {
+	var export groupExport
+
+	url, err := h.Bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return export, err
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return export, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return export, fmt.Errorf("下载文件失败: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportFileSize+1))
+	if err != nil {
+		return export, err
+	}
+	if len(data) > maxImportFileSize {
+		return export, fmt.Errorf("导入文件过大")
+	}
+
+	if err := json.Unmarshal(data, &export); err != nil {
+		return export, fmt.Errorf("文件不是有效的导出文件: %w", err)
+	}
+	if export.Version < 1 || export.Version > exportVersion {
+		return export, fmt.Errorf("不支持的导出格式版本: %d", export.Version)
+	}
+	if export.Settings.Escalation != nil {
+		if _, err := importedEscalationSteps(0, export.Settings.Escalation); err != nil {
+			return export, err
+		}
+	}
+
+	return export, nil
+}
+
+// importedEscalationSteps 将导出的处罚升级规则转换为群组的规则，并检查规则是否有效
+func importedEscalationSteps(chatID int64, exported []exportedEscalationStep) ([]models.EscalationStep, error) {
+	if len(exported) > maxEscalationSteps {
+		return nil, fmt.Errorf("处罚升级规则超过 %d 条", maxEscalationSteps)
+	}
+
+	steps := make([]models.EscalationStep, 0, len(exported))
+	seen := make(map[int]bool)
+	for _, step := range exported {
+		switch step.Action {
+		case models.EscalationWarn, models.EscalationDelete, models.EscalationBan:
+		default:
+			return nil, fmt.Errorf("无效的处罚升级处理方式: %s", step.Action)
+		}
+		if step.Threshold <= 0 || seen[step.Threshold] || step.DurationSeconds < 0 {
+			return nil, fmt.Errorf("无效的处罚升级触发次数: %d", step.Threshold)
+		}
+		seen[step.Threshold] = true
+
+		steps = append(steps, models.EscalationStep{
+			ChatID:    chatID,
+			Threshold: step.Threshold,
+			Action:    step.Action,
+			Duration:  time.Duration(step.DurationSeconds) * time.Second,
+		})
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].Threshold < steps[j].Threshold
+	})
+	return steps, nil
+}
+
+// describeImport 生成导入预览，不修改任何数据
+func (h *Handler) describeImport(chatID int64, export groupExport) (string, error) {
+	var b strings.Builder
+
+	b.WriteString("📥 导入预览（尚未做任何修改）:\n\n")
+	b.WriteString(fmt.Sprintf("来源群组: %d\n导出时间: %s\n\n", export.SourceChatID, export.ExportedAt.Format("2006-01-02 15:04:05")))
+
+	// 白名单变更
+	var added, existing []exportedChannel
+	for _, channel := range export.Whitelist {
+		// 已过期的临时条目不导入
+		if channel.expired() {
+			continue
+		}
+		isWhitelisted, err := h.inGroupWhitelist(chatID, channel.ChannelID)
+		if err != nil {
+			return "", err
+		}
+		if isWhitelisted {
+			existing = append(existing, channel)
+		} else {
+			added = append(added, channel)
+		}
+	}
+	b.WriteString(fmt.Sprintf("白名单: 新增 %d 个，已存在 %d 个\n", len(added), len(existing)))
+	for i, channel := range added {
+		if i == maxDiffLines {
+			b.WriteString(fmt.Sprintf("  … 以及其他 %d 个\n", len(added)-maxDiffLines))
+			break
+		}
+		line := fmt.Sprintf("  + %d", channel.ChannelID)
+		if channel.Description != "" {
+			line += fmt.Sprintf("（%s）", channel.Description)
+		}
+		if !channel.ExpiresAt.IsZero() {
+			line += fmt.Sprintf(" 有效期至 %s", channel.ExpiresAt.Format("2006-01-02 15:04"))
+		}
+		if quota := channel.quota(); quota.HasQuota() {
+			line += " 发言配额: " + formatQuota(quota)
+		}
+		if policy := models.ParseContentPolicy(channel.ContentPolicy); len(policy) > 0 {
+			line += " 内容限制: " + formatContentPolicy(policy)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	// 设置变更
+	current, err := h.DB.GetOrCreateGroupSettings(chatID)
+	if err != nil {
+		return "", err
+	}
+	imported := applyExportedSettings(current, export.Settings)
+
+	var changes []string
+	if current.AdminOnly != imported.AdminOnly {
+		changes = append(changes, fmt.Sprintf("  管理权限: %s → %s", formatAdminOnly(current.AdminOnly), formatAdminOnly(imported.AdminOnly)))
+	}
+	if current.LogChannelID != imported.LogChannelID {
+		changes = append(changes, fmt.Sprintf("  日志频道: %d → %d", current.LogChannelID, imported.LogChannelID))
+	}
+	if current.Enabled != imported.Enabled {
+		changes = append(changes, fmt.Sprintf("  状态: %s → %s", formatEnabled(current.Enabled), formatEnabled(imported.Enabled)))
+	}
+	if current.RetentionDays != imported.RetentionDays {
+		changes = append(changes, fmt.Sprintf("  消息记录保留: %s → %s", h.formatRetention(current), h.formatRetention(imported)))
+	}
+	if current.UseGlobalLists != imported.UseGlobalLists {
+		changes = append(changes, fmt.Sprintf("  全局名单: %s → %s", formatUseGlobalLists(current.UseGlobalLists), formatUseGlobalLists(imported.UseGlobalLists)))
+	}
+	if current.BanThreshold != imported.BanThreshold {
+		changes = append(changes, fmt.Sprintf("  自动封禁: %s → %s", formatBanThreshold(current.BanThreshold), formatBanThreshold(imported.BanThreshold)))
+	}
+	if current.LinkedChannelAdmin != imported.LinkedChannelAdmin {
+		changes = append(changes, fmt.Sprintf("  关联频道身份: %s → %s", formatLinkedChannelAdmin(current.LinkedChannelAdmin), formatLinkedChannelAdmin(imported.LinkedChannelAdmin)))
+	}
+	if current.LinkedChannelAuto != imported.LinkedChannelAuto {
+		changes = append(changes, fmt.Sprintf("  关联频道变更: %s → %s", formatLinkedChannelAuto(current.LinkedChannelAuto), formatLinkedChannelAuto(imported.LinkedChannelAuto)))
+	}
+	if current.ObserveMode != imported.ObserveMode {
+		changes = append(changes, fmt.Sprintf("  观察模式: %s → %s", formatObserveMode(current.ObserveMode), formatObserveMode(imported.ObserveMode)))
+	}
+	if reviewQuorum(current) != reviewQuorum(imported) {
+		changes = append(changes, fmt.Sprintf("  申请审核: %s → %s", formatReviewQuorum(reviewQuorum(current)), formatReviewQuorum(reviewQuorum(imported))))
+	}
+	if current.ReapplyCooldown != imported.ReapplyCooldown {
+		changes = append(changes, fmt.Sprintf("  重新申请冷却: %s → %s", formatReapplyCooldown(current.ReapplyCooldown), formatReapplyCooldown(imported.ReapplyCooldown)))
+	}
+	if current.UnclaimedTimeout != imported.UnclaimedTimeout || current.UnreviewedTimeout != imported.UnreviewedTimeout {
+		changes = append(changes, fmt.Sprintf("  申请超时: %s → %s", formatApplicationTimeouts(current), formatApplicationTimeouts(imported)))
+	}
+	if current.OwnershipPolicy.String() != imported.OwnershipPolicy.String() {
+		changes = append(changes, fmt.Sprintf("  所有权验证: %s → %s", formatOwnershipPolicy(current.OwnershipPolicy), formatOwnershipPolicy(imported.OwnershipPolicy)))
+	}
+	if current.EscalationWindowHours != imported.EscalationWindowHours {
+		changes = append(changes, fmt.Sprintf("  处罚升级统计窗口: %s → %s",
+			utils.FormatDuration(escalationWindow(current)), utils.FormatDuration(escalationWindow(imported))))
+	}
+	if export.Settings.Escalation != nil {
+		currentSteps, err := h.DB.GetEscalationSteps(chatID)
+		if err != nil {
+			return "", err
+		}
+		importedSteps, err := importedEscalationSteps(chatID, export.Settings.Escalation)
+		if err != nil {
+			return "", err
+		}
+		if formatEscalationSteps(currentSteps) != formatEscalationSteps(importedSteps) {
+			changes = append(changes, fmt.Sprintf("  处罚升级: %s → %s", formatEscalationSteps(currentSteps), formatEscalationSteps(importedSteps)))
+		}
+	}
+	if len(changes) == 0 {
+		b.WriteString("\n设置: 无变化\n")
+	} else {
+		b.WriteString("\n设置变更:\n" + strings.Join(changes, "\n") + "\n")
+	}
+
+	// 申请变更
+	newApps, skippedApps := 0, 0
+	for _, app := range export.Applications {
+		hasApp, err := h.DB.HasPendingApplication(chatID, app.ChannelID)
+		if err != nil {
+			return "", err
+		}
+		if hasApp {
+			skippedApps++
+		} else {
+			newApps++
+		}
+	}
+	b.WriteString(fmt.Sprintf("\n待处理申请: 新增 %d 个，跳过 %d 个（已有待处理申请）\n", newApps, skippedApps))
+	if newApps > 0 {
+		b.WriteString("导入的申请需要申请人重新认领并验证频道所有权\n")
+	}
+
+	// 共享白名单订阅变更
+	if len(export.Subscriptions) > 0 {
+		subscribe, missing, err := h.importedSubscriptions(chatID, export.Subscriptions)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(fmt.Sprintf("\n共享白名单订阅: 新增 %d 个", len(subscribe)))
+		if len(missing) > 0 {
+			b.WriteString(fmt.Sprintf("，跳过 %d 个（名单不存在: %s）", len(missing), strings.Join(missing, "、")))
+		}
+		b.WriteString("\n")
+		for _, set := range subscribe {
+			b.WriteString(fmt.Sprintf("  + %s\n", set.Name))
+		}
+	}
+
+	b.WriteString("\n请点击下方按钮确认或取消导入")
+	return b.String(), nil
+}
+
+// applyExportedSettings 将导出的设置应用到当前群组设置上
+func applyExportedSettings(settings models.GroupSettings, exported exportedSettings) models.GroupSettings {
+	settings.AdminOnly = exported.AdminOnly
+	settings.LogChannelID = exported.LogChannelID
+	settings.Enabled = exported.Enabled
+	settings.RetentionDays = exported.RetentionDays
+	if exported.UseGlobalLists != nil {
+		settings.UseGlobalLists = *exported.UseGlobalLists
+	}
+	if exported.BanThreshold != nil {
+		settings.BanThreshold = *exported.BanThreshold
+	}
+	if exported.LinkedChannelAdmin != nil {
+		settings.LinkedChannelAdmin = *exported.LinkedChannelAdmin
+	}
+	if exported.LinkedChannelAuto != nil {
+		settings.LinkedChannelAuto = *exported.LinkedChannelAuto
+	}
+	// 导入时新开启的观察模式从导入时开始统计
+	if exported.ObserveMode != nil {
+		if *exported.ObserveMode && !settings.ObserveMode {
+			settings.ObserveSince = time.Now()
+		}
+		settings.ObserveMode = *exported.ObserveMode
+	}
+	if exported.ReviewQuorum != nil && *exported.ReviewQuorum >= 1 && *exported.ReviewQuorum <= maxReviewQuorum {
+		settings.ReviewQuorum = *exported.ReviewQuorum
+	}
+	if exported.ReapplyCooldownSeconds != nil {
+		cooldown := time.Duration(*exported.ReapplyCooldownSeconds) * time.Second
+		if cooldown >= 0 && cooldown <= maxReapplyCooldown {
+			settings.ReapplyCooldown = cooldown
+		}
+	}
+	if exported.UnclaimedTimeoutSeconds != nil {
+		timeout := time.Duration(*exported.UnclaimedTimeoutSeconds) * time.Second
+		if timeout >= 0 && timeout <= maxApplicationTimeout {
+			settings.UnclaimedTimeout = timeout
+		}
+	}
+	if exported.UnreviewedTimeoutSeconds != nil {
+		timeout := time.Duration(*exported.UnreviewedTimeoutSeconds) * time.Second
+		if timeout >= 0 && timeout <= maxApplicationTimeout {
+			settings.UnreviewedTimeout = timeout
+		}
+	}
+	if exported.OwnershipPolicy != nil {
+		settings.OwnershipPolicy = models.ParseOwnershipPolicy(*exported.OwnershipPolicy)
+	}
+	if exported.EscalationWindowHours != nil && *exported.EscalationWindowHours > 0 {
+		settings.EscalationWindowHours = *exported.EscalationWindowHours
+	}
+	return settings
+}
+
+// formatAdminOnly 格式化管理权限设置
+func formatAdminOnly(adminOnly bool) string {
+	if adminOnly {
+		return "仅管理员"
+	}
+	return "所有成员"
+}
+
+// formatEnabled 格式化启用状态
+func formatEnabled(enabled bool) string {
+	if enabled {
+		return "启用"
+	}
+	return "禁用"
+}
+
+// handleImportCallback 处理导入预览的确认和取消按钮
+func (h *Handler) handleImportCallback(query *tgbotapi.CallbackQuery) error {
+	parts := strings.Split(query.Data, ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
+	}
+
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	// 只有发起导入的管理员可以确认或取消
+	state, err := h.DB.GetUserState(query.From.ID)
+	if err != nil {
+		return err
+	}
+	prefix := fmt.Sprintf("import:%d:", chatID)
+	if !strings.HasPrefix(state, prefix) {
+		callback := tgbotapi.NewCallback(query.ID, "只有发起导入的管理员可以操作，或导入已过期")
+		_, _ = h.Bot.Request(callback)
+		return nil
+	}
+	fileID := strings.TrimPrefix(state, prefix)
+
+	if err := h.DB.ClearUserState(query.From.ID); err != nil {
+		return err
+	}
+
+	// 确认时重新检查权限，发起导入后被撤销管理员身份的用户不能再写入
+	if parts[0] == "import_confirm" && !utils.IsGlobalAdmin(h.Config.AdminUsers, query.From.ID) {
+		isAdmin, err := utils.IsAdmin(h.Bot, chatID, query.From.ID)
+		if err != nil {
+			return err
+		}
+		if !isAdmin {
+			callback := tgbotapi.NewCallback(query.ID, "只有群组管理员可以导入配置")
+			_, _ = h.Bot.Request(callback)
+
+			editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, "您已不是该群组的管理员，导入已取消")
+			_, err := h.Bot.Send(editMsg)
+			return err
+		}
+	}
+
+	if parts[0] == "import_cancel" {
+		callback := tgbotapi.NewCallback(query.ID, "已取消导入")
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, "已取消导入")
+		_, err := h.Bot.Send(editMsg)
+		return err
+	}
+
+	export, err := h.downloadGroupExport(fileID)
+	if err != nil {
+		callback := tgbotapi.NewCallback(query.ID, "读取导入文件失败")
+		_, _ = h.Bot.Request(callback)
+		return err
+	}
+
+	summary, err := h.applyGroupImport(chatID, query.From.ID, export)
+	if err != nil {
+		callback := tgbotapi.NewCallback(query.ID, "导入失败")
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, fmt.Sprintf("导入失败: %s\n\n%s", err.Error(), summary))
+		_, _ = h.Bot.Send(editMsg)
+		return err
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, "导入完成")
+	_, _ = h.Bot.Request(callback)
+
+	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, summary)
+	_, err = h.Bot.Send(editMsg)
+	return err
+}
+
+// applyGroupImport 将导出文件应用到群组，返回导入结果摘要
+func (h *Handler) applyGroupImport(chatID, actorID int64, export groupExport) (string, error) {
+	addedChannels, addedApps, addedSubscriptions := 0, 0, 0
+	summary := func() string {
+		text := fmt.Sprintf("📥 导入完成:\n\n白名单新增 %d 个\n待处理申请新增 %d 个", addedChannels, addedApps)
+		if len(export.Subscriptions) > 0 {
+			text += fmt.Sprintf("\n共享白名单订阅新增 %d 个", addedSubscriptions)
+		}
+		return text
+	}
+
+	// 导入白名单，已存在的频道保持不变
+	for _, channel := range export.Whitelist {
+		if channel.expired() {
+			continue
+		}
+		isWhitelisted, err := h.inGroupWhitelist(chatID, channel.ChannelID)
+		if err != nil {
+			return summary(), err
+		}
+		if isWhitelisted {
+			continue
+		}
+		if err := h.DB.AddChannelToWhitelist(chatID, channel.ChannelID, actorID, channel.Username, channel.Description, channel.ExpiresAt); err != nil {
+			return summary(), err
+		}
+		h.audit(chatID, actorID, models.AuditActionWhitelistAdd, channel.ChannelID, "", "导入")
+		if quota := channel.quota(); quota.HasQuota() {
+			if err := h.DB.SetChannelQuota(chatID, channel.ChannelID, quota.QuotaPosts, quota.QuotaWindow); err != nil {
+				return summary(), err
+			}
+		}
+		if policy := models.ParseContentPolicy(channel.ContentPolicy); len(policy) > 0 {
+			if err := h.DB.SetChannelContentPolicy(chatID, channel.ChannelID, policy); err != nil {
+				return summary(), err
+			}
+		}
+		addedChannels++
+	}
+
+	// 导入设置
+	settings, err := h.DB.GetOrCreateGroupSettings(chatID)
+	if err != nil {
+		return summary(), err
+	}
+	imported := applyExportedSettings(settings, export.Settings)
+	if err := h.DB.UpdateGroupSettings(imported); err != nil {
+		return summary(), err
+	}
+	h.audit(chatID, actorID, models.AuditActionImport, 0, formatSettingsForAudit(settings), formatSettingsForAudit(imported))
+
+	// 导入处罚升级规则
+	if export.Settings.Escalation != nil {
+		steps, err := importedEscalationSteps(chatID, export.Settings.Escalation)
+		if err != nil {
+			return summary(), err
+		}
+		currentSteps, err := h.DB.GetEscalationSteps(chatID)
+		if err != nil {
+			return summary(), err
+		}
+		if err := h.DB.SetEscalationSteps(chatID, steps); err != nil {
+			return summary(), err
+		}
+		h.audit(chatID, actorID, models.AuditActionImport, 0,
+			"处罚升级: "+formatEscalationSteps(currentSteps), "处罚升级: "+formatEscalationSteps(steps))
+	}
+
+	// 导入待处理申请，已有待处理申请或仍在重新申请冷却期内的频道跳过。
+	// 导出文件可以被任意修改，申请以未认领、未验证的状态导入，由申请人重新认领并验证频道所有权
+	for _, app := range export.Applications {
+		hasApp, err := h.DB.HasPendingApplication(chatID, app.ChannelID)
+		if err != nil {
+			return summary(), err
+		}
+		if hasApp {
+			continue
+		}
+		reapplyAt, err := h.DB.GetReapplyTime(chatID, app.ChannelID)
+		if err != nil {
+			return summary(), err
+		}
+		if time.Now().Before(reapplyAt) {
+			continue
+		}
+		if err := h.DB.CreateChannelApplication(chatID, app.ChannelID, 0, app.Reason); err != nil {
+			return summary(), err
+		}
+		addedApps++
+	}
+
+	// 导入共享白名单订阅，目标环境中不存在的名单跳过
+	if len(export.Subscriptions) > 0 {
+		subscribe, _, err := h.importedSubscriptions(chatID, export.Subscriptions)
+		if err != nil {
+			return summary(), err
+		}
+		for _, set := range subscribe {
+			if err := h.DB.SubscribeToSet(chatID, set.ID, actorID); err != nil {
+				return summary(), err
+			}
+			h.audit(chatID, actorID, models.AuditActionSetSubscribe, 0, "", set.Name)
+			addedSubscriptions++
+		}
+	}
+
+	return summary(), nil
+}
+
+// importedSubscriptions 找出导出文件中需要新订阅的共享白名单，以及不存在的名单名称
+func (h *Handler) importedSubscriptions(chatID int64, names []string) ([]models.WhitelistSet, []string, error) {
+	current, err := h.DB.GetSubscribedSets(chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	subscribed := make(map[int64]bool)
+	for _, set := range current {
+		subscribed[set.ID] = true
+	}
+
+	var subscribe []models.WhitelistSet
+	var missing []string
+	for _, name := range names {
+		set, err := h.DB.GetWhitelistSet(strings.ToLower(name))
+		if err != nil {
+			return nil, nil, err
+		}
+		if set.ID == 0 {
+			missing = append(missing, name)
+			continue
+		}
+		if !subscribed[set.ID] {
+			subscribed[set.ID] = true
+			subscribe = append(subscribe, set)
+		}
+	}
+	return subscribe, missing, nil
+}
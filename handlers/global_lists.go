@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// globalListNames 全局名单的显示名称
+var globalListNames = map[string]string{
+	models.GlobalListWhitelist: "全局白名单",
+	models.GlobalListBlacklist: "全局黑名单",
+}
+
+// HandleGlobalWhitelist 管理全局白名单
+func (h *Handler) HandleGlobalWhitelist(message *tgbotapi.Message, args string) error {
+	return h.handleGlobalList(message, args, models.GlobalListWhitelist, "gwl")
+}
+
+// HandleGlobalBlacklist 管理全局黑名单
+func (h *Handler) HandleGlobalBlacklist(message *tgbotapi.Message, args string) error {
+	return h.handleGlobalList(message, args, models.GlobalListBlacklist, "gbl")
+}
+
+// handleGlobalList 处理 /gwl 和 /gbl：不带参数时列出名单，add 添加频道，remove 移除频道
+func (h *Handler) handleGlobalList(message *tgbotapi.Message, args, listType, command string) error {
+	// 只允许私聊使用
+	if message.Chat.Type != "private" {
+		return nil
+	}
+
+	// 检查是否是全局管理员
+	if !utils.IsGlobalAdmin(h.Config.AdminUsers, message.From.ID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "只有全局管理员可以管理全局名单")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	listName := globalListNames[listType]
+	usage := fmt.Sprintf("用法:\n/%s - 查看%s\n/%s add 频道 [备注] - 添加频道\n/%s remove 频道 - 移除频道\n频道可以是频道ID、@用户名或 t.me 链接",
+		command, listName, command, command)
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return h.sendGlobalList(message.Chat.ID, listType, usage)
+	}
+
+	if len(fields) < 2 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, usage)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	channelID, _, err := h.resolveChannel(fields[1])
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的频道: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	current, err := h.DB.GetGlobalListType(channelID)
+	if err != nil {
+		return err
+	}
+
+	channelName := h.getChannelName(channelID)
+
+	var text string
+	switch strings.ToLower(fields[0]) {
+	case "add":
+		if current == listType {
+			text = fmt.Sprintf("频道「%s」已在%s中", channelName, listName)
+			break
+		}
+
+		description := strings.Join(fields[2:], " ")
+		if err := h.DB.SetGlobalListEntry(listType, channelID, message.From.ID, description); err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("添加到%s失败: %s", listName, err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+
+		text = fmt.Sprintf("已将频道「%s」添加到%s", channelName, listName)
+		if current != "" {
+			text = fmt.Sprintf("已将频道「%s」从%s移到%s", channelName, globalListNames[current], listName)
+		}
+		// 全局名单不属于任何群组，审计日志的群组ID记为 0
+		h.audit(0, message.From.ID, models.AuditActionGlobalListAdd, channelID, globalListNames[current], listName)
+	case "remove", "del":
+		if current != listType {
+			text = fmt.Sprintf("频道「%s」不在%s中", channelName, listName)
+			break
+		}
+
+		if err := h.DB.RemoveGlobalListEntry(listType, channelID); err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("从%s移除失败: %s", listName, err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+
+		h.audit(0, message.From.ID, models.AuditActionGlobalListRemove, channelID, listName, "")
+
+		text = fmt.Sprintf("已将频道「%s」从%s移除", channelName, listName)
+	default:
+		text = usage
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// sendGlobalList 发送全局名单中的频道列表
+func (h *Handler) sendGlobalList(chatID int64, listType, usage string) error {
+	entries, err := h.DB.GetGlobalListEntries(listType)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("获取%s失败: %s", globalListNames[listType], err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	var text string
+	if len(entries) == 0 {
+		text = fmt.Sprintf("%s中没有频道", globalListNames[listType])
+	} else {
+		text = fmt.Sprintf("📋 %s（共 %d 个）:\n\n", globalListNames[listType], len(entries))
+		for i, entry := range entries {
+			text += fmt.Sprintf("%d. 频道「%s」(ID: %d)\n    添加时间: %s\n",
+				i+1, h.getChannelName(entry.ChannelID), entry.ChannelID, entry.AddedAt.Format("2006-01-02 15:04:05"))
+			if entry.Description != "" {
+				text += fmt.Sprintf("    备注: %s\n", entry.Description)
+			}
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text+"\n"+usage)
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// formatUseGlobalLists 返回全局名单设置的显示文本
+func formatUseGlobalLists(useGlobalLists bool) string {
+	if useGlobalLists {
+		return "应用"
+	}
+	return "不应用"
+}
+
+// HandleGlobalLists 查看或设置本群是否应用全局白名单和黑名单
+func (h *Handler) HandleGlobalLists(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	before := settings.UseGlobalLists
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		text := fmt.Sprintf("全局白名单和黑名单: %s\n\n"+
+			"使用 /globallists on 应用全局名单\n"+
+			"使用 /globallists off 不应用全局名单", formatUseGlobalLists(settings.UseGlobalLists))
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	case "on":
+		settings.UseGlobalLists = true
+	case "off":
+		settings.UseGlobalLists = false
+	default:
+		msg := tgbotapi.NewMessage(message.Chat.ID, "格式：/globallists on 或 /globallists off")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新设置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0,
+		"全局名单: "+formatUseGlobalLists(before), "全局名单: "+formatUseGlobalLists(settings.UseGlobalLists))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("本群已设置为%s全局白名单和黑名单", formatUseGlobalLists(settings.UseGlobalLists)))
+	_, err = h.Bot.Send(msg)
+	return err
+}
@@ -11,13 +11,21 @@ import (
 // Handler 消息处理器
 type Handler struct {
 	Bot        *tgbotapi.BotAPI
-	DB         *db.DB
+	DB         db.Store
 	Config     *config.Config
 	CommandMap map[string]func(message *tgbotapi.Message, args string) error
 
 	// 消息队列和保护锁
 	messageQueue     []blockedMessageInfo
 	messageQueueLock sync.Mutex
+
+	// 匿名管理员发送的、等待管理员确认的命令
+	identityCommands     map[int64]pendingIdentityCommand
+	identityCommandSeq   int64
+	identityCommandsLock sync.Mutex
+
+	// 保证进程内同一时间只处理一张申请投票，跨进程的重复审核由申请状态的条件更新避免
+	reviewLock sync.Mutex
 }
 
 // 被阻止的消息信息
@@ -26,10 +34,13 @@ type blockedMessageInfo struct {
 	ChannelID   int64
 	MessageID   int
 	MessageText string
+	Reason      string
+	Observed    bool // 观察模式下本应删除、实际未删除的消息
 }
 
-// New 创建一个新的处理器
-func New(bot *tgbotapi.BotAPI, db *db.DB, config *config.Config) *Handler {
+// New 创建一个新的处理器，只初始化命令映射，不访问 Telegram，也不启动后台任务。
+// 正式运行时需要再调用 Start
+func New(bot *tgbotapi.BotAPI, db db.Store, config *config.Config) *Handler {
 	h := &Handler{
 		Bot:              bot,
 		DB:               db,
@@ -37,6 +48,7 @@ func New(bot *tgbotapi.BotAPI, db *db.DB, config *config.Config) *Handler {
 		CommandMap:       make(map[string]func(message *tgbotapi.Message, args string) error),
 		messageQueue:     []blockedMessageInfo{},
 		messageQueueLock: sync.Mutex{},
+		identityCommands: make(map[int64]pendingIdentityCommand),
 	}
 
 	// 初始化命令映射
@@ -51,18 +63,54 @@ func New(bot *tgbotapi.BotAPI, db *db.DB, config *config.Config) *Handler {
 	h.CommandMap["enable"] = h.HandleEnable
 	h.CommandMap["disable"] = h.HandleDisable
 	h.CommandMap["settings"] = h.HandleSettings
+	h.CommandMap["retention"] = h.HandleRetention
+	h.CommandMap["backup"] = h.HandleBackup
+	h.CommandMap["export"] = h.HandleExport
+	h.CommandMap["import"] = h.HandleImport
+	h.CommandMap["audit"] = h.HandleAudit
+	h.CommandMap["history"] = h.HandleHistory
+	h.CommandMap["globallists"] = h.HandleGlobalLists
+	h.CommandMap["gwl"] = h.HandleGlobalWhitelist
+	h.CommandMap["gbl"] = h.HandleGlobalBlacklist
+	h.CommandMap["sban"] = h.HandleSenderBan
+	h.CommandMap["sunban"] = h.HandleSenderUnban
+	h.CommandMap["sbanlist"] = h.HandleSenderBanList
+	h.CommandMap["autoban"] = h.HandleAutoBan
+	h.CommandMap["escalation"] = h.HandleEscalation
+	h.CommandMap["quota"] = h.HandleQuota
+	h.CommandMap["policy"] = h.HandlePolicy
+	h.CommandMap["linkedadmin"] = h.HandleLinkedAdmin
+	h.CommandMap["linkedchannel"] = h.HandleLinkedChannel
+	h.CommandMap["observe"] = h.HandleObserve
+	h.CommandMap["wlset"] = h.HandleWhitelistSet
+	h.CommandMap["subscribe"] = h.HandleSubscribe
+	h.CommandMap["unsubscribe"] = h.HandleUnsubscribe
 	h.CommandMap["approve"] = h.HandleApprove
 	h.CommandMap["reject"] = h.HandleReject
+	h.CommandMap["pending"] = h.HandlePending
+	h.CommandMap["quorum"] = h.HandleQuorum
+	h.CommandMap["cooldown"] = h.HandleCooldown
+	h.CommandMap["apptimeout"] = h.HandleAppTimeout
+	h.CommandMap["ownership"] = h.HandleOwnership
 	h.CommandMap["apply"] = h.HandleApply
 	h.CommandMap["claim"] = h.HandleClaim
 
+	return h
+}
+
+// Start 向 Telegram 注册命令列表，并启动消息队列、到期检查和关联频道检查等后台任务
+func (h *Handler) Start() {
 	// 设置命令映射
 	h.SetupCommands()
 
 	// 启动批量处理goroutine
 	go h.processMsgQueue()
 
-	return h
+	// 启动临时白名单、临时封禁和待处理申请的到期检查
+	go h.processExpiry()
+
+	// 启动关联频道的定期检查
+	go h.processLinkedChannels()
 }
 
 // HandleUpdate 处理消息更新
@@ -92,5 +140,10 @@ func (h *Handler) HandleUpdate(update tgbotapi.Update) error {
 		return h.HandleCallbackQuery(update.CallbackQuery)
 	}
 
+	// 处理机器人在群组中的成员状态变化
+	if update.MyChatMember != nil {
+		return h.HandleMyChatMember(update.MyChatMember)
+	}
+
 	return nil
 }
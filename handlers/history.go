@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// decisionNoteFromArgs 从 /approve、/reject 的参数中取出申请ID或频道之后的审核备注
+func decisionNoteFromArgs(args string) string {
+	fields := strings.SplitN(strings.TrimSpace(args), " ", 2)
+	if len(fields) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(fields[1])
+}
+
+// formatDecisionNote 格式化审核备注，备注为空时返回空字符串
+func formatDecisionNote(note string) string {
+	if note == "" {
+		return ""
+	}
+	return "\n审核备注: " + note
+}
+
+// formatApplicationStatus 返回申请状态的显示名称
+func formatApplicationStatus(status string) string {
+	switch status {
+	case "pending":
+		return "待审核"
+	case "approved":
+		return "已批准"
+	case "rejected":
+		return "已拒绝"
+	case "expired":
+		return "已过期"
+	default:
+		return status
+	}
+}
+
+// formatUserID 格式化用户ID，0 显示为给定的占位文本
+func formatUserID(userID int64, zero string) string {
+	if userID == 0 {
+		return zero
+	}
+	return fmt.Sprintf("%d", userID)
+}
+
+// HandleHistory 显示频道在本群的全部申请记录，支持回复频道消息或提供频道ID
+func (h *Handler) HandleHistory(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	var channelID int64
+	if message.ReplyToMessage != nil && utils.IsChannelMessage(message.ReplyToMessage) {
+		channelID = utils.GetChannelID(message.ReplyToMessage)
+	} else if args != "" {
+		var err error
+		channelID, _, err = h.resolveChannel(strings.TrimSpace(args))
+		if err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的频道: %s", err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+	} else {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "请回复一条频道消息或提供频道ID、@用户名或 t.me 链接，格式：/history 频道")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	applications, err := h.DB.GetChannelApplicationHistory(message.Chat.ID, channelID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("获取申请记录失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	channelName := h.getChannelName(channelID)
+	if len(applications) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("频道「%s」在本群没有申请记录", channelName))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	text := fmt.Sprintf("📋 频道「%s」的申请记录（共 %d 次）:\n\n", channelName, len(applications))
+	for i, app := range applications {
+		text += fmt.Sprintf("%d. %s 申请，状态: %s\n", i+1, app.AppliedAt.Format("2006-01-02 15:04"), formatApplicationStatus(app.Status))
+		if app.Reason != "" {
+			text += fmt.Sprintf("理由: %s\n", app.Reason)
+		}
+		if app.Status == "pending" {
+			text += fmt.Sprintf("认领人: %s\n", formatUserID(app.UserID, "未认领"))
+		} else {
+			text += fmt.Sprintf("认领人: %s\n", formatUserID(app.ClaimantID, "未认领"))
+			text += fmt.Sprintf("审核人: %s\n", formatUserID(app.ReviewerID, "未知"))
+			if !app.DecidedAt.IsZero() {
+				text += fmt.Sprintf("审核时间: %s\n", app.DecidedAt.Format("2006-01-02 15:04"))
+			}
+			if app.DecisionNote != "" {
+				text += fmt.Sprintf("审核备注: %s\n", app.DecisionNote)
+			}
+		}
+		text += "\n"
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, strings.TrimRight(text, "\n"))
+	_, err = h.Bot.Send(msg)
+	return err
+}
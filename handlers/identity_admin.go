@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// identityCommandTimeout 匿名管理员发送的命令等待确认的时间
+const identityCommandTimeout = 10 * time.Minute
+
+// identityReadOnlyCommands 匿名管理员可以直接执行的只读命令，其余命令需要管理员确认。
+// /export 需要私聊发送给执行人，也需要确认后以确认人的身份执行
+var identityReadOnlyCommands = map[string]bool{
+	"start":         true,
+	"help":          true,
+	"list_channels": true,
+	"stats":         true,
+	"settings":      true,
+	"audit":         true,
+	"history":       true,
+	"pending":       true,
+	"sbanlist":      true,
+	"escalation":    true,
+}
+
+// pendingIdentityCommand 匿名管理员发送的、等待管理员确认的命令
+type pendingIdentityCommand struct {
+	ChatID      int64
+	Description string                        // 显示给管理员的命令内容
+	Message     *tgbotapi.Message             // 原始消息
+	Run         func(*tgbotapi.Message) error // 确认后以确认人的身份执行
+	CreatedAt   time.Time
+}
+
+// formatLinkedChannelAdmin 格式化关联频道身份设置
+func formatLinkedChannelAdmin(linkedChannelAdmin bool) string {
+	if linkedChannelAdmin {
+		return "视为管理员"
+	}
+	return "视为普通频道"
+}
+
+// isIdentityAdmin 检查消息是否由匿名管理员发送，或在群组开启相应设置时以关联频道身份发送。
+// 关联频道自动转发到群组的消息不算
+func (h *Handler) isIdentityAdmin(message *tgbotapi.Message) bool {
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		return false
+	}
+	if utils.IsAnonymousAdminMessage(message) {
+		return true
+	}
+	if !utils.IsChannelMessage(message) || message.IsAutomaticForward {
+		return false
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil || !settings.LinkedChannelAdmin {
+		return false
+	}
+	return settings.LinkedChannelID != 0 && message.SenderChat.ID == settings.LinkedChannelID
+}
+
+// handleIdentityAdminCommand 处理匿名管理员发送的命令，只读命令直接执行，其余命令等待管理员确认
+func (h *Handler) handleIdentityAdminCommand(message *tgbotapi.Message, command, args string) error {
+	// 与普通命令一样，只处理艾特机器人的指令
+	if !utils.IsMentioningBot(message, h.Bot.Self.UserName) && command != "apply" && command != "claim" {
+		return nil
+	}
+
+	handler, exists := h.CommandMap[command]
+	if !exists {
+		return h.HandleUnknownCommand(message)
+	}
+
+	if identityReadOnlyCommands[command] {
+		return handler(message, args)
+	}
+
+	description := "/" + command
+	if args != "" {
+		description += " " + args
+	}
+	return h.requestIdentityConfirmation(message, description, func(m *tgbotapi.Message) error {
+		return handler(m, args)
+	})
+}
+
+// requestIdentityConfirmation 保存匿名管理员发送的命令，并发送确认按钮
+func (h *Handler) requestIdentityConfirmation(message *tgbotapi.Message, description string, run func(*tgbotapi.Message) error) error {
+	h.identityCommandsLock.Lock()
+	// 顺便清理已过期的命令
+	for id, pending := range h.identityCommands {
+		if time.Since(pending.CreatedAt) > identityCommandTimeout {
+			delete(h.identityCommands, id)
+		}
+	}
+	h.identityCommandSeq++
+	id := h.identityCommandSeq
+	h.identityCommands[id] = pendingIdentityCommand{
+		ChatID:      message.Chat.ID,
+		Description: description,
+		Message:     message,
+		Run:         run,
+		CreatedAt:   time.Now(),
+	}
+	h.identityCommandsLock.Unlock()
+
+	identity := "匿名管理员"
+	if !utils.IsAnonymousAdminMessage(message) {
+		identity = fmt.Sprintf("关联频道「%s」", message.SenderChat.Title)
+	}
+
+	text := fmt.Sprintf("%s发送了命令:\n%s\n\n无法确认发送者的身份，需要一位管理员在 %s内点击下方按钮确认后才会执行",
+		identity, description, utils.FormatDuration(identityCommandTimeout))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ 确认执行", fmt.Sprintf("idcmd:confirm:%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ 取消", fmt.Sprintf("idcmd:cancel:%d", id)),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyToMessageID = message.MessageID
+	msg.ReplyMarkup = keyboard
+	_, err := h.Bot.Send(msg)
+	return err
+}
+
+// handleIdentityCommandCallback 处理匿名管理员命令的确认和取消按钮
+func (h *Handler) handleIdentityCommandCallback(query *tgbotapi.CallbackQuery) error {
+	parts := strings.Split(query.Data, ":")
+	if len(parts) != 3 {
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	h.identityCommandsLock.Lock()
+	pending, ok := h.identityCommands[id]
+	h.identityCommandsLock.Unlock()
+
+	if !ok || time.Since(pending.CreatedAt) > identityCommandTimeout {
+		callback := tgbotapi.NewCallback(query.ID, "命令已过期，请重新发送")
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, "命令已过期，请重新发送")
+		_, _ = h.Bot.Send(editMsg)
+		return nil
+	}
+
+	// 只有群组管理员或全局管理员可以确认
+	if !utils.IsGlobalAdmin(h.Config.AdminUsers, query.From.ID) {
+		isAdmin, err := utils.IsAdmin(h.Bot, pending.ChatID, query.From.ID)
+		if err != nil {
+			return err
+		}
+		if !isAdmin {
+			callback := tgbotapi.NewCallback(query.ID, "只有群组管理员可以确认")
+			_, _ = h.Bot.Request(callback)
+			return nil
+		}
+	}
+
+	// 从待确认列表中取出，避免重复执行
+	h.identityCommandsLock.Lock()
+	_, ok = h.identityCommands[id]
+	delete(h.identityCommands, id)
+	h.identityCommandsLock.Unlock()
+	if !ok {
+		callback := tgbotapi.NewCallback(query.ID, "命令已被处理")
+		_, _ = h.Bot.Request(callback)
+		return nil
+	}
+
+	if parts[1] == "cancel" {
+		callback := tgbotapi.NewCallback(query.ID, "已取消")
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+			fmt.Sprintf("命令 %s 已被 %s 取消", pending.Description, formatUserID(query.From.ID, "未知")))
+		_, err := h.Bot.Send(editMsg)
+		return err
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, "已确认执行")
+	_, _ = h.Bot.Request(callback)
+
+	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+		fmt.Sprintf("命令 %s 已由 %s 确认执行", pending.Description, formatUserID(query.From.ID, "未知")))
+	_, _ = h.Bot.Send(editMsg)
+
+	// 以确认人的身份执行命令，权限检查和审计日志都记录确认人
+	confirmed := *pending.Message
+	confirmed.From = query.From
+	confirmed.SenderChat = nil
+	return pending.Run(&confirmed)
+}
+
+// HandleLinkedAdmin 查看或设置是否将以关联频道身份发送的消息视为管理员
+func (h *Handler) HandleLinkedAdmin(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	before := settings.LinkedChannelAdmin
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		text := fmt.Sprintf("关联频道身份: %s\n\n"+
+			"使用 /linkedadmin on 将以关联频道身份发送的消息视为管理员（不受白名单限制，命令与匿名管理员一样需要管理员确认）\n"+
+			"使用 /linkedadmin off 视为普通频道", formatLinkedChannelAdmin(settings.LinkedChannelAdmin))
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	case "on":
+		settings.LinkedChannelAdmin = true
+	case "off":
+		settings.LinkedChannelAdmin = false
+	default:
+		msg := tgbotapi.NewMessage(message.Chat.ID, "格式：/linkedadmin on 或 /linkedadmin off")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新设置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0,
+		"关联频道身份: "+formatLinkedChannelAdmin(before), "关联频道身份: "+formatLinkedChannelAdmin(settings.LinkedChannelAdmin))
+
+	text := fmt.Sprintf("以关联频道身份发送的消息将%s", formatLinkedChannelAdmin(settings.LinkedChannelAdmin))
+	if settings.LinkedChannelAdmin && settings.LinkedChannelID == 0 {
+		text += "\n\n⚠️ 本群目前没有关联频道"
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// linkedChannelDescription 自动添加的关联频道在白名单中的描述，用于识别关联频道变更后需要移除的旧记录
+const linkedChannelDescription = "自动添加的关联频道"
+
+// linkedChannelSyncInterval 定期检查所有群组关联频道的间隔
+const linkedChannelSyncInterval = 6 * time.Hour
+
+// linkedChannelSyncDelay 逐个群组查询关联频道之间的间隔，避免触发 Telegram 的频率限制
+const linkedChannelSyncDelay = 100 * time.Millisecond
+
+// formatLinkedChannelAuto 格式化关联频道变更时的处理方式
+func formatLinkedChannelAuto(linkedChannelAuto bool) string {
+	if linkedChannelAuto {
+		return "自动更新白名单"
+	}
+	return "需要管理员确认"
+}
+
+// getLinkedChannelID 通过 GetChat 获取群组当前的关联频道ID，没有关联频道时返回 0
+func (h *Handler) getLinkedChannelID(chatID int64) (int64, error) {
+	chat, err := h.Bot.GetChat(tgbotapi.ChatInfoConfig{
+		ChatConfig: tgbotapi.ChatConfig{
+			ChatID: chatID,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return chat.LinkedChatID, nil
+}
+
+// processLinkedChannels 启动时和之后定期检查所有群组的关联频道是否变更
+func (h *Handler) processLinkedChannels() {
+	h.syncAllLinkedChannels()
+
+	ticker := time.NewTicker(linkedChannelSyncInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.syncAllLinkedChannels()
+	}
+}
+
+// syncAllLinkedChannels 检查所有启用了机器人的群组的关联频道
+func (h *Handler) syncAllLinkedChannels() {
+	all, err := h.DB.GetAllGroupSettings()
+	if err != nil {
+		fmt.Printf("获取群组设置失败: %s\n", err.Error())
+		return
+	}
+
+	for _, settings := range all {
+		if !settings.Enabled || settings.ChatID > 0 {
+			continue
+		}
+		if err := h.syncLinkedChannel(settings); err != nil {
+			fmt.Printf("检查群组 %d 的关联频道失败: %s\n", settings.ChatID, err.Error())
+		}
+		time.Sleep(linkedChannelSyncDelay)
+	}
+}
+
+// syncLinkedChannel 检查群组的关联频道是否变更，变更时按群组设置自动更新白名单或请管理员确认
+func (h *Handler) syncLinkedChannel(settings models.GroupSettings) error {
+	linkedID, err := h.getLinkedChannelID(settings.ChatID)
+	if err != nil {
+		return err
+	}
+	if linkedID == settings.LinkedChannelID {
+		return nil
+	}
+
+	// 先记录新的关联频道，需要确认时也不会重复提示。
+	// 传入的设置可能已经过时，只更新关联频道，避免覆盖管理员在此期间修改的其他设置
+	if err := h.DB.UpdateLinkedChannelID(settings.ChatID, linkedID); err != nil {
+		return err
+	}
+
+	if !settings.LinkedChannelAuto {
+		return h.promptLinkedChannelChange(settings.ChatID, linkedID)
+	}
+
+	lines, err := h.applyLinkedChannel(settings.ChatID, linkedID, 0)
+	if err != nil || len(lines) == 0 {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(settings.ChatID, "检测到本群的关联频道发生变更:\n"+strings.Join(lines, "\n"))
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// linkedChannelChanges 计算更新白名单时需要移除的旧关联频道，以及新的关联频道是否需要添加
+func (h *Handler) linkedChannelChanges(chatID, linkedID int64) ([]models.WhitelistedChannel, bool, error) {
+	channels, err := h.DB.GetWhitelistedChannels(chatID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var stale []models.WhitelistedChannel
+	add := linkedID != 0
+	for _, channel := range channels {
+		if channel.ChannelID == linkedID {
+			add = false
+			continue
+		}
+		if channel.Description == linkedChannelDescription {
+			stale = append(stale, channel)
+		}
+	}
+	return stale, add, nil
+}
+
+// applyLinkedChannel 移除旧关联频道的白名单记录并添加新的关联频道，返回执行的变更说明
+func (h *Handler) applyLinkedChannel(chatID, linkedID, actorID int64) ([]string, error) {
+	stale, add, err := h.linkedChannelChanges(chatID, linkedID)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, channel := range stale {
+		if err := h.DB.RemoveChannelFromWhitelist(chatID, channel.ChannelID); err != nil {
+			return lines, err
+		}
+		h.audit(chatID, actorID, models.AuditActionWhitelistRemove, channel.ChannelID, linkedChannelDescription, "关联频道已变更")
+		lines = append(lines, fmt.Sprintf("已将原关联频道「%s」移出白名单", h.getChannelName(channel.ChannelID)))
+	}
+
+	if add {
+		username := ""
+		channel, err := h.Bot.GetChat(tgbotapi.ChatInfoConfig{
+			ChatConfig: tgbotapi.ChatConfig{
+				ChatID: linkedID,
+			},
+		})
+		if err == nil {
+			username = channel.UserName
+		}
+
+		if err := h.DB.AddChannelToWhitelist(chatID, linkedID, actorID, username, linkedChannelDescription, time.Time{}); err != nil {
+			return lines, err
+		}
+		h.audit(chatID, actorID, models.AuditActionWhitelistAdd, linkedID, "", linkedChannelDescription)
+		h.liftSenderBanQuietly(chatID, linkedID, actorID)
+		lines = append(lines, fmt.Sprintf("已将关联频道「%s」添加到白名单", h.getChannelName(linkedID)))
+	}
+
+	return lines, nil
+}
+
+// promptLinkedChannelChange 关联频道变更需要确认时，在群组中列出待更新的白名单并发送确认按钮
+func (h *Handler) promptLinkedChannelChange(chatID, linkedID int64) error {
+	stale, add, err := h.linkedChannelChanges(chatID, linkedID)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 && !add {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("检测到本群的关联频道发生变更:\n")
+	if add {
+		b.WriteString(fmt.Sprintf("新的关联频道「%s」尚未加入白名单\n", h.getChannelName(linkedID)))
+	} else if linkedID == 0 {
+		b.WriteString("本群已不再有关联频道\n")
+	}
+	for _, channel := range stale {
+		b.WriteString(fmt.Sprintf("原关联频道「%s」仍在白名单中\n", h.getChannelName(channel.ChannelID)))
+	}
+	b.WriteString("\n管理员可以选择是否更新白名单")
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ 更新白名单", fmt.Sprintf("linked:apply:%d", linkedID)),
+			tgbotapi.NewInlineKeyboardButtonData("忽略", fmt.Sprintf("linked:ignore:%d", linkedID)),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	msg.ReplyMarkup = keyboard
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// handleLinkedChannelCallback 处理关联频道变更的确认和忽略按钮
+func (h *Handler) handleLinkedChannelCallback(query *tgbotapi.CallbackQuery) error {
+	parts := strings.Split(query.Data, ":")
+	if len(parts) != 3 {
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
+	}
+
+	linkedID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	chatID := query.Message.Chat.ID
+
+	// 只有群组管理员或全局管理员可以操作
+	if !utils.IsGlobalAdmin(h.Config.AdminUsers, query.From.ID) {
+		isAdmin, err := utils.IsAdmin(h.Bot, chatID, query.From.ID)
+		if err != nil {
+			return err
+		}
+		if !isAdmin {
+			callback := tgbotapi.NewCallback(query.ID, "只有群组管理员可以操作")
+			_, _ = h.Bot.Request(callback)
+			return nil
+		}
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(chatID)
+	if err != nil {
+		return err
+	}
+
+	// 关联频道在提示之后再次变更时，按钮失效
+	if settings.LinkedChannelID != linkedID {
+		callback := tgbotapi.NewCallback(query.ID, "关联频道已再次变更")
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageText(chatID, query.Message.MessageID, "关联频道已再次变更，此提示已失效")
+		_, err := h.Bot.Send(editMsg)
+		return err
+	}
+
+	if parts[1] == "ignore" {
+		callback := tgbotapi.NewCallback(query.ID, "已忽略")
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageText(chatID, query.Message.MessageID,
+			fmt.Sprintf("%s\n\n已由 %s 忽略，白名单保持不变", query.Message.Text, formatUserID(query.From.ID, "未知")))
+		_, err := h.Bot.Send(editMsg)
+		return err
+	}
+
+	lines, err := h.applyLinkedChannel(chatID, linkedID, query.From.ID)
+	if err != nil {
+		return err
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, "白名单已更新")
+	_, _ = h.Bot.Request(callback)
+
+	text := "白名单已是最新，无需更新"
+	if len(lines) > 0 {
+		text = strings.Join(lines, "\n")
+	}
+	editMsg := tgbotapi.NewEditMessageText(chatID, query.Message.MessageID,
+		fmt.Sprintf("%s\n\n由 %s 确认", text, formatUserID(query.From.ID, "未知")))
+	_, err = h.Bot.Send(editMsg)
+	return err
+}
+
+// HandleMyChatMember 机器人被加入群组时立即检查群组的关联频道，被加入频道时完成认领申请的所有权验证
+func (h *Handler) HandleMyChatMember(update *tgbotapi.ChatMemberUpdated) error {
+	wasMember := !update.OldChatMember.HasLeft() && !update.OldChatMember.WasKicked()
+	isMember := !update.NewChatMember.HasLeft() && !update.NewChatMember.WasKicked()
+	if wasMember || !isMember {
+		return nil
+	}
+
+	if update.Chat.Type == "channel" {
+		return h.handleBotAddedToChannel(update)
+	}
+	if update.Chat.Type != "group" && update.Chat.Type != "supergroup" {
+		return nil
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(update.Chat.ID)
+	if err != nil {
+		return err
+	}
+	return h.syncLinkedChannel(settings)
+}
+
+// HandleLinkedChannel 查看关联频道，设置关联频道变更时的处理方式，或立即检查关联频道
+func (h *Handler) HandleLinkedChannel(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	before := settings.LinkedChannelAuto
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		linked := "无"
+		if settings.LinkedChannelID != 0 {
+			linked = fmt.Sprintf("「%s」(%d)", h.getChannelName(settings.LinkedChannelID), settings.LinkedChannelID)
+		}
+		text := fmt.Sprintf("关联频道: %s\n变更时: %s\n\n"+
+			"使用 /linkedchannel auto 在关联频道变更时自动更新白名单\n"+
+			"使用 /linkedchannel confirm 在关联频道变更时由管理员确认\n"+
+			"使用 /linkedchannel check 立即检查关联频道", linked, formatLinkedChannelAuto(settings.LinkedChannelAuto))
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	case "check":
+		previous := settings.LinkedChannelID
+		if err := h.syncLinkedChannel(settings); err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("检查关联频道失败: %s", err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+
+		settings, err = h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+		if err != nil {
+			return err
+		}
+		text := "本群没有关联频道"
+		if settings.LinkedChannelID != 0 {
+			text = fmt.Sprintf("当前关联频道: 「%s」(%d)", h.getChannelName(settings.LinkedChannelID), settings.LinkedChannelID)
+		}
+		if settings.LinkedChannelID == previous {
+			text += "，没有变化"
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	case "auto":
+		settings.LinkedChannelAuto = true
+	case "confirm":
+		settings.LinkedChannelAuto = false
+	default:
+		msg := tgbotapi.NewMessage(message.Chat.ID, "格式：/linkedchannel auto、/linkedchannel confirm 或 /linkedchannel check")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新设置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0,
+		"关联频道变更: "+formatLinkedChannelAuto(before), "关联频道变更: "+formatLinkedChannelAuto(settings.LinkedChannelAuto))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("关联频道变更时将%s", formatLinkedChannelAuto(settings.LinkedChannelAuto)))
+	_, err = h.Bot.Send(msg)
+	return err
+}
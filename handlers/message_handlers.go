@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/anhe/tg-whitelist-bot/db/models"
 	"github.com/anhe/tg-whitelist-bot/utils"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -22,6 +23,17 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 		return nil
 	}
 
+	// 处理附带 /import 说明的导出文件
+	if h.isImportCaption(message) {
+		// 匿名管理员导入配置需要管理员确认
+		if h.isIdentityAdmin(message) {
+			return h.requestIdentityConfirmation(message, "/import", func(m *tgbotapi.Message) error {
+				return h.handleImportDocument(m, m.Document)
+			})
+		}
+		return h.handleImportDocument(message, message.Document)
+	}
+
 	// 检查群组设置
 	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
 	if err != nil {
@@ -33,30 +45,15 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 		return nil
 	}
 
-	// 检查是否是自动转发的频道消息，且是当前群组的关联频道
-	if message.IsAutomaticForward && utils.IsChannelMessage(message) {
-		channelID := utils.GetChannelID(message)
-
-		isWhitelisted, err := h.DB.IsChannelWhitelisted(message.Chat.ID, channelID)
-		if err != nil {
-			return err
-		}
-
-		// 如果不在白名单中，自动添加
-		if !isWhitelisted {
-			err = h.DB.AddChannelToWhitelist(message.Chat.ID, channelID, 0, "自动添加的关联频道")
-			if err != nil {
-				return err
-			}
-
-			// 获取频道名称
-			channelName := h.getChannelName(channelID)
-
-			notifyText := fmt.Sprintf("已自动将关联频道「%s」添加到白名单", channelName)
-			notifyMsg := tgbotapi.NewMessage(message.Chat.ID, notifyText)
-			_, _ = h.Bot.Send(notifyMsg)
+	// 匿名管理员和被视为管理员的关联频道不受白名单限制
+	if h.isIdentityAdmin(message) {
+		return nil
+	}
 
-			return nil
+	// 关联频道自动转发的消息来自尚未记录的频道时，说明关联频道可能已变更，立即检查
+	if message.IsAutomaticForward && utils.IsChannelMessage(message) && utils.GetChannelID(message) != settings.LinkedChannelID {
+		if err := h.syncLinkedChannel(settings); err != nil {
+			fmt.Printf("检查群组 %d 的关联频道失败: %s\n", message.Chat.ID, err.Error())
 		}
 	}
 
@@ -64,16 +61,30 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 	if utils.IsChannelMessage(message) {
 		channelID := utils.GetChannelID(message)
 
-		// 检查频道是否在白名单中
-		isWhitelisted, err := h.DB.IsChannelWhitelisted(message.Chat.ID, channelID)
+		// 检查频道是否允许发言
+		verdict, err := h.checkChannel(message.Chat.ID, channelID, settings)
 		if err != nil {
 			return err
 		}
 
-		// 如果不在白名单中
-		if !isWhitelisted {
-			// 删除消息
+		// 认领验证码和观察模式下的消息不删除也不提示
+		if h.screenChannelMessage(message, channelID, verdict, settings) {
+			return nil
+		}
+
+		// 全局黑名单中的频道直接删除，不提示申请
+		if verdict == verdictBlacklisted {
 			go h.deleteMessageWithTimeout(message.Chat.ID, message.MessageID)
+			go h.addToMessageQueueWithReason(message.Chat.ID, channelID, message.MessageID, message.Text, verdict.blockReason())
+			return nil
+		}
+
+		// 如果不在白名单中
+		if verdict != verdictAllowed {
+			// 删除消息，观察模式下 /apply 仍会走到这里，不删除
+			if !settings.ObserveMode {
+				go h.deleteMessageWithTimeout(message.Chat.ID, message.MessageID)
+			}
 
 			// 如果是 /apply 命令，处理申请逻辑
 			if message.Command() == "apply" {
@@ -91,9 +102,8 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 						return err
 					}
 
-					// 如果今天已经通知过一次"待审核"
-					if hasNoticed {
-						// 直接删除，不再提示
+					// 如果今天已经通知过一次"待审核"，或处于观察模式，不再提示
+					if hasNoticed || settings.ObserveMode {
 						return nil
 					}
 
@@ -117,29 +127,15 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 				return nil
 			}
 
-			// 对于非命令消息，检查今天是否已经提示过"需要申请"
-			hasPrompted, _ := h.DB.HasPromptedToday(message.Chat.ID, channelID)
-
-			// 如果今天已经提示过"需要申请"，直接删除不再提示
-			if hasPrompted {
-				// 记录被阻止的消息
-				go h.addToMessageQueue(message.Chat.ID, channelID, message.MessageID, message.Text)
-				return nil
-			}
+			// 对于非命令消息，按群组的处罚升级规则处理
+			return h.handleViolation(message, channelID, settings)
+		}
 
-			// 第一次提示"需要申请"
-			channelName := h.getChannelName(channelID)
-			promptText := fmt.Sprintf("频道「%s」未在白名单中，已删除消息。\n\n"+
-				"频道可以直接发送 /apply + 申请理由 命令申请允许发言。", channelName)
-			promptMsg := tgbotapi.NewMessage(message.Chat.ID, promptText)
-			if _, err := h.Bot.Send(promptMsg); err == nil {
-				// 记录已提示过"需要申请"
-				h.DB.RecordPrompt(message.Chat.ID, channelID)
+		// 白名单频道违反内容限制或超出发言配额时删除消息
+		if !message.IsCommand() {
+			if blocked, err := h.enforceWhitelistEntry(message, channelID, settings); err != nil || blocked {
+				return err
 			}
-
-			// 记录被阻止的消息
-			go h.addToMessageQueue(message.Chat.ID, channelID, message.MessageID, message.Text)
-			return nil
 		}
 	}
 
@@ -167,7 +163,9 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 
 		// 如果已经有待处理的申请且今天已经提示过，直接删除消息
 		if hasApp && noticed {
-			go h.deleteMessageWithTimeout(message.Chat.ID, message.MessageID)
+			if !settings.ObserveMode {
+				go h.deleteMessageWithTimeout(message.Chat.ID, message.MessageID)
+			}
 			return nil
 		}
 
@@ -199,16 +197,30 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 		// 获取频道ID
 		channelID := message.SenderChat.ID
 
-		// 检查是否在白名单中
-		isWhitelisted, err := h.DB.IsChannelWhitelisted(message.Chat.ID, channelID)
+		// 检查频道是否允许发言
+		verdict, err := h.checkChannel(message.Chat.ID, channelID, settings)
 		if err != nil {
 			return err
 		}
 
-		// 如果不在白名单中
-		if !isWhitelisted {
-			// 删除消息
+		// 认领验证码和观察模式下的消息不删除也不提示
+		if h.screenChannelMessage(message, channelID, verdict, settings) {
+			return nil
+		}
+
+		// 全局黑名单中的频道直接删除，不提示申请
+		if verdict == verdictBlacklisted {
 			go h.deleteMessageWithTimeout(message.Chat.ID, message.MessageID)
+			go h.addToMessageQueueWithReason(message.Chat.ID, channelID, message.MessageID, message.Text, verdict.blockReason())
+			return nil
+		}
+
+		// 如果不在白名单中
+		if verdict != verdictAllowed {
+			// 删除消息，观察模式下 /apply 仍会走到这里，不删除
+			if !settings.ObserveMode {
+				go h.deleteMessageWithTimeout(message.Chat.ID, message.MessageID)
+			}
 
 			// 如果是 /apply 命令
 			if message.Command() == "apply" {
@@ -219,8 +231,8 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 					// 检查今天是否已经提示过"待审核"状态
 					hasNoticed, _ := h.DB.HasPendingNoticeToday(message.Chat.ID, channelID)
 
-					// 如果今天已经提示过一次"待审核"，直接删除不再提示
-					if hasNoticed {
+					// 如果今天已经提示过一次"待审核"，或处于观察模式，不再提示
+					if hasNoticed || settings.ObserveMode {
 						return nil
 					}
 
@@ -245,35 +257,45 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 				return nil
 			}
 
-			// 检查今天是否已经提示过"需要申请"
-			hasPrompted, _ := h.DB.HasPromptedToday(message.Chat.ID, channelID)
+			// 对于非命令消息，按群组的处罚升级规则处理
+			return h.handleViolation(message, channelID, settings)
+		}
+	}
 
-			// 如果今天已经提示过，直接返回
-			if hasPrompted {
-				// 记录被阻止的消息
-				go h.addToMessageQueue(message.Chat.ID, channelID, message.MessageID, message.Text)
-				return nil
-			}
+	return nil
+}
 
-			// 获取频道名称
-			channelName := h.getChannelName(channelID)
+// screenChannelMessage 处理未获准发言的频道消息中不按违规处理的情况，返回 true 表示消息已处理，调用方不再继续：
+// 频道发送认领验证码时完成所有权验证，验证码消息不计入违规；观察模式下只记录本应删除的消息，不删除也不提示，/apply 仍正常处理申请
+func (h *Handler) screenChannelMessage(message *tgbotapi.Message, channelID int64, verdict channelVerdict, settings models.GroupSettings) bool {
+	if verdict == verdictAllowed {
+		return false
+	}
 
-			// 对于其他消息，发送未在白名单的提示
-			promptText := fmt.Sprintf("频道「%s」未在白名单中，已删除消息。\n\n"+
-				"频道可以直接发送 /apply + 申请理由 命令申请允许发言。", channelName)
-			promptMsg := tgbotapi.NewMessage(message.Chat.ID, promptText)
-			if _, err := h.Bot.Send(promptMsg); err == nil {
-				// 记录已提示过"需要申请"
-				h.DB.RecordPrompt(message.Chat.ID, channelID)
+	if verdict != verdictBlacklisted {
+		matched, err := h.handleOwnershipCode(message, channelID)
+		if err != nil {
+			fmt.Printf("检查频道 %d 的认领验证码失败: %s\n", channelID, err.Error())
+		}
+		if matched {
+			if !settings.ObserveMode {
+				go h.deleteMessageWithTimeout(message.Chat.ID, message.MessageID)
 			}
-
-			// 记录被阻止的消息
-			go h.addToMessageQueue(message.Chat.ID, channelID, message.MessageID, message.Text)
-			return nil
+			return true
 		}
 	}
 
-	return nil
+	if settings.ObserveMode && (verdict == verdictBlacklisted || message.Command() != "apply") {
+		// 不在白名单中的频道发送的普通消息，记录按处罚升级规则本应执行的处罚
+		if verdict == verdictNotWhitelisted && !message.IsCommand() {
+			if err := h.observeViolation(message.Chat.ID, channelID, settings); err != nil {
+				fmt.Printf("检查频道 %d 在观察模式下本应执行的处罚失败: %s\n", channelID, err.Error())
+			}
+		}
+		go h.addObservedToMessageQueue(message.Chat.ID, channelID, message.MessageID, message.Text, verdict.blockReason())
+		return true
+	}
+	return false
 }
 
 // handlePrivateMessage 处理私聊消息
@@ -289,6 +311,11 @@ func (h *Handler) handlePrivateMessage(message *tgbotapi.Message) error {
 		return nil
 	}
 
+	// 处理管理员发送的自定义拒绝理由
+	if strings.HasPrefix(state, rejectReasonState) {
+		return h.handleRejectReasonInput(message, state)
+	}
+
 	// 处理等待理由的状态
 	if strings.HasPrefix(state, "waiting_reason:") {
 		// 解析状态中的群组ID和频道ID
@@ -307,29 +334,32 @@ func (h *Handler) handlePrivateMessage(message *tgbotapi.Message) error {
 			return err
 		}
 
-		// 更新申请理由
-		err = h.DB.UpdateChannelApplicationReason(chatID, channelID, message.Text)
+		// 先认领申请，认领失败时不修改申请理由
+		err = h.claimApplication(chatID, channelID, message.From.ID)
 		if err != nil {
-			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新申请理由失败: %s", err.Error()))
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新申请用户失败: %s", err.Error()))
 			_, _ = h.Bot.Send(msg)
 			return err
 		}
 
-		// 更新申请用户ID
-		err = h.DB.UpdateChannelApplicationUser(chatID, channelID, message.From.ID)
+		// 更新申请理由
+		err = h.DB.UpdateChannelApplicationReason(chatID, channelID, message.Text)
 		if err != nil {
-			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新申请用户失败: %s", err.Error()))
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新申请理由失败: %s", err.Error()))
 			_, _ = h.Bot.Send(msg)
 			return err
 		}
 
-		// 验证频道所有权
-		err = h.DB.VerifyChannelOwnership(chatID, channelID, message.From.ID)
+		// 按群组设置验证频道所有权，未全部通过时已发送验证说明，通过后才通知管理员
+		verified, err := h.beginOwnershipVerification(chatID, channelID, message.From.ID, message.Chat.ID)
 		if err != nil {
 			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("验证频道所有权失败: %s", err.Error()))
 			_, _ = h.Bot.Send(msg)
 			return err
 		}
+		if !verified {
+			return h.DB.ClearUserState(message.From.ID)
+		}
 
 		// 获取频道和群组名称
 		channelName := h.getChannelName(channelID)
@@ -354,7 +384,7 @@ func (h *Handler) handlePrivateMessage(message *tgbotapi.Message) error {
 		}
 
 		// 通知管理员
-		err = h.notifyAdminsAboutApplication(chatID, channelID, message.From.ID, channelName, message.Text)
+		err = h.notifyAdminsAboutApplication(chatID, channelID, channelName)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlockedMessageRecorded(t *testing.T) {
+	h, fake := newTestHandler(t)
+	since := time.Now().Add(-time.Minute)
+
+	if err := h.HandleMessage(channelMessage(10, testChannelID, "广告")); err != nil {
+		t.Fatalf("处理频道消息失败: %v", err)
+	}
+	if !fake.deleted(testChatID, 10) {
+		t.Fatal("不在白名单中的频道消息没有被删除")
+	}
+	expectSent(t, fake, testChatID, "/apply")
+
+	h.flushMessageQueue()
+	count, err := h.DB.CountBlockedMessagesSince(testChatID, testChannelID, since)
+	if err != nil || count != 1 {
+		t.Fatalf("记录了 %d 条被阻止的消息（%v），期望 1 条", count, err)
+	}
+
+	// 白名单中的频道可以正常发言
+	if err := h.DB.AddChannelToWhitelist(testChatID, testChannelID, testGroupAdminID, "", "", time.Time{}); err != nil {
+		t.Fatalf("添加白名单失败: %v", err)
+	}
+	if err := h.HandleMessage(channelMessage(11, testChannelID, "正常消息")); err != nil {
+		t.Fatalf("处理频道消息失败: %v", err)
+	}
+	if fake.deleted(testChatID, 11) {
+		t.Fatal("白名单频道的消息被删除")
+	}
+}
+
+func TestObserveModeKeepsMessages(t *testing.T) {
+	h, fake := newTestHandler(t)
+	since := time.Now().Add(-time.Minute)
+
+	settings, err := h.DB.GetOrCreateGroupSettings(testChatID)
+	if err != nil {
+		t.Fatalf("获取群组设置失败: %v", err)
+	}
+	settings.ObserveMode = true
+	if err := h.DB.UpdateGroupSettings(settings); err != nil {
+		t.Fatalf("更新群组设置失败: %v", err)
+	}
+
+	if err := h.HandleMessage(channelMessage(10, testChannelID, "广告")); err != nil {
+		t.Fatalf("处理频道消息失败: %v", err)
+	}
+	if fake.deleted(testChatID, 10) {
+		t.Fatal("观察模式下删除了消息")
+	}
+
+	// 观察记录在后台写入队列
+	deadline := time.Now().Add(time.Second)
+	for h.queuedMessageCount(testChatID, testChannelID, true) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.flushMessageQueue()
+	observed, err := h.DB.CountObservedMessagesSince(testChatID, testChannelID, since)
+	if err != nil || observed != 1 {
+		t.Fatalf("记录了 %d 条观察记录（%v），期望 1 条", observed, err)
+	}
+}
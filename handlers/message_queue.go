@@ -39,36 +39,29 @@ func (h *Handler) flushMessageQueue() {
 			ChannelID:   msg.ChannelID,
 			MessageID:   msg.MessageID,
 			MessageText: msg.MessageText,
+			Reason:      msg.Reason,
+			Observed:    msg.Observed,
 		}
 	}
 
-	// 开始事务处理
-	tx, err := h.DB.BeginTx()
-	if err != nil {
-		// 如果开启事务失败，逐个处理消息
+	// 批量插入，失败时逐个处理
+	if err := h.DB.LogBlockedMessagesBatch(dbMessages); err != nil {
 		for _, msg := range messages {
-			_ = h.DB.LogBlockedMessage(msg.ChatID, msg.ChannelID, msg.MessageID, msg.MessageText)
+			_ = h.DB.LogBlockedMessage(msg.ChatID, msg.ChannelID, msg.MessageID, msg.MessageText, msg.Reason, msg.Observed)
 		}
-		return
 	}
 
-	// 批量插入
-	success := h.DB.LogBlockedMessagesBatch(tx, dbMessages)
-
-	// 如果批量处理成功，提交事务
-	if success {
-		_ = tx.Commit()
-	} else {
-		// 否则回滚并逐个处理
-		_ = tx.Rollback()
-		for _, msg := range messages {
-			_ = h.DB.LogBlockedMessage(msg.ChatID, msg.ChannelID, msg.MessageID, msg.MessageText)
-		}
-	}
+	// 检查是否有频道达到自动封禁的数量
+	h.enforceBanThreshold(messages)
 }
 
-// addToMessageQueue 添加到消息队列
+// addToMessageQueue 将不在白名单中的频道被阻止的消息添加到消息队列
 func (h *Handler) addToMessageQueue(chatID, channelID int64, messageID int, messageText string) {
+	h.addToMessageQueueWithReason(chatID, channelID, messageID, messageText, models.BlockReasonNotWhitelisted)
+}
+
+// addToMessageQueueWithReason 将被阻止的消息连同阻止原因添加到消息队列
+func (h *Handler) addToMessageQueueWithReason(chatID, channelID int64, messageID int, messageText, reason string) {
 	h.messageQueueLock.Lock()
 	defer h.messageQueueLock.Unlock()
 
@@ -77,5 +70,41 @@ func (h *Handler) addToMessageQueue(chatID, channelID int64, messageID int, mess
 		ChannelID:   channelID,
 		MessageID:   messageID,
 		MessageText: messageText,
+		Reason:      reason,
 	})
 }
+
+// addObservedToMessageQueue 将观察模式下本应被删除的消息添加到消息队列
+func (h *Handler) addObservedToMessageQueue(chatID, channelID int64, messageID int, messageText, reason string) {
+	h.messageQueueLock.Lock()
+	defer h.messageQueueLock.Unlock()
+
+	h.messageQueue = append(h.messageQueue, blockedMessageInfo{
+		ChatID:      chatID,
+		ChannelID:   channelID,
+		MessageID:   messageID,
+		MessageText: messageText,
+		Reason:      reason,
+		Observed:    true,
+	})
+}
+
+// queuedMessageCount 统计队列中尚未写入数据库的频道因不在白名单（或在全局黑名单中）而被阻止的消息数量，
+// observed 为 true 时统计观察模式下的记录，否则统计实际删除的记录
+func (h *Handler) queuedMessageCount(chatID, channelID int64, observed bool) int {
+	h.messageQueueLock.Lock()
+	defer h.messageQueueLock.Unlock()
+
+	count := 0
+	for _, msg := range h.messageQueue {
+		if msg.ChatID == chatID && msg.ChannelID == channelID && countsAsViolation(msg.Reason) && msg.Observed == observed {
+			count++
+		}
+	}
+	return count
+}
+
+// countsAsViolation 判断被阻止的消息是否计入自动封禁和处罚升级的次数，超出配额和违反内容限制的白名单频道不计入
+func countsAsViolation(reason string) bool {
+	return reason == models.BlockReasonNotWhitelisted || reason == models.BlockReasonBlacklisted
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxObservedChannels 观察模式统计中最多列出的频道数量
+const maxObservedChannels = 10
+
+// blockReasonNames 被阻止消息原因的显示名称
+var blockReasonNames = map[string]string{
+	models.BlockReasonNotWhitelisted: "不在白名单",
+	models.BlockReasonQuota:          "超出配额",
+	models.BlockReasonContentPolicy:  "违反内容限制",
+	models.BlockReasonBlacklisted:    "全局黑名单",
+}
+
+// formatObserveMode 格式化观察模式设置
+func formatObserveMode(observeMode bool) string {
+	if observeMode {
+		return "开启（只记录，不删除）"
+	}
+	return "关闭"
+}
+
+// observeSummary 汇总群组在本次观察期间本应删除的消息
+func (h *Handler) observeSummary(settings models.GroupSettings) (string, error) {
+	// 先写入队列中的记录，确保统计完整
+	h.flushMessageQueue()
+
+	stats, err := h.DB.GetObservedMessageStats(settings.ChatID, settings.ObserveSince)
+	if err != nil {
+		return "", err
+	}
+
+	total := 0
+	for _, stat := range stats {
+		total += stat.Count
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("观察开始于: %s（已观察 %s）\n",
+		settings.ObserveSince.Format("2006-01-02 15:04:05"), utils.FormatDuration(time.Since(settings.ObserveSince))))
+	sb.WriteString(fmt.Sprintf("本应删除的消息: %d 条\n", total))
+
+	for i, stat := range stats {
+		if i >= maxObservedChannels {
+			sb.WriteString(fmt.Sprintf("... 另有 %d 项未列出\n", len(stats)-maxObservedChannels))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s（%s）: %d 条\n", i+1, h.getChannelName(stat.ChannelID), blockReasonNames[stat.Reason], stat.Count))
+	}
+
+	// 本次观察期间按处罚升级规则和自动封禁设置本应执行的处罚
+	var actions []string
+	for _, action := range []string{models.AuditActionObserveBan, models.AuditActionObserveWarn} {
+		entries, _, err := h.DB.GetAuditLogs(settings.ChatID, models.AuditFilter{Action: action}, maxObservedChannels, 0)
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range entries {
+			if entry.CreatedAt.Before(settings.ObserveSince) {
+				break
+			}
+			actions = append(actions, fmt.Sprintf("%s %s: %s（%s）",
+				entry.CreatedAt.Format("01-02 15:04"), auditActionNames[action], h.getChannelName(entry.TargetID), entry.AfterValue))
+		}
+	}
+	if len(actions) > 0 {
+		sb.WriteString(fmt.Sprintf("\n本应执行的处罚（每类最多列出最近 %d 条，完整记录见 /audit observe_warn 和 /audit observe_ban）:\n", maxObservedChannels))
+		sb.WriteString(strings.Join(actions, "\n") + "\n")
+	}
+	return sb.String(), nil
+}
+
+// observeViolation 观察模式下按处罚升级规则判断不在白名单中的频道发送普通消息时本应执行的处罚，
+// 警告和封禁记录到审计日志，删除和提示申请已体现在观察记录中，不再单独记录
+func (h *Handler) observeViolation(chatID, channelID int64, settings models.GroupSettings) error {
+	steps, err := h.DB.GetEscalationSteps(chatID)
+	if err != nil || len(steps) == 0 {
+		return err
+	}
+
+	// 只统计本次观察期间的记录，当前这条消息尚未加入队列
+	window := escalationWindow(settings)
+	since := time.Now().Add(-window)
+	if since.Before(settings.ObserveSince) {
+		since = settings.ObserveSince
+	}
+	count, err := h.DB.CountObservedMessagesSince(chatID, channelID, since)
+	if err != nil {
+		return err
+	}
+	count += h.queuedMessageCount(chatID, channelID, true) + 1
+
+	step, ok := models.MatchEscalationStep(steps, count)
+	if !ok {
+		return nil
+	}
+
+	detail := fmt.Sprintf("%s内第 %d 次", utils.FormatDuration(window), count)
+	switch step.Action {
+	case models.EscalationWarn:
+		h.audit(chatID, 0, models.AuditActionObserveWarn, channelID, "", detail)
+	case models.EscalationBan:
+		unbannedAt, err := h.DB.GetSenderChatUnbanTime(chatID, channelID)
+		if err != nil {
+			return err
+		}
+		if unbannedAt.After(since) {
+			since = unbannedAt
+		}
+		h.recordObservedBan(chatID, channelID, since, formatEscalationAction(step.Action, step.Duration)+"："+detail)
+	}
+	return nil
+}
+
+// recordObservedBan 记录观察模式下本应执行的封禁。频道被封禁后无法再发言，since 之后已记录过时不再重复记录
+func (h *Handler) recordObservedBan(chatID, channelID int64, since time.Time, detail string) {
+	filter := models.AuditFilter{Action: models.AuditActionObserveBan, TargetID: channelID}
+	entries, _, err := h.DB.GetAuditLogs(chatID, filter, 1, 0)
+	if err != nil {
+		fmt.Printf("获取频道 %d 本应执行的封禁记录失败: %s\n", channelID, err.Error())
+		return
+	}
+	if len(entries) > 0 && !entries[0].CreatedAt.Before(since) {
+		return
+	}
+	h.audit(chatID, 0, models.AuditActionObserveBan, channelID, "", detail)
+}
+
+// HandleObserve 查看或设置群组的观察模式。观察模式下机器人照常判断，但只记录本应删除的消息，不删除也不提示
+func (h *Handler) HandleObserve(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	before := settings.ObserveMode
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		text := fmt.Sprintf("观察模式: %s\n", formatObserveMode(settings.ObserveMode))
+		if settings.ObserveMode {
+			summary, err := h.observeSummary(settings)
+			if err != nil {
+				return err
+			}
+			text += summary
+		}
+		text += "\n使用 /observe on 开启观察模式，机器人只记录本应删除的消息，不删除也不提示\n" +
+			"使用 /observe off 关闭观察模式，恢复正常处理"
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	case "on":
+		if settings.ObserveMode {
+			msg := tgbotapi.NewMessage(message.Chat.ID, "观察模式已经开启，使用 /observe 查看统计")
+			_, err := h.Bot.Send(msg)
+			return err
+		}
+		settings.ObserveMode = true
+		settings.ObserveSince = time.Now()
+	case "off":
+		if !settings.ObserveMode {
+			msg := tgbotapi.NewMessage(message.Chat.ID, "观察模式未开启")
+			_, err := h.Bot.Send(msg)
+			return err
+		}
+		settings.ObserveMode = false
+	default:
+		msg := tgbotapi.NewMessage(message.Chat.ID, "格式：/observe on 或 /observe off")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 关闭前先汇总本次观察的结果
+	var summary string
+	if before {
+		summary, err = h.observeSummary(settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新设置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0,
+		"观察模式: "+formatObserveMode(before), "观察模式: "+formatObserveMode(settings.ObserveMode))
+
+	var text string
+	if settings.ObserveMode {
+		text = "已开启观察模式，机器人只记录本应删除的消息，不删除也不提示。使用 /stats 或 /observe 查看统计"
+	} else {
+		text = "已关闭观察模式，恢复正常处理。本次观察结果:\n\n" + summary
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
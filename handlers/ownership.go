@@ -0,0 +1,489 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ownershipCodeAlphabet 生成验证码使用的字符，去掉了容易混淆的 0、O、1、I
+const ownershipCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// ownershipCodeLength 验证码的长度
+const ownershipCodeLength = 8
+
+// ownershipVerifier 一种频道所有权验证方式
+type ownershipVerifier interface {
+	// title 返回验证方式的显示名称
+	title() string
+	// newCheck 认领时为申请创建验证记录
+	newCheck(app models.ChannelApplication) (models.OwnershipCheck, error)
+	// instructions 返回告诉认领人如何完成验证的说明
+	instructions(h *Handler, app models.ChannelApplication, check models.OwnershipCheck) string
+	// verify 主动检查认领人是否已通过验证，只能由群组消息或成员变化完成的验证返回 false
+	verify(h *Handler, app models.ChannelApplication) (bool, error)
+}
+
+// ownershipVerifiers 各验证方式的实现
+var ownershipVerifiers = map[string]ownershipVerifier{
+	models.OwnershipAdmin: adminOwnershipVerifier{},
+	models.OwnershipCode:  codeOwnershipVerifier{},
+	models.OwnershipBot:   botOwnershipVerifier{},
+}
+
+// adminOwnershipVerifier 检查认领人是否是频道的管理员
+type adminOwnershipVerifier struct{}
+
+func (adminOwnershipVerifier) title() string {
+	return "频道管理员"
+}
+
+func (adminOwnershipVerifier) newCheck(app models.ChannelApplication) (models.OwnershipCheck, error) {
+	return models.OwnershipCheck{ApplicationID: app.ID, Method: models.OwnershipAdmin}, nil
+}
+
+func (adminOwnershipVerifier) instructions(h *Handler, _ models.ChannelApplication, _ models.OwnershipCheck) string {
+	return fmt.Sprintf("您需要是频道的管理员。机器人需要能查看频道的管理员列表，如果检查失败，请先将 @%s 添加到频道", h.Bot.Self.UserName)
+}
+
+func (adminOwnershipVerifier) verify(h *Handler, app models.ChannelApplication) (bool, error) {
+	admins, err := h.Bot.GetChatAdministrators(tgbotapi.ChatAdministratorsConfig{
+		ChatConfig: tgbotapi.ChatConfig{
+			ChatID: app.ChannelID,
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("无法获取频道的管理员列表: %s", err.Error())
+	}
+
+	for _, admin := range admins {
+		if admin.User != nil && admin.User.ID == app.UserID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// codeOwnershipVerifier 要求频道在群组中发送一次性验证码
+type codeOwnershipVerifier struct{}
+
+func (codeOwnershipVerifier) title() string {
+	return "频道验证码"
+}
+
+func (codeOwnershipVerifier) newCheck(app models.ChannelApplication) (models.OwnershipCheck, error) {
+	code, err := newOwnershipCode()
+	if err != nil {
+		return models.OwnershipCheck{}, err
+	}
+	return models.OwnershipCheck{ApplicationID: app.ID, Method: models.OwnershipCode, Code: code}, nil
+}
+
+func (codeOwnershipVerifier) instructions(h *Handler, app models.ChannelApplication, check models.OwnershipCheck) string {
+	return fmt.Sprintf("请以频道「%s」的身份在群组中发送验证码 %s，机器人收到后会自动删除该消息",
+		h.getChannelName(app.ChannelID), check.Code)
+}
+
+func (codeOwnershipVerifier) verify(*Handler, models.ChannelApplication) (bool, error) {
+	return false, nil
+}
+
+// botOwnershipVerifier 要求认领人将机器人添加到频道，只有频道管理员才能添加机器人
+type botOwnershipVerifier struct{}
+
+func (botOwnershipVerifier) title() string {
+	return "添加机器人到频道"
+}
+
+func (botOwnershipVerifier) newCheck(app models.ChannelApplication) (models.OwnershipCheck, error) {
+	return models.OwnershipCheck{ApplicationID: app.ID, Method: models.OwnershipBot}, nil
+}
+
+func (botOwnershipVerifier) instructions(h *Handler, _ models.ChannelApplication, _ models.OwnershipCheck) string {
+	return fmt.Sprintf("请由您本人将 @%s 添加到频道（如果机器人已在频道中，请先移除再重新添加），验证通过后可以将机器人移出频道", h.Bot.Self.UserName)
+}
+
+func (botOwnershipVerifier) verify(*Handler, models.ChannelApplication) (bool, error) {
+	return false, nil
+}
+
+// newOwnershipCode 生成一次性验证码
+func newOwnershipCode() (string, error) {
+	buf := make([]byte, ownershipCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = ownershipCodeAlphabet[int(b)%len(ownershipCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// formatOwnershipPolicy 格式化群组要求的所有权验证方式
+func formatOwnershipPolicy(policy models.OwnershipPolicy) string {
+	if len(policy) == 0 {
+		return "不验证（认领人确认即可）"
+	}
+	titles := make([]string, 0, len(policy))
+	for _, method := range policy {
+		titles = append(titles, ownershipVerifiers[method].title())
+	}
+	return strings.Join(titles, "、")
+}
+
+// ownershipProgress 检查申请的各项所有权验证，返回验证进度说明和是否已全部通过
+func (h *Handler) ownershipProgress(app models.ChannelApplication, policy models.OwnershipPolicy) (string, bool, error) {
+	checks, err := h.DB.GetOwnershipChecks(app.ID)
+	if err != nil {
+		return "", false, err
+	}
+	byMethod := make(map[string]models.OwnershipCheck, len(checks))
+	for _, check := range checks {
+		byMethod[check.Method] = check
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("认领频道「%s」的申请 #%d 需要通过以下所有权验证:\n", h.getChannelName(app.ChannelID), app.ID))
+
+	allPassed := true
+	for i, method := range policy {
+		verifier := ownershipVerifiers[method]
+		check, exists := byMethod[method]
+		if !exists {
+			// 认领后群组才新增的验证方式
+			if check, err = verifier.newCheck(app); err != nil {
+				return "", false, err
+			}
+			if err := h.DB.AddOwnershipCheck(check); err != nil {
+				return "", false, err
+			}
+		}
+
+		var problem string
+		if !check.Passed() {
+			passed, err := verifier.verify(h, app)
+			if err != nil {
+				problem = err.Error()
+			}
+			if passed {
+				if err := h.DB.PassOwnershipCheck(app.ID, method); err != nil {
+					return "", false, err
+				}
+				check.PassedAt = time.Now()
+			}
+		}
+
+		if check.Passed() {
+			sb.WriteString(fmt.Sprintf("\n%d. ✅ %s: 已通过\n", i+1, verifier.title()))
+			continue
+		}
+		allPassed = false
+		sb.WriteString(fmt.Sprintf("\n%d. ⏳ %s: %s\n", i+1, verifier.title(), verifier.instructions(h, app, check)))
+		if problem != "" {
+			sb.WriteString("   ⚠️ " + problem + "\n")
+		}
+	}
+
+	sb.WriteString("\n完成后点击「重新检查」，全部通过后管理员才会收到申请")
+	return sb.String(), allPassed, nil
+}
+
+// ownershipKeyboard 所有权验证说明下方的重新检查按钮
+func ownershipKeyboard(applicationID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔄 重新检查", fmt.Sprintf("verify:%d", applicationID)),
+	))
+}
+
+// claimApplication 将申请的认领人设为 userID。尚未通过所有权验证的认领可以被其他用户取代，
+// 原认领人的验证记录会被清除，并私聊告知原认领人
+func (h *Handler) claimApplication(chatID, channelID, userID int64) error {
+	previous, err := h.DB.GetPendingChannelApplication(chatID, channelID)
+	if err != nil {
+		return err
+	}
+	if err := h.DB.UpdateChannelApplicationUser(chatID, channelID, userID); err != nil {
+		return err
+	}
+
+	if previous.UserID != 0 && previous.UserID != userID {
+		notifyMsg := tgbotapi.NewMessage(previous.UserID,
+			fmt.Sprintf("您认领的频道「%s」的申请尚未通过所有权验证，已被其他用户重新认领", h.getChannelName(channelID)))
+		_, _ = h.Bot.Send(notifyMsg)
+	}
+	return nil
+}
+
+// beginOwnershipVerification 认领人认领申请后，按群组设置验证频道所有权。
+// 群组没有要求验证或验证已全部通过时标记所有权已验证并返回 true；否则将验证说明发送到 replyChatID 并返回 false
+func (h *Handler) beginOwnershipVerification(chatID, channelID, userID, replyChatID int64) (bool, error) {
+	settings, err := h.DB.GetOrCreateGroupSettings(chatID)
+	if err != nil {
+		return false, err
+	}
+	if len(settings.OwnershipPolicy) == 0 {
+		return true, h.DB.VerifyChannelOwnership(chatID, channelID, userID)
+	}
+
+	app, err := h.DB.GetPendingChannelApplication(chatID, channelID)
+	if err != nil {
+		return false, err
+	}
+	if app.ID == 0 || app.UserID != userID {
+		return false, fmt.Errorf("未找到该频道的待处理申请")
+	}
+
+	for _, method := range settings.OwnershipPolicy {
+		check, err := ownershipVerifiers[method].newCheck(app)
+		if err != nil {
+			return false, err
+		}
+		if err := h.DB.AddOwnershipCheck(check); err != nil {
+			return false, err
+		}
+	}
+
+	progress, passed, err := h.ownershipProgress(app, settings.OwnershipPolicy)
+	if err != nil {
+		return false, err
+	}
+	if passed {
+		return true, h.DB.VerifyChannelOwnership(chatID, channelID, userID)
+	}
+
+	msg := tgbotapi.NewMessage(replyChatID, progress)
+	msg.ReplyMarkup = ownershipKeyboard(app.ID)
+	_, err = h.Bot.Send(msg)
+	return false, err
+}
+
+// completeOwnershipVerification 认领人通过全部所有权验证后，标记所有权已验证，通知管理员审核并告知认领人
+func (h *Handler) completeOwnershipVerification(app models.ChannelApplication) error {
+	if err := h.DB.VerifyChannelOwnership(app.ChatID, app.ChannelID, app.UserID); err != nil {
+		return err
+	}
+
+	channelName := h.getChannelName(app.ChannelID)
+	if err := h.notifyAdminsAboutApplication(app.ChatID, app.ChannelID, channelName); err != nil {
+		return err
+	}
+
+	notifyMsg := tgbotapi.NewMessage(app.UserID, fmt.Sprintf("频道「%s」的所有权验证已全部通过，申请已提交给管理员审核", channelName))
+	_, _ = h.Bot.Send(notifyMsg)
+	return nil
+}
+
+// refreshOwnershipVerification 重新检查认领人的所有权验证，全部通过时完成验证。返回验证进度说明和是否已通过
+func (h *Handler) refreshOwnershipVerification(app models.ChannelApplication) (string, bool, error) {
+	settings, err := h.DB.GetOrCreateGroupSettings(app.ChatID)
+	if err != nil {
+		return "", false, err
+	}
+
+	progress, passed, err := h.ownershipProgress(app, settings.OwnershipPolicy)
+	if err != nil || !passed {
+		return progress, false, err
+	}
+	return progress, true, h.completeOwnershipVerification(app)
+}
+
+// handleOwnershipCallback 处理认领人点击的重新检查按钮，回调数据为 verify:申请ID
+func (h *Handler) handleOwnershipCallback(query *tgbotapi.CallbackQuery) error {
+	applicationID, err := strconv.ParseInt(strings.TrimPrefix(query.Data, "verify:"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
+	}
+
+	app, err := h.DB.GetChannelApplicationByID(applicationID)
+	if err != nil {
+		return err
+	}
+
+	var alert string
+	switch {
+	case app.ID == 0 || app.Status != "pending":
+		alert = "该申请不存在或已被处理"
+	case app.UserID != query.From.ID:
+		alert = "只有认领人可以进行验证"
+	case app.VerifiedChannel:
+		alert = "所有权验证已经通过，请等待管理员审核"
+	}
+	if alert != "" {
+		callback := tgbotapi.NewCallback(query.ID, alert)
+		_, _ = h.Bot.Request(callback)
+		return nil
+	}
+
+	progress, passed, err := h.refreshOwnershipVerification(app)
+	if err != nil {
+		callback := tgbotapi.NewCallback(query.ID, "检查失败: "+err.Error())
+		_, _ = h.Bot.Request(callback)
+		return err
+	}
+
+	if passed {
+		callback := tgbotapi.NewCallback(query.ID, "验证已全部通过")
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+			fmt.Sprintf("频道「%s」的所有权验证已全部通过，管理员将尽快审核", h.getChannelName(app.ChannelID)))
+		_, err := h.Bot.Send(editMsg)
+		return err
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, "仍有验证未通过")
+	_, _ = h.Bot.Request(callback)
+
+	// 内容未变化时编辑会失败，忽略即可
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, progress, ownershipKeyboard(app.ID))
+	_, _ = h.Bot.Send(editMsg)
+	return nil
+}
+
+// handleOwnershipCode 检查频道在群组中发送的消息是否包含认领验证码，包含时完成该项验证并返回 true
+func (h *Handler) handleOwnershipCode(message *tgbotapi.Message, channelID int64) (bool, error) {
+	text := strings.ToUpper(message.Text + " " + message.Caption)
+	if strings.TrimSpace(text) == "" {
+		return false, nil
+	}
+
+	app, err := h.DB.GetPendingChannelApplication(message.Chat.ID, channelID)
+	if err != nil || app.ID == 0 || app.UserID == 0 || app.VerifiedChannel {
+		return false, err
+	}
+
+	checks, err := h.DB.GetOwnershipChecks(app.ID)
+	if err != nil {
+		return false, err
+	}
+	var matched bool
+	for _, check := range checks {
+		if check.Method == models.OwnershipCode && !check.Passed() && check.Code != "" && strings.Contains(text, check.Code) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false, nil
+	}
+
+	if err := h.DB.PassOwnershipCheck(app.ID, models.OwnershipCode); err != nil {
+		return true, err
+	}
+	return true, h.notifyOwnershipProgress(app, "已收到频道发送的验证码")
+}
+
+// handleBotAddedToChannel 机器人被加入频道时，完成添加者对该频道待处理申请的验证
+func (h *Handler) handleBotAddedToChannel(update *tgbotapi.ChatMemberUpdated) error {
+	applications, err := h.DB.GetPendingApplications()
+	if err != nil {
+		return err
+	}
+
+	for _, app := range applications {
+		if app.ChannelID != update.Chat.ID || app.UserID != update.From.ID || app.VerifiedChannel {
+			continue
+		}
+		settings, err := h.DB.GetOrCreateGroupSettings(app.ChatID)
+		if err != nil {
+			return err
+		}
+		if !settings.OwnershipPolicy.Has(models.OwnershipBot) {
+			continue
+		}
+
+		// 认领后群组才新增该验证方式时，先创建验证记录
+		check, err := ownershipVerifiers[models.OwnershipBot].newCheck(app)
+		if err != nil {
+			return err
+		}
+		if err := h.DB.AddOwnershipCheck(check); err != nil {
+			return err
+		}
+		if err := h.DB.PassOwnershipCheck(app.ID, models.OwnershipBot); err != nil {
+			return err
+		}
+		if err := h.notifyOwnershipProgress(app, "已检测到您将机器人添加到频道"); err != nil {
+			fmt.Printf("更新申请 #%d 的所有权验证失败: %s\n", app.ID, err.Error())
+		}
+	}
+	return nil
+}
+
+// notifyOwnershipProgress 一项验证通过后重新检查，并私聊告知认领人验证进度
+func (h *Handler) notifyOwnershipProgress(app models.ChannelApplication, event string) error {
+	progress, passed, err := h.refreshOwnershipVerification(app)
+	if err != nil || passed {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(app.UserID, event+"\n\n"+progress)
+	msg.ReplyMarkup = ownershipKeyboard(app.ID)
+	_, _ = h.Bot.Send(msg)
+	return nil
+}
+
+// HandleOwnership 查看或设置认领申请时需要通过的频道所有权验证
+func (h *Handler) HandleOwnership(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(strings.ToLower(args))
+	if len(fields) == 0 {
+		text := fmt.Sprintf("所有权验证: %s\n\n"+
+			"使用 /ownership 方式... 设置认领申请时需要全部通过的验证，可选:\n"+
+			"admin - 认领人是频道的管理员\n"+
+			"code - 频道在群组中发送一次性验证码\n"+
+			"bot - 认领人将机器人添加到频道\n"+
+			"使用 /ownership off 取消验证，认领人确认即可", formatOwnershipPolicy(settings.OwnershipPolicy))
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	var policy models.OwnershipPolicy
+	if !(len(fields) == 1 && fields[0] == "off") {
+		for _, field := range fields {
+			if _, ok := ownershipVerifiers[field]; !ok {
+				msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("未知的验证方式: %s，可选 admin、code、bot 或 off", field))
+				_, err := h.Bot.Send(msg)
+				return err
+			}
+		}
+		policy = models.ParseOwnershipPolicy(strings.Join(fields, ","))
+	}
+
+	before := settings.OwnershipPolicy
+	settings.OwnershipPolicy = policy
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新设置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0,
+		"所有权验证: "+formatOwnershipPolicy(before), "所有权验证: "+formatOwnershipPolicy(policy))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("所有权验证已设置为: %s\n已认领但尚未通过验证的申请会按新的设置检查", formatOwnershipPolicy(policy)))
+	_, err = h.Bot.Send(msg)
+	return err
+}
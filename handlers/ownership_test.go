@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// requireOwnership 设置测试群组要求的所有权验证方式
+func requireOwnership(t *testing.T, h *Handler, methods string) {
+	t.Helper()
+
+	settings, err := h.DB.GetOrCreateGroupSettings(testChatID)
+	if err != nil {
+		t.Fatalf("获取群组设置失败: %v", err)
+	}
+	settings.OwnershipPolicy = models.ParseOwnershipPolicy(methods)
+	if err := h.DB.UpdateGroupSettings(settings); err != nil {
+		t.Fatalf("更新群组设置失败: %v", err)
+	}
+}
+
+func TestOwnershipAdminVerifier(t *testing.T) {
+	h, _ := newTestHandler(t)
+	requireOwnership(t, h, models.OwnershipAdmin)
+
+	if err := h.DB.CreateChannelApplication(testChatID, testChannelID, 0, "理由"); err != nil {
+		t.Fatalf("创建申请失败: %v", err)
+	}
+
+	// 不是频道管理员的用户认领后无法通过验证
+	if err := h.HandleCallbackQuery(claimCallback(3, testChatID, testChannelID)); err != nil {
+		t.Fatalf("认领申请失败: %v", err)
+	}
+	app, err := h.DB.GetPendingChannelApplication(testChatID, testChannelID)
+	if err != nil || app.UserID != 3 || app.VerifiedChannel {
+		t.Fatalf("认领后申请为 %+v（%v），期望认领人 3 且未通过验证", app, err)
+	}
+
+	// 频道管理员可以取代未通过验证的认领并直接通过验证
+	if err := h.HandleCallbackQuery(claimCallback(testOwnerID, testChatID, testChannelID)); err != nil {
+		t.Fatalf("重新认领申请失败: %v", err)
+	}
+	if app, err = h.DB.GetPendingChannelApplication(testChatID, testChannelID); err != nil || app.UserID != testOwnerID || !app.VerifiedChannel {
+		t.Fatalf("频道管理员认领后申请为 %+v（%v），期望已通过验证", app, err)
+	}
+}
+
+func TestOwnershipCodeVerifier(t *testing.T) {
+	h, fake := newTestHandler(t)
+	requireOwnership(t, h, models.OwnershipCode)
+
+	if err := h.DB.CreateChannelApplication(testChatID, testChannelID, 0, "理由"); err != nil {
+		t.Fatalf("创建申请失败: %v", err)
+	}
+	if err := h.HandleCallbackQuery(claimCallback(testOwnerID, testChatID, testChannelID)); err != nil {
+		t.Fatalf("认领申请失败: %v", err)
+	}
+	app, err := h.DB.GetPendingChannelApplication(testChatID, testChannelID)
+	if err != nil || app.VerifiedChannel {
+		t.Fatalf("认领后申请为 %+v（%v），期望尚未通过验证", app, err)
+	}
+
+	checks, err := h.DB.GetOwnershipChecks(app.ID)
+	if err != nil || len(checks) != 1 || checks[0].Code == "" {
+		t.Fatalf("验证记录为 %+v（%v），期望一项验证码验证", checks, err)
+	}
+	expectSent(t, fake, testOwnerID, checks[0].Code)
+
+	// 错误的验证码按普通的违规消息处理
+	if err := h.HandleMessage(channelMessage(10, testChannelID, "WRONGCODE")); err != nil {
+		t.Fatalf("处理频道消息失败: %v", err)
+	}
+	if app, _ = h.DB.GetPendingChannelApplication(testChatID, testChannelID); app.VerifiedChannel {
+		t.Fatal("错误的验证码通过了验证")
+	}
+
+	// 频道在群组中发送验证码后通过验证，管理员收到申请通知
+	if err := h.HandleMessage(channelMessage(11, testChannelID, "验证码 "+checks[0].Code)); err != nil {
+		t.Fatalf("处理验证码消息失败: %v", err)
+	}
+	if app, err = h.DB.GetPendingChannelApplication(testChatID, testChannelID); err != nil || !app.VerifiedChannel {
+		t.Fatalf("发送验证码后申请为 %+v（%v），期望已通过验证", app, err)
+	}
+	if !fake.deleted(testChatID, 11) {
+		t.Fatal("验证码消息没有被删除")
+	}
+	expectSent(t, fake, testGroupAdminID, "理由")
+}
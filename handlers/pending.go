@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pendingPageSize /pending 每页显示的申请数量
+const pendingPageSize = 5
+
+// canViewPending 检查用户能否查看待处理的申请。chatID 为 0 表示所有群组，只有全局管理员可以查看
+func (h *Handler) canViewPending(userID, chatID int64) (bool, error) {
+	if chatID == 0 {
+		return utils.IsGlobalAdmin(h.Config.AdminUsers, userID), nil
+	}
+	return h.canReviewApplication(userID, chatID)
+}
+
+// HandlePending 分页列出待处理的申请，已认领的申请可以直接点击按钮批准或拒绝。
+// 在群组中列出本群的申请；私聊中可以指定群组ID，不指定时列出所有群组的申请（仅全局管理员）
+func (h *Handler) HandlePending(message *tgbotapi.Message, args string) error {
+	var chatID int64
+	switch message.Chat.Type {
+	case "group", "supergroup":
+		// 检查权限
+		if ok, err := h.requireGroupAdmin(message); !ok {
+			return err
+		}
+		chatID = message.Chat.ID
+	case "private":
+		if arg := strings.TrimSpace(args); arg != "" {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil || id == 0 {
+				msg := tgbotapi.NewMessage(message.Chat.ID, "格式：/pending [群组ID]")
+				_, err := h.Bot.Send(msg)
+				return err
+			}
+			chatID = id
+		}
+
+		ok, err := h.canViewPending(message.From.ID, chatID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			text := "您不是该群组的管理员"
+			if chatID == 0 {
+				text = "只有全局管理员可以查看所有群组的申请，请使用 /pending 群组ID 或在群组中使用此命令"
+			}
+			msg := tgbotapi.NewMessage(message.Chat.ID, text)
+			_, err := h.Bot.Send(msg)
+			return err
+		}
+	default:
+		return nil
+	}
+
+	text, markup, err := h.buildPendingPage(chatID, 0, "")
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("获取申请失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// buildPendingPage 生成指定页的待处理申请文本和按钮，status 不为空时显示在开头
+func (h *Handler) buildPendingPage(chatID int64, page int, status string) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	applications, total, err := h.DB.GetPendingApplicationsPage(chatID, pendingPageSize, page*pendingPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+
+	text := ""
+	if status != "" {
+		text = status + "\n\n"
+	}
+	if total == 0 {
+		return text + "没有待处理的申请", nil, nil
+	}
+
+	// 审核后最后一页可能已经没有申请，退回到新的最后一页
+	pages := (total + pendingPageSize - 1) / pendingPageSize
+	if page >= pages {
+		page = pages - 1
+		applications, total, err = h.DB.GetPendingApplicationsPage(chatID, pendingPageSize, page*pendingPageSize)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	text += fmt.Sprintf("📋 待处理的申请（第 %d/%d 页，共 %d 条）:\n\n", page+1, pages, total)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, app := range applications {
+		text += fmt.Sprintf("#%d %s\n频道: %s", app.ID, app.AppliedAt.Format("2006-01-02 15:04"), h.getChannelName(app.ChannelID))
+		if chatID == 0 {
+			text += fmt.Sprintf("\n群组: %d", app.ChatID)
+		}
+		text += "\n申请人: " + formatUserID(app.UserID, "未认领")
+		if app.Reason != "" {
+			text += "\n申请理由: " + app.Reason
+		}
+		if votes, err := h.DB.GetApplicationVotes(app.ID); err == nil && len(votes) > 0 {
+			if settings, err := h.DB.GetOrCreateGroupSettings(app.ChatID); err == nil {
+				text += "\n" + formatVoteTally(votes, reviewQuorum(settings))
+			}
+		}
+		if !app.VerifiedChannel {
+			text += "\n⚠️ 尚未经过认领验证，暂不能审核"
+		} else {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ 批准 #%d", app.ID), fmt.Sprintf("pending:approve:%d:%d:%d", app.ID, chatID, page)),
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("❌ 拒绝 #%d", app.ID), fmt.Sprintf("pending:reject:%d:%d:%d", app.ID, chatID, page)),
+			))
+		}
+		text += "\n\n"
+	}
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("⬅️ 上一页", fmt.Sprintf("pending:page:%d:%d", chatID, page-1)))
+	}
+	if page+1 < pages {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("下一页 ➡️", fmt.Sprintf("pending:page:%d:%d", chatID, page+1)))
+	}
+	if len(buttons) > 0 {
+		rows = append(rows, buttons)
+	}
+	if len(rows) == 0 {
+		return text, nil, nil
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return text, &markup, nil
+}
+
+// handlePendingCallback 处理待处理申请列表的翻页、批准和拒绝按钮。
+// 回调数据为 pending:page:群组ID:页码 或 pending:approve|reject:申请ID:群组ID:页码
+func (h *Handler) handlePendingCallback(query *tgbotapi.CallbackQuery) error {
+	parts := strings.Split(query.Data, ":")
+	if len(parts) < 4 {
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
+	}
+
+	var applicationID int64
+	if parts[1] != "page" {
+		if len(parts) != 5 {
+			return fmt.Errorf("无效的回调数据: %s", query.Data)
+		}
+		id, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		applicationID = id
+		parts = append(parts[:2], parts[3:]...)
+	}
+
+	chatID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return err
+	}
+	page, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return err
+	}
+
+	// 只有能查看这些申请的管理员可以操作
+	ok, err := h.canViewPending(query.From.ID, chatID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		callback := tgbotapi.NewCallback(query.ID, "您没有权限执行此操作")
+		_, _ = h.Bot.Request(callback)
+		return nil
+	}
+
+	var status string
+	if applicationID != 0 {
+		status, err = h.reviewFromPending(applicationID, parts[1] == "approve", query.From.ID)
+		if err != nil {
+			callback := tgbotapi.NewCallback(query.ID, err.Error())
+			_, _ = h.Bot.Request(callback)
+			return err
+		}
+	}
+
+	text, markup, err := h.buildPendingPage(chatID, page, status)
+	if err != nil {
+		callback := tgbotapi.NewCallback(query.ID, "获取申请失败")
+		_, _ = h.Bot.Request(callback)
+		return err
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, status)
+	_, _ = h.Bot.Request(callback)
+
+	var editMsg tgbotapi.EditMessageTextConfig
+	if markup != nil {
+		editMsg = tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, *markup)
+	} else {
+		editMsg = tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
+	}
+	_, err = h.Bot.Send(editMsg)
+	return err
+}
+
+// reviewFromPending 在待处理申请列表中批准申请，或私聊发送拒绝理由选择菜单，返回显示在列表开头的结果。
+// 申请已被处理等无法审核的情况作为结果返回，只有审核失败时返回错误
+func (h *Handler) reviewFromPending(applicationID int64, approve bool, reviewerID int64) (string, error) {
+	app, err := h.applicationForReview(applicationID, reviewerID)
+	if err != nil {
+		return "⚠️ " + err.Error(), nil
+	}
+
+	// 拒绝前先私聊选择拒绝理由
+	if !approve {
+		if err := h.sendRejectReasonPicker(reviewerID, app); err != nil {
+			return "⚠️ 无法发送私聊消息，请先私聊机器人", nil
+		}
+		return fmt.Sprintf("请在私聊中选择拒绝申请 #%d 的理由", app.ID), nil
+	}
+
+	result, err := h.voteOnApplication(app, reviewerID, models.VoteApprove, "")
+	if err != nil {
+		return "", err
+	}
+	return "✅ " + result, nil
+}
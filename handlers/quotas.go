@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db"
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	maxQuotaPosts  = 1000               // 发言配额允许设置的最大消息数
+	maxQuotaWindow = 7 * 24 * time.Hour // 发言配额允许设置的最长统计窗口，更早的发言记录会被清理
+)
+
+// quotaUsage /quota 命令的用法
+const quotaUsage = "用法:\n" +
+	"/quota 频道 - 查看频道的发言配额\n" +
+	"/quota 频道 5/24h - 设置频道在 24 小时内最多发送 5 条消息\n" +
+	"/quota 频道 off - 取消频道的发言配额\n\n" +
+	"也可以回复频道消息使用，时长支持单位 m、h、d、w，最长 7d"
+
+// parseQuota 解析 "次数/时长" 格式的发言配额，如 5/24h、1/1h
+func parseQuota(arg string) (int, time.Duration, error) {
+	parts := strings.SplitN(arg, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("无效的发言配额: %s，格式为 次数/时长，如 5/24h", arg)
+	}
+
+	posts, err := strconv.Atoi(parts[0])
+	if err != nil || posts <= 0 || posts > maxQuotaPosts {
+		return 0, 0, fmt.Errorf("消息数必须在 1 到 %d 之间", maxQuotaPosts)
+	}
+
+	window, err := utils.ParseDuration(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if window < time.Minute || window > maxQuotaWindow {
+		return 0, 0, fmt.Errorf("统计窗口必须在 1 分钟到 %s 之间", utils.FormatDuration(maxQuotaWindow))
+	}
+	return posts, window, nil
+}
+
+// formatQuota 格式化白名单频道的发言配额
+func formatQuota(channel models.WhitelistedChannel) string {
+	if !channel.HasQuota() {
+		return "不限制"
+	}
+	return fmt.Sprintf("%s内最多 %d 条", utils.FormatDuration(channel.QuotaWindow), channel.QuotaPosts)
+}
+
+// channelQuotaUsage 统计频道在当前配额窗口内已发送的消息数量
+func (h *Handler) channelQuotaUsage(channel models.WhitelistedChannel) (int, error) {
+	return h.DB.CountChannelPostsSince(channel.ChatID, channel.ChannelID, time.Now().Add(-channel.QuotaWindow))
+}
+
+// enforceQuota 检查白名单频道是否超出发言配额。未超出时记录本次发言；
+// 超出时删除消息，以超出配额为原因记录被阻止的消息，并每天提示一次，返回 true。
+// 观察模式下超出配额的消息只记录，不删除也不提示
+func (h *Handler) enforceQuota(message *tgbotapi.Message, channel models.WhitelistedChannel, observe bool) (bool, error) {
+	if !channel.HasQuota() {
+		return false, nil
+	}
+	chatID, channelID := message.Chat.ID, channel.ChannelID
+
+	used, err := h.channelQuotaUsage(channel)
+	if err != nil {
+		return false, err
+	}
+	if used < channel.QuotaPosts {
+		return false, h.DB.RecordChannelPost(chatID, channelID, time.Now())
+	}
+
+	if observe {
+		go h.addObservedToMessageQueue(chatID, channelID, message.MessageID, message.Text, models.BlockReasonQuota)
+		return true, nil
+	}
+
+	go h.deleteMessageWithTimeout(chatID, message.MessageID)
+	go h.addToMessageQueueWithReason(chatID, channelID, message.MessageID, message.Text, models.BlockReasonQuota)
+
+	// 每个频道每天只提示一次
+	noticed, err := h.DB.HasChannelDailyPrompt(chatID, channelID, db.PromptTypeQuotaNotice)
+	if err != nil || noticed {
+		return true, err
+	}
+
+	noticeText := fmt.Sprintf("频道「%s」已超出发言配额（%s），超出的消息会被删除，配额会随时间自动恢复。",
+		h.getChannelName(channelID), formatQuota(channel))
+	if _, err := h.Bot.Send(tgbotapi.NewMessage(chatID, noticeText)); err == nil {
+		_ = h.DB.RecordChannelDailyPrompt(chatID, channelID, db.PromptTypeQuotaNotice)
+	}
+	return true, nil
+}
+
+// pruneChannelPosts 清理超出最长配额窗口的发言记录
+func (h *Handler) pruneChannelPosts() {
+	if _, err := h.DB.PruneChannelPosts(time.Now().Add(-maxQuotaWindow)); err != nil {
+		fmt.Printf("清理发言记录失败: %s\n", err.Error())
+	}
+}
+
+// HandleQuota 查看或设置白名单频道的发言配额，支持回复频道消息或提供频道
+func (h *Handler) HandleQuota(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	fields := strings.Fields(args)
+
+	var channelID int64
+	if message.ReplyToMessage != nil && utils.IsChannelMessage(message.ReplyToMessage) {
+		channelID = utils.GetChannelID(message.ReplyToMessage)
+	} else if len(fields) > 0 {
+		var err error
+		channelID, _, err = h.resolveChannel(fields[0])
+		if err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的频道: %s", err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+		fields = fields[1:]
+	} else {
+		msg := tgbotapi.NewMessage(message.Chat.ID, quotaUsage)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	channel, err := h.DB.GetWhitelistEntry(message.Chat.ID, channelID)
+	if err != nil {
+		return err
+	}
+	if channel.ID == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "该频道不在本群的白名单中，只能为白名单频道设置发言配额")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	channelName := h.getChannelName(channelID)
+
+	// 查看当前配额
+	if len(fields) == 0 {
+		text := fmt.Sprintf("频道「%s」的发言配额: %s", channelName, formatQuota(channel))
+		if channel.HasQuota() {
+			used, err := h.channelQuotaUsage(channel)
+			if err != nil {
+				return err
+			}
+			text += fmt.Sprintf("\n当前窗口内已发送: %d 条", used)
+		}
+		msg := tgbotapi.NewMessage(message.Chat.ID, text+"\n\n"+quotaUsage)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	var posts int
+	var window time.Duration
+	if strings.ToLower(fields[0]) != "off" {
+		posts, window, err = parseQuota(fields[0])
+		if err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+	}
+
+	before := formatQuota(channel)
+	if err := h.DB.SetChannelQuota(message.Chat.ID, channelID, posts, window); err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("设置发言配额失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	channel.QuotaPosts = posts
+	channel.QuotaWindow = window
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionQuotaUpdate, channelID, before, formatQuota(channel))
+
+	var text string
+	if channel.HasQuota() {
+		text = fmt.Sprintf("已将频道「%s」的发言配额设置为%s，超出的消息会被删除", channelName, formatQuota(channel))
+	} else {
+		text = fmt.Sprintf("已取消频道「%s」的发言配额", channelName)
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
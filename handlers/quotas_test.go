@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotaEnforced(t *testing.T) {
+	h, fake := newTestHandler(t)
+
+	if err := h.DB.AddChannelToWhitelist(testChatID, testChannelID, testGroupAdminID, "", "", time.Time{}); err != nil {
+		t.Fatalf("添加白名单失败: %v", err)
+	}
+	if err := h.DB.SetChannelQuota(testChatID, testChannelID, 2, time.Hour); err != nil {
+		t.Fatalf("设置发言配额失败: %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if err := h.HandleMessage(channelMessage(10+i, testChannelID, "消息")); err != nil {
+			t.Fatalf("处理第 %d 条消息失败: %v", i, err)
+		}
+	}
+	if !fake.deleted(testChatID, 13) {
+		t.Fatal("超出配额的消息没有被删除")
+	}
+	if fake.deleted(testChatID, 11) || fake.deleted(testChatID, 12) {
+		t.Fatal("配额内的消息被删除")
+	}
+	expectSent(t, fake, testChatID, "已超出发言配额")
+
+	// 超出配额不计入违规
+	h.flushMessageQueue()
+	count, err := h.DB.CountBlockedMessagesSince(testChatID, testChannelID, time.Now().Add(-time.Minute))
+	if err != nil || count != 0 {
+		t.Fatalf("超出配额的消息计入了 %d 次违规（%v）", count, err)
+	}
+}
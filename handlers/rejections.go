@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxReapplyCooldown 申请被拒绝后重新申请允许设置的最长冷却时间
+const maxReapplyCooldown = 90 * 24 * time.Hour
+
+// rejectReasonState 等待管理员发送自定义拒绝理由的用户状态前缀，后接申请ID
+const rejectReasonState = "reject_reason:"
+
+// rejectReasonPresets 拒绝申请时可以直接选择的理由
+var rejectReasonPresets = []string{
+	"频道内容与本群主题无关",
+	"频道含有广告或推广内容",
+	"无法确认申请人是频道所有者",
+	"申请理由不充分",
+}
+
+// formatReapplyCooldown 格式化重新申请的冷却时间
+func formatReapplyCooldown(cooldown time.Duration) string {
+	if cooldown <= 0 {
+		return "不限制"
+	}
+	return utils.FormatDuration(cooldown)
+}
+
+// formatRejectReason 格式化拒绝理由，理由为空时返回空字符串
+func formatRejectReason(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return "\n拒绝理由: " + reason
+}
+
+// sendRejectReasonPicker 私聊发送拒绝理由选择菜单，管理员选择理由后才会投出拒绝票
+func (h *Handler) sendRejectReasonPicker(userID int64, app models.ChannelApplication) error {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, reason := range rejectReasonPresets {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(reason, fmt.Sprintf("rejreason:%d:%d", app.ID, i)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ 自定义理由", fmt.Sprintf("rejreason:%d:custom", app.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("不填写理由", fmt.Sprintf("rejreason:%d:none", app.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("取消", fmt.Sprintf("rejreason:%d:cancel", app.ID)),
+		),
+	)
+
+	text := fmt.Sprintf("请选择拒绝频道「%s」申请 #%d 的理由，理由会发送给申请人和群组:",
+		h.getChannelName(app.ChannelID), app.ID)
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_, err := h.Bot.Send(msg)
+	return err
+}
+
+// handleRejectReasonCallback 处理拒绝理由选择菜单。
+// 回调数据为 rejreason:申请ID:选项，选项为预设理由的序号、custom、none 或 cancel
+func (h *Handler) handleRejectReasonCallback(query *tgbotapi.CallbackQuery) error {
+	parts := strings.Split(query.Data, ":")
+	if len(parts) != 3 {
+		return fmt.Errorf("无效的回调数据: %s", query.Data)
+	}
+
+	applicationID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	choice := parts[2]
+
+	if choice == "cancel" {
+		// 只清除等待该申请理由的状态
+		if state, err := h.DB.GetUserState(query.From.ID); err == nil && state == fmt.Sprintf("%s%d", rejectReasonState, applicationID) {
+			_ = h.DB.ClearUserState(query.From.ID)
+		}
+
+		callback := tgbotapi.NewCallback(query.ID, "已取消")
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+			fmt.Sprintf("已取消拒绝申请 #%d", applicationID))
+		_, err := h.Bot.Send(editMsg)
+		return err
+	}
+
+	app, err := h.applicationForReview(applicationID, query.From.ID)
+	if err != nil {
+		callback := tgbotapi.NewCallback(query.ID, err.Error())
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, err.Error())
+		_, _ = h.Bot.Send(editMsg)
+		return nil
+	}
+
+	var reason string
+	switch choice {
+	case "custom":
+		// 不覆盖其他未完成的操作（如确认导入、补充申请理由），等待其他申请的拒绝理由时可以直接替换
+		state, err := h.DB.GetUserState(query.From.ID)
+		if err != nil {
+			return err
+		}
+		if state != "" && !strings.HasPrefix(state, rejectReasonState) {
+			callback := tgbotapi.NewCallback(query.ID, "您还有未完成的操作（如确认导入或补充申请理由），请先完成后再填写自定义理由，或选择预设理由")
+			callback.ShowAlert = true
+			_, _ = h.Bot.Request(callback)
+			return nil
+		}
+		if err := h.DB.SetUserState(query.From.ID, fmt.Sprintf("%s%d", rejectReasonState, app.ID)); err != nil {
+			return err
+		}
+
+		callback := tgbotapi.NewCallback(query.ID, "")
+		_, _ = h.Bot.Request(callback)
+
+		editMsg := tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID,
+			fmt.Sprintf("请直接发送拒绝申请 #%d 的理由", app.ID),
+			tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("取消", fmt.Sprintf("rejreason:%d:cancel", app.ID)),
+			)))
+		_, err = h.Bot.Send(editMsg)
+		return err
+	case "none":
+	default:
+		index, err := strconv.Atoi(choice)
+		if err != nil || index < 0 || index >= len(rejectReasonPresets) {
+			return fmt.Errorf("无效的回调数据: %s", query.Data)
+		}
+		reason = rejectReasonPresets[index]
+	}
+
+	result, err := h.voteOnApplication(app, query.From.ID, models.VoteReject, reason)
+	if err != nil {
+		callback := tgbotapi.NewCallback(query.ID, err.Error())
+		_, _ = h.Bot.Request(callback)
+		return err
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, result)
+	_, _ = h.Bot.Request(callback)
+
+	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, result+formatRejectReason(reason))
+	_, err = h.Bot.Send(editMsg)
+	return err
+}
+
+// handleRejectReasonInput 处理管理员私聊发送的自定义拒绝理由
+func (h *Handler) handleRejectReasonInput(message *tgbotapi.Message, state string) error {
+	applicationID, err := strconv.ParseInt(strings.TrimPrefix(state, rejectReasonState), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid state format: %s", state)
+	}
+
+	reason := strings.TrimSpace(message.Text)
+	if reason == "" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("请发送文字形式的拒绝理由，用于拒绝申请 #%d", applicationID))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	if err := h.DB.ClearUserState(message.From.ID); err != nil {
+		return err
+	}
+
+	app, err := h.applicationForReview(applicationID, message.From.ID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	result, err := h.voteOnApplication(app, message.From.ID, models.VoteReject, reason)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, result+formatRejectReason(reason))
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// HandleCooldown 查看或设置申请被拒绝后重新申请的冷却时间
+func (h *Handler) HandleCooldown(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	arg := strings.ToLower(strings.TrimSpace(args))
+	if arg == "" {
+		text := fmt.Sprintf("重新申请冷却时间: %s\n\n"+
+			"使用 /cooldown 时长（如 24h、7d）设置频道的申请被拒绝后多久才能再次申请，最长 %s\n"+
+			"使用 /cooldown off 取消限制", formatReapplyCooldown(settings.ReapplyCooldown), utils.FormatDuration(maxReapplyCooldown))
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	var cooldown time.Duration
+	if arg != "off" {
+		cooldown, err = utils.ParseDuration(arg)
+		if err == nil && cooldown > maxReapplyCooldown {
+			err = fmt.Errorf("冷却时间最长为 %s", utils.FormatDuration(maxReapplyCooldown))
+		}
+		if err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, err.Error())
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+	}
+
+	before := settings.ReapplyCooldown
+	settings.ReapplyCooldown = cooldown
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新设置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0,
+		"重新申请冷却: "+formatReapplyCooldown(before), "重新申请冷却: "+formatReapplyCooldown(cooldown))
+
+	text := "已取消重新申请的冷却时间"
+	if cooldown > 0 {
+		text = fmt.Sprintf("频道的申请被拒绝后，需要等待 %s 才能再次申请", utils.FormatDuration(cooldown))
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
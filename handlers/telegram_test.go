@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/config"
+	"github.com/anhe/tg-whitelist-bot/db"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	testGlobalAdminID = 1         // 全局管理员
+	testGroupAdminID  = 2         // 群组管理员
+	testOwnerID       = 7         // 频道所有者
+	testChatID        = -100      // 测试群组
+	testChannelID     = -1001     // 测试频道
+	testBotID         = 999999999 // 机器人自身
+)
+
+// telegramCall 机器人向 Telegram 发起的一次请求
+type telegramCall struct {
+	Method string
+	Params url.Values
+}
+
+// fakeTelegram 记录机器人发起的请求并返回固定的响应，用于在不访问 Telegram 的情况下测试处理器
+type fakeTelegram struct {
+	mu     sync.Mutex
+	calls  []telegramCall
+	admins map[int64][]int64 // 群组或频道的管理员
+	nextID int
+}
+
+// newTestHandler 创建使用内存存储和假 Telegram 接口的处理器，群组管理员为 testGroupAdminID，
+// 频道管理员为 testOwnerID
+func newTestHandler(t *testing.T) (*Handler, *fakeTelegram) {
+	t.Helper()
+
+	fake := &fakeTelegram{
+		admins: map[int64][]int64{
+			testChatID:    {testGroupAdminID},
+			testChannelID: {testOwnerID},
+		},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	bot := &tgbotapi.BotAPI{
+		Token:  "test",
+		Client: server.Client(),
+		Buffer: 100,
+		Self:   tgbotapi.User{ID: testBotID, IsBot: true, UserName: "test_bot"},
+	}
+	bot.SetAPIEndpoint(server.URL + "/bot%s/%s")
+
+	cfg := &config.Config{AdminUsers: []int64{testGlobalAdminID}, BlockedMessageRetentionDays: 30}
+	return New(bot, db.NewMemoryStore(), cfg), fake
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	method := path.Base(r.URL.Path)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, telegramCall{Method: method, Params: r.PostForm})
+	f.nextID++
+	messageID := f.nextID
+	f.mu.Unlock()
+
+	var result interface{} = true
+	switch {
+	case strings.HasPrefix(method, "send") || strings.HasPrefix(method, "editMessage"):
+		chatID, _ := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+		result = tgbotapi.Message{
+			MessageID: messageID,
+			From:      &tgbotapi.User{ID: testBotID, IsBot: true},
+			Chat:      &tgbotapi.Chat{ID: chatID},
+			Date:      int(time.Now().Unix()),
+			Text:      r.PostForm.Get("text"),
+		}
+	case method == "getChat":
+		chatID, err := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+		if err != nil {
+			chatID = testChannelID
+		}
+		result = tgbotapi.Chat{ID: chatID, Type: "channel", Title: fmt.Sprintf("频道%d", chatID)}
+	case method == "getChatAdministrators":
+		chatID, _ := strconv.ParseInt(r.PostForm.Get("chat_id"), 10, 64)
+		members := []tgbotapi.ChatMember{}
+		f.mu.Lock()
+		for _, userID := range f.admins[chatID] {
+			members = append(members, tgbotapi.ChatMember{User: &tgbotapi.User{ID: userID}, Status: "administrator"})
+		}
+		f.mu.Unlock()
+		result = members
+	}
+
+	raw, _ := json.Marshal(result)
+	_ = json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+// callsTo 返回对指定方法的全部请求
+func (f *fakeTelegram) callsTo(method string) []telegramCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []telegramCall
+	for _, call := range f.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// sentTexts 返回发送到 chatID 的全部消息文本
+func (f *fakeTelegram) sentTexts(chatID int64) []string {
+	var texts []string
+	for _, call := range f.callsTo("sendMessage") {
+		if call.Params.Get("chat_id") == strconv.FormatInt(chatID, 10) {
+			texts = append(texts, call.Params.Get("text"))
+		}
+	}
+	return texts
+}
+
+// deleted 判断消息是否被删除。删除在后台执行，最多等待一秒
+func (f *fakeTelegram) deleted(chatID int64, messageID int) bool {
+	deadline := time.Now().Add(time.Second)
+	for {
+		for _, call := range f.callsTo("deleteMessage") {
+			if call.Params.Get("chat_id") == strconv.FormatInt(chatID, 10) &&
+				call.Params.Get("message_id") == strconv.Itoa(messageID) {
+				return true
+			}
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// expectSent 断言发送到 chatID 的消息中有包含 want 的消息
+func expectSent(t *testing.T, fake *fakeTelegram, chatID int64, want string) {
+	t.Helper()
+	texts := fake.sentTexts(chatID)
+	for _, text := range texts {
+		if strings.Contains(text, want) {
+			return
+		}
+	}
+	t.Fatalf("没有发送到 %d 的包含「%s」的消息，实际发送了 %q", chatID, want, texts)
+}
+
+// commandMessage 构造用户在 chat 中发送的命令消息
+func commandMessage(chat *tgbotapi.Chat, fromID int64, text string) *tgbotapi.Message {
+	command := strings.Fields(text)[0]
+	return &tgbotapi.Message{
+		MessageID: int(time.Now().UnixNano() % 1000000),
+		From:      &tgbotapi.User{ID: fromID, FirstName: "用户"},
+		Chat:      chat,
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	}
+}
+
+// channelMessage 构造频道在测试群组中发送的消息，以 / 开头的文本视为命令
+func channelMessage(messageID int, channelID int64, text string) *tgbotapi.Message {
+	message := &tgbotapi.Message{
+		MessageID:  messageID,
+		From:       &tgbotapi.User{ID: 136817688, IsBot: true, UserName: "Channel_Bot"},
+		SenderChat: &tgbotapi.Chat{ID: channelID, Type: "channel", Title: fmt.Sprintf("频道%d", channelID)},
+		Chat:       groupChat(),
+		Date:       int(time.Now().Unix()),
+		Text:       text,
+	}
+	if strings.HasPrefix(text, "/") {
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(strings.Fields(text)[0])}}
+	}
+	return message
+}
+
+// groupChat 测试群组
+func groupChat() *tgbotapi.Chat {
+	return &tgbotapi.Chat{ID: testChatID, Type: "supergroup", Title: "测试群组"}
+}
+
+// privateChat 与用户的私聊
+func privateChat(userID int64) *tgbotapi.Chat {
+	return &tgbotapi.Chat{ID: userID, Type: "private"}
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxReviewQuorum 批准申请最多可以要求的管理员票数
+const maxReviewQuorum = 10
+
+// reviewQuorum 返回群组批准申请实际需要的票数，未设置时为 1
+func reviewQuorum(settings models.GroupSettings) int {
+	if settings.ReviewQuorum < 1 {
+		return 1
+	}
+	return settings.ReviewQuorum
+}
+
+// formatReviewQuorum 格式化申请审核的票数设置
+func formatReviewQuorum(quorum int) string {
+	if quorum <= 1 {
+		return "1 位管理员即可决定"
+	}
+	return fmt.Sprintf("需要 %d 位管理员批准，任何一位拒绝即拒绝", quorum)
+}
+
+// formatVoteTally 格式化申请的投票进度
+func formatVoteTally(votes []models.ApplicationVote, quorum int) string {
+	approvals := 0
+	var lines []string
+	for _, vote := range votes {
+		mark := "❌"
+		if vote.Vote == models.VoteApprove {
+			mark = "✅"
+			approvals++
+		}
+		line := fmt.Sprintf("%s %s", mark, formatUserID(vote.VoterID, "未知"))
+		if vote.Note != "" {
+			line += "：" + vote.Note
+		}
+		lines = append(lines, line)
+	}
+
+	text := fmt.Sprintf("审核进度: 批准 %d/%d", approvals, quorum)
+	if len(lines) > 0 {
+		text += "\n" + strings.Join(lines, "\n")
+	}
+	return text
+}
+
+// countApprovals 统计批准票数，并返回是否有人投了拒绝票
+func countApprovals(votes []models.ApplicationVote) (int, bool) {
+	approvals := 0
+	rejected := false
+	for _, vote := range votes {
+		if vote.Vote == models.VoteApprove {
+			approvals++
+		} else {
+			rejected = true
+		}
+	}
+	return approvals, rejected
+}
+
+// voteOnApplication 记录管理员对申请的投票。有人拒绝时立即拒绝申请，批准票数达到群组设置的票数时批准申请，
+// 并在所有管理员的申请通知中更新投票进度。返回给投票人的结果说明。
+// reviewLock 只在进程内串行化投票，多个进程同时作出决定时由 ReviewChannelApplication 的条件更新保证申请只被审核一次，
+// 批准和拒绝的其他操作都在状态更新成功之后才执行
+func (h *Handler) voteOnApplication(app models.ChannelApplication, voterID int64, vote, note string) (string, error) {
+	h.reviewLock.Lock()
+	defer h.reviewLock.Unlock()
+
+	// 等待锁期间申请可能已被其他管理员审核
+	current, err := h.DB.GetChannelApplicationByID(app.ID)
+	if err != nil {
+		return "", fmt.Errorf("获取申请失败: %s", err.Error())
+	}
+	if current.Status != "pending" {
+		return "", fmt.Errorf("申请 #%d %s，无需再次审核", app.ID, formatApplicationStatus(current.Status))
+	}
+	app = current
+
+	settings, err := h.DB.GetOrCreateGroupSettings(app.ChatID)
+	if err != nil {
+		return "", err
+	}
+	quorum := reviewQuorum(settings)
+
+	err = h.DB.RecordApplicationVote(models.ApplicationVote{ApplicationID: app.ID, VoterID: voterID, Vote: vote, Note: note})
+	if err != nil {
+		return "", fmt.Errorf("记录投票失败: %s", err.Error())
+	}
+	votes, err := h.DB.GetApplicationVotes(app.ID)
+	if err != nil {
+		return "", fmt.Errorf("获取投票失败: %s", err.Error())
+	}
+
+	approvals, rejected := countApprovals(votes)
+	switch {
+	case rejected:
+		channelName, err := h.rejectApplication(app, voterID, note)
+		if err != nil {
+			return "", err
+		}
+		h.refreshApplicationNotices(app, channelName, votes, quorum, "❌ 申请已被拒绝")
+		return fmt.Sprintf("已拒绝频道「%s」的发言申请 #%d", channelName, app.ID), nil
+	case approvals >= quorum:
+		channelName, err := h.approveApplication(app, voterID, note)
+		if err != nil {
+			return "", err
+		}
+		h.refreshApplicationNotices(app, channelName, votes, quorum, "✅ 申请已被批准")
+		return fmt.Sprintf("已批准频道「%s」的发言申请 #%d", channelName, app.ID), nil
+	}
+
+	channelName := h.getChannelName(app.ChannelID)
+	// 其他进程可能已经审核了申请，这时不再恢复通知中的按钮
+	if current, err := h.DB.GetChannelApplicationByID(app.ID); err == nil && current.Status == "pending" {
+		h.refreshApplicationNotices(app, channelName, votes, quorum, "")
+	}
+	return fmt.Sprintf("已记录您对频道「%s」申请 #%d 的批准票（%d/%d），还需要 %d 位管理员批准",
+		channelName, app.ID, approvals, quorum, quorum-approvals), nil
+}
+
+// refreshApplicationNotices 在所有管理员的申请通知中显示投票进度。result 不为空表示申请已审核，同时移除按钮
+func (h *Handler) refreshApplicationNotices(app models.ChannelApplication, channelName string, votes []models.ApplicationVote, quorum int, result string) {
+	notices, err := h.DB.GetApplicationNotices(app.ID)
+	if err != nil || len(notices) == 0 {
+		return
+	}
+
+	text, err := h.applicationNoticeText(app, channelName)
+	if err != nil {
+		fmt.Printf("生成申请 #%d 的通知失败: %s\n", app.ID, err.Error())
+		return
+	}
+	if quorum > 1 {
+		text += "\n\n" + formatReviewQuorum(quorum)
+	}
+	text += "\n\n" + formatVoteTally(votes, quorum)
+	if result != "" {
+		text += "\n\n" + result
+	}
+
+	for _, notice := range notices {
+		var editMsg tgbotapi.EditMessageTextConfig
+		if result == "" {
+			editMsg = tgbotapi.NewEditMessageTextAndMarkup(notice.ChatID, notice.MessageID, text, applicationNoticeKeyboard(app.ID))
+		} else {
+			editMsg = tgbotapi.NewEditMessageText(notice.ChatID, notice.MessageID, text)
+		}
+		_, _ = h.Bot.Send(editMsg)
+	}
+}
+
+// HandleQuorum 查看或设置批准申请所需的管理员票数
+func (h *Handler) HandleQuorum(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	settings, err := h.DB.GetOrCreateGroupSettings(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	arg := strings.TrimSpace(args)
+	if arg == "" {
+		text := fmt.Sprintf("申请审核: %s\n\n"+
+			"使用 /quorum 票数 设置批准申请所需的管理员票数（1 到 %d），任何一位管理员拒绝即拒绝申请",
+			formatReviewQuorum(reviewQuorum(settings)), maxReviewQuorum)
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	quorum, err := strconv.Atoi(arg)
+	if err != nil || quorum < 1 || quorum > maxReviewQuorum {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("票数必须在 1 到 %d 之间，格式：/quorum 票数", maxReviewQuorum))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	before := reviewQuorum(settings)
+	settings.ReviewQuorum = quorum
+	err = h.DB.UpdateGroupSettings(settings)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("更新设置失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSettingsUpdate, 0,
+		"申请审核: "+formatReviewQuorum(before), "申请审核: "+formatReviewQuorum(quorum))
+
+	text := fmt.Sprintf("申请审核已设置为: %s", formatReviewQuorum(quorum))
+	if quorum > 1 {
+		text += "\n\n已有的投票保留，待处理的申请在下一次投票时按新的票数判断"
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
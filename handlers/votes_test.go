@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+)
+
+// verifiedApplication 创建已由 testOwnerID 认领并通过验证的申请
+func verifiedApplication(t *testing.T, h *Handler) models.ChannelApplication {
+	t.Helper()
+
+	if err := h.DB.CreateChannelApplication(testChatID, testChannelID, 0, "理由"); err != nil {
+		t.Fatalf("创建申请失败: %v", err)
+	}
+	if err := h.DB.UpdateChannelApplicationUser(testChatID, testChannelID, testOwnerID); err != nil {
+		t.Fatalf("认领申请失败: %v", err)
+	}
+	if err := h.DB.VerifyChannelOwnership(testChatID, testChannelID, testOwnerID); err != nil {
+		t.Fatalf("验证所有权失败: %v", err)
+	}
+	app, err := h.DB.GetPendingChannelApplication(testChatID, testChannelID)
+	if err != nil {
+		t.Fatalf("获取待处理申请失败: %v", err)
+	}
+	return app
+}
+
+func TestReviewQuorum(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	settings, err := h.DB.GetOrCreateGroupSettings(testChatID)
+	if err != nil {
+		t.Fatalf("获取群组设置失败: %v", err)
+	}
+	settings.ReviewQuorum = 2
+	if err := h.DB.UpdateGroupSettings(settings); err != nil {
+		t.Fatalf("更新群组设置失败: %v", err)
+	}
+	app := verifiedApplication(t, h)
+
+	if _, err := h.voteOnApplication(app, testGlobalAdminID, models.VoteApprove, ""); err != nil {
+		t.Fatalf("第一票失败: %v", err)
+	}
+	// 同一位管理员重复投票不增加票数
+	if _, err := h.voteOnApplication(app, testGlobalAdminID, models.VoteApprove, ""); err != nil {
+		t.Fatalf("重复投票失败: %v", err)
+	}
+	if current, err := h.DB.GetChannelApplicationByID(app.ID); err != nil || current.Status != "pending" {
+		t.Fatalf("票数不足时申请状态为 %s（%v），期望 pending", current.Status, err)
+	}
+
+	if _, err := h.voteOnApplication(app, testGroupAdminID, models.VoteApprove, ""); err != nil {
+		t.Fatalf("第二票失败: %v", err)
+	}
+	if current, err := h.DB.GetChannelApplicationByID(app.ID); err != nil || current.Status != "approved" {
+		t.Fatalf("达到票数后申请状态为 %s（%v），期望 approved", current.Status, err)
+	}
+	if whitelisted, err := h.DB.IsChannelWhitelisted(testChatID, testChannelID); err != nil || !whitelisted {
+		t.Fatalf("批准后频道不在白名单中（%v）", err)
+	}
+
+	// 已审核的申请不能再投票
+	if _, err := h.voteOnApplication(app, 3, models.VoteReject, ""); err == nil {
+		t.Fatal("已批准的申请仍可以投票")
+	}
+}
+
+func TestReviewQuorumRejectWins(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	settings, err := h.DB.GetOrCreateGroupSettings(testChatID)
+	if err != nil {
+		t.Fatalf("获取群组设置失败: %v", err)
+	}
+	settings.ReviewQuorum = 2
+	if err := h.DB.UpdateGroupSettings(settings); err != nil {
+		t.Fatalf("更新群组设置失败: %v", err)
+	}
+	app := verifiedApplication(t, h)
+
+	if _, err := h.voteOnApplication(app, testGlobalAdminID, models.VoteApprove, ""); err != nil {
+		t.Fatalf("批准票失败: %v", err)
+	}
+	if _, err := h.voteOnApplication(app, testGroupAdminID, models.VoteReject, "不符合要求"); err != nil {
+		t.Fatalf("拒绝票失败: %v", err)
+	}
+	if current, err := h.DB.GetChannelApplicationByID(app.ID); err != nil || current.Status != "rejected" {
+		t.Fatalf("有人拒绝后申请状态为 %s（%v），期望 rejected", current.Status, err)
+	}
+	if whitelisted, _ := h.DB.IsChannelWhitelisted(testChatID, testChannelID); whitelisted {
+		t.Fatal("被拒绝的频道进入了白名单")
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// expiryInterval 检查临时白名单、临时封禁和待处理申请是否到期的间隔
+const expiryInterval = time.Minute
+
+// processExpiry 定期移除已过期的临时白名单，解除已到期的临时封禁，标记超时的待处理申请，并清理过期的发言记录
+func (h *Handler) processExpiry() {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.removeExpiredWhitelistEntries()
+		h.liftExpiredSenderBans()
+		h.expireStaleApplications()
+		h.pruneChannelPosts()
+	}
+}
+
+// removeExpiredWhitelistEntries 移除已过期的临时白名单，并通知群组和频道的认领人
+func (h *Handler) removeExpiredWhitelistEntries() {
+	expired, err := h.DB.RemoveExpiredWhitelistEntries(time.Now())
+	if err != nil {
+		fmt.Printf("移除过期的临时白名单失败: %s\n", err.Error())
+		return
+	}
+
+	for _, channel := range expired {
+		h.audit(channel.ChatID, 0, models.AuditActionWhitelistRemove, channel.ChannelID,
+			"有效期至 "+channel.ExpiresAt.Format("2006-01-02 15:04"), "已过期")
+
+		channelName := h.getChannelName(channel.ChannelID)
+
+		// 通知群组
+		groupMsg := tgbotapi.NewMessage(channel.ChatID, fmt.Sprintf("频道「%s」的临时白名单已到期，已自动移除", channelName))
+		_, _ = h.Bot.Send(groupMsg)
+
+		// 通知频道的认领人
+		claimantID, err := h.channelClaimant(channel.ChatID, channel.ChannelID)
+		if err != nil {
+			fmt.Printf("获取频道 %d 的认领人失败: %s\n", channel.ChannelID, err.Error())
+			continue
+		}
+		if claimantID != 0 {
+			notifyMsg := tgbotapi.NewMessage(claimantID, fmt.Sprintf("频道「%s」在群组中的临时发言权限已到期，如需继续发言请重新申请", channelName))
+			_, _ = h.Bot.Send(notifyMsg)
+		}
+	}
+}
+
+// channelClaimant 获取频道在群组中最近一次申请的认领人，没有时返回 0
+func (h *Handler) channelClaimant(chatID, channelID int64) (int64, error) {
+	applications, err := h.DB.GetChannelApplicationHistory(chatID, channelID)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(applications) - 1; i >= 0; i-- {
+		app := applications[i]
+		if app.ClaimantID != 0 {
+			return app.ClaimantID, nil
+		}
+		if app.UserID != 0 {
+			return app.UserID, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/anhe/tg-whitelist-bot/db/models"
+	"github.com/anhe/tg-whitelist-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// whitelistSetNamePattern 共享白名单名称的格式
+var whitelistSetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,31}$`)
+
+// whitelistSetUsage /wlset 命令的用法
+const whitelistSetUsage = "用法:\n" +
+	"/wlset - 查看您管理的共享白名单\n" +
+	"/wlset create 名称 [描述] - 创建共享白名单\n" +
+	"/wlset delete 名称 - 删除共享白名单\n" +
+	"/wlset show 名称 - 查看共享白名单中的频道\n" +
+	"/wlset add 名称 频道 - 添加频道\n" +
+	"/wlset remove 名称 频道 - 移除频道\n\n" +
+	"名称由 2 到 32 个小写字母、数字、- 或 _ 组成，频道可以是频道ID、@用户名或 t.me 链接"
+
+// formatSetNames 将共享白名单的名称用顿号连接
+func formatSetNames(sets []models.WhitelistSet) string {
+	names := make([]string, 0, len(sets))
+	for _, set := range sets {
+		names = append(names, set.Name)
+	}
+	return strings.Join(names, "、")
+}
+
+// canManageSet 检查用户是否可以管理共享白名单，所有者和全局管理员可以管理
+func (h *Handler) canManageSet(set models.WhitelistSet, userID int64) bool {
+	return set.OwnerID == userID || utils.IsGlobalAdmin(h.Config.AdminUsers, userID)
+}
+
+// HandleWhitelistSet 在私聊中管理共享白名单
+func (h *Handler) HandleWhitelistSet(message *tgbotapi.Message, args string) error {
+	// 只允许私聊使用
+	if message.Chat.Type != "private" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "请在私聊中使用此命令管理共享白名单")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return h.sendOwnedWhitelistSets(message)
+	}
+	if len(fields) < 2 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, whitelistSetUsage)
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	subcommand := strings.ToLower(fields[0])
+	name := strings.ToLower(fields[1])
+	if !whitelistSetNamePattern.MatchString(name) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的共享白名单名称: %s\n\n%s", fields[1], whitelistSetUsage))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	set, err := h.DB.GetWhitelistSet(name)
+	if err != nil {
+		return err
+	}
+
+	// 创建共享白名单
+	if subcommand == "create" {
+		if set.ID != 0 {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("共享白名单「%s」已存在", name))
+			_, err := h.Bot.Send(msg)
+			return err
+		}
+
+		description := strings.Join(fields[2:], " ")
+		if _, err := h.DB.CreateWhitelistSet(name, message.From.ID, description); err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("创建共享白名单失败: %s", err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已创建共享白名单「%s」\n\n"+
+			"使用 /wlset add %s 频道 添加频道，群组管理员可以在群内发送 /subscribe %s 订阅", name, name, name))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	if set.ID == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("共享白名单「%s」不存在", name))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 查看频道不需要管理权限
+	if subcommand == "show" {
+		return h.sendWhitelistSetChannels(message.Chat.ID, set)
+	}
+
+	if !h.canManageSet(set, message.From.ID) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "只有共享白名单的所有者可以管理该名单")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	var text string
+	switch subcommand {
+	case "delete":
+		if err := h.DB.DeleteWhitelistSet(set.ID); err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("删除共享白名单失败: %s", err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+		text = fmt.Sprintf("已删除共享白名单「%s」，订阅该名单的群组不再应用其中的频道", name)
+	case "add", "remove", "del":
+		if len(fields) < 3 {
+			text = whitelistSetUsage
+			break
+		}
+
+		channelID, _, err := h.resolveChannel(fields[2])
+		if err != nil {
+			msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("无效的频道: %s", err.Error()))
+			_, _ = h.Bot.Send(msg)
+			return err
+		}
+		channelName := h.getChannelName(channelID)
+
+		if subcommand == "add" {
+			if err := h.DB.AddChannelToSet(set.ID, channelID, message.From.ID); err != nil {
+				msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("添加频道失败: %s", err.Error()))
+				_, _ = h.Bot.Send(msg)
+				return err
+			}
+			text = fmt.Sprintf("已将频道「%s」添加到共享白名单「%s」", channelName, name)
+		} else {
+			if err := h.DB.RemoveChannelFromSet(set.ID, channelID); err != nil {
+				msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("移除频道失败: %s", err.Error()))
+				_, _ = h.Bot.Send(msg)
+				return err
+			}
+			text = fmt.Sprintf("已将频道「%s」从共享白名单「%s」移除", channelName, name)
+		}
+	default:
+		text = whitelistSetUsage
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// sendOwnedWhitelistSets 发送用户管理的共享白名单，全局管理员可以看到全部名单
+func (h *Handler) sendOwnedWhitelistSets(message *tgbotapi.Message) error {
+	ownerID := message.From.ID
+	if utils.IsGlobalAdmin(h.Config.AdminUsers, message.From.ID) {
+		ownerID = 0
+	}
+
+	sets, err := h.DB.GetWhitelistSets(ownerID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("获取共享白名单失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	var text string
+	if len(sets) == 0 {
+		text = "您还没有管理的共享白名单\n\n"
+	} else {
+		text = fmt.Sprintf("📋 共享白名单（共 %d 个）:\n\n", len(sets))
+		for i, set := range sets {
+			channels, err := h.DB.GetSetChannels(set.ID)
+			if err != nil {
+				return err
+			}
+			text += fmt.Sprintf("%d. %s（%d 个频道）\n", i+1, set.Name, len(channels))
+			if set.Description != "" {
+				text += fmt.Sprintf("    描述: %s\n", set.Description)
+			}
+			if ownerID == 0 {
+				text += fmt.Sprintf("    所有者: %d\n", set.OwnerID)
+			}
+		}
+		text += "\n"
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text+whitelistSetUsage)
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// sendWhitelistSetChannels 发送共享白名单中的频道列表
+func (h *Handler) sendWhitelistSetChannels(chatID int64, set models.WhitelistSet) error {
+	channels, err := h.DB.GetSetChannels(set.ID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("获取共享白名单失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	var text string
+	if len(channels) == 0 {
+		text = fmt.Sprintf("共享白名单「%s」中没有频道", set.Name)
+	} else {
+		text = fmt.Sprintf("📋 共享白名单「%s」（共 %d 个频道）:\n\n", set.Name, len(channels))
+		for i, channel := range channels {
+			text += fmt.Sprintf("%d. 频道「%s」(ID: %d)\n    添加时间: %s\n",
+				i+1, h.getChannelName(channel.ChannelID), channel.ChannelID, channel.AddedAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// HandleSubscribe 查看本群订阅的共享白名单，或订阅指定的共享白名单
+func (h *Handler) HandleSubscribe(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	name := strings.ToLower(strings.TrimSpace(args))
+	if name == "" {
+		return h.sendSubscribedSets(message.Chat.ID)
+	}
+
+	set, err := h.DB.GetWhitelistSet(name)
+	if err != nil {
+		return err
+	}
+	if set.ID == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("共享白名单「%s」不存在", name))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	if err := h.DB.SubscribeToSet(message.Chat.ID, set.ID, message.From.ID); err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("订阅共享白名单失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSetSubscribe, 0, "", set.Name)
+
+	channels, err := h.DB.GetSetChannels(set.ID)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已订阅共享白名单「%s」，其中的 %d 个频道可以在本群发言", set.Name, len(channels)))
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// HandleUnsubscribe 取消订阅共享白名单
+func (h *Handler) HandleUnsubscribe(message *tgbotapi.Message, args string) error {
+	// 只在群组中工作
+	if message.Chat.Type != "group" && message.Chat.Type != "supergroup" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "此命令只能在群组中使用")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	// 检查权限
+	if ok, err := h.requireGroupAdmin(message); !ok {
+		return err
+	}
+
+	name := strings.ToLower(strings.TrimSpace(args))
+	if name == "" {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "请提供共享白名单的名称，格式：/unsubscribe 名称")
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	subscribed, err := h.DB.GetSubscribedSets(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var target models.WhitelistSet
+	for _, set := range subscribed {
+		if set.Name == name {
+			target = set
+			break
+		}
+	}
+	if target.ID == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("本群没有订阅共享白名单「%s」", name))
+		_, err := h.Bot.Send(msg)
+		return err
+	}
+
+	if err := h.DB.UnsubscribeFromSet(message.Chat.ID, target.ID); err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("取消订阅失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+	h.audit(message.Chat.ID, message.From.ID, models.AuditActionSetUnsubscribe, 0, target.Name, "")
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已取消订阅共享白名单「%s」", target.Name))
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// sendSubscribedSets 发送群组订阅的共享白名单
+func (h *Handler) sendSubscribedSets(chatID int64) error {
+	sets, err := h.DB.GetSubscribedSets(chatID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("获取订阅失败: %s", err.Error()))
+		_, _ = h.Bot.Send(msg)
+		return err
+	}
+
+	text, err := h.formatSubscribedSets(sets)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		text = "本群没有订阅共享白名单\n"
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text+"\n使用 /subscribe 名称 订阅，/unsubscribe 名称 取消订阅")
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+// formatSubscribedSets 格式化群组订阅的共享白名单及其频道数量，没有订阅时返回空字符串
+func (h *Handler) formatSubscribedSets(sets []models.WhitelistSet) (string, error) {
+	if len(sets) == 0 {
+		return "", nil
+	}
+
+	text := "🔗 订阅的共享白名单:\n"
+	for _, set := range sets {
+		channels, err := h.DB.GetSetChannels(set.ID)
+		if err != nil {
+			return "", err
+		}
+		text += fmt.Sprintf("- %s（%d 个频道）\n", set.Name, len(channels))
+	}
+	return text, nil
+}
@@ -2,8 +2,10 @@ package utils
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -58,11 +60,78 @@ func ParseChannelID(args string) (int64, error) {
 	return channelID, nil
 }
 
+// ChannelRef 从命令参数解析出的频道引用，ID 和 Username 只有一个非零值
+type ChannelRef struct {
+	ID       int64  // 频道ID
+	Username string // 频道用户名，不含 @
+}
+
+// channelUsernamePattern Telegram 公开用户名的格式
+var channelUsernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// ParseChannelRef 解析频道ID、@用户名、https://t.me/用户名 或 https://t.me/c/内部ID 形式的频道引用，
+// 链接后面的消息编号会被忽略
+func ParseChannelRef(arg string) (ChannelRef, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return ChannelRef{}, fmt.Errorf("频道不能为空")
+	}
+
+	// 数字ID，兼容带 @ 前缀的写法
+	if channelID, err := strconv.ParseInt(strings.TrimPrefix(arg, "@"), 10, 64); err == nil {
+		return ChannelRef{ID: channelID}, nil
+	}
+
+	var username string
+	if strings.HasPrefix(arg, "@") {
+		username = arg[1:]
+	} else {
+		link := strings.TrimPrefix(strings.TrimPrefix(arg, "https://"), "http://")
+		link = strings.TrimPrefix(link, "www.")
+		var path string
+		switch {
+		case strings.HasPrefix(link, "t.me/"):
+			path = strings.TrimPrefix(link, "t.me/")
+		case strings.HasPrefix(link, "telegram.me/"):
+			path = strings.TrimPrefix(link, "telegram.me/")
+		default:
+			return ChannelRef{}, fmt.Errorf("无法识别的频道: %s，请使用频道ID、@用户名或 t.me 链接", arg)
+		}
+		if i := strings.IndexAny(path, "?#"); i >= 0 {
+			path = path[:i]
+		}
+
+		parts := strings.Split(path, "/")
+		if parts[0] == "c" {
+			// 私有频道的消息链接 t.me/c/内部ID/消息编号，频道ID为 -100 加内部ID
+			if len(parts) < 2 {
+				return ChannelRef{}, fmt.Errorf("无效的频道链接: %s", arg)
+			}
+			internalID, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil || internalID <= 0 {
+				return ChannelRef{}, fmt.Errorf("无效的频道链接: %s", arg)
+			}
+			return ChannelRef{ID: -1000000000000 - internalID}, nil
+		}
+		username = parts[0]
+	}
+
+	if !channelUsernamePattern.MatchString(username) {
+		return ChannelRef{}, fmt.Errorf("无效的频道用户名: %s", username)
+	}
+	return ChannelRef{Username: username}, nil
+}
+
 // IsChannelMessage 检查消息是否来自频道
 func IsChannelMessage(message *tgbotapi.Message) bool {
 	return message.SenderChat != nil && message.SenderChat.Type == "channel"
 }
 
+// IsAnonymousAdminMessage 检查消息是否由匿名管理员以群组身份发送，只有管理员可以匿名发言
+func IsAnonymousAdminMessage(message *tgbotapi.Message) bool {
+	return message.SenderChat != nil && message.Chat != nil && message.SenderChat.ID == message.Chat.ID
+}
+
 // GetChannelID 获取消息的频道ID
 func GetChannelID(message *tgbotapi.Message) int64 {
 	if message.SenderChat != nil {
@@ -88,6 +157,64 @@ func TruncateText(text string, maxLength int) string {
 	return text[:maxLength-3] + "..."
 }
 
+// durationUnits 时长参数支持的单位
+var durationUnits = map[byte]time.Duration{
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// ParseDuration 解析 30m、12h、7d、2w 形式的时长
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return 0, fmt.Errorf("无效的时长: %s", s)
+	}
+
+	unit, ok := durationUnits[s[len(s)-1]]
+	if !ok {
+		return 0, fmt.Errorf("无效的时长单位: %s，可用单位: m、h、d、w", s)
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的时长: %s", s)
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+// IsDuration 判断参数是否是时长
+func IsDuration(s string) bool {
+	_, err := ParseDuration(s)
+	return err == nil
+}
+
+// FormatDuration 将时长格式化为「3天4小时」形式，不足一分钟按一分钟计
+func FormatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "1分钟"
+	}
+
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%d天", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%d小时", hours))
+	}
+	// 超过一天时不再显示分钟
+	if minutes > 0 && days == 0 {
+		parts = append(parts, fmt.Sprintf("%d分钟", minutes))
+	}
+	return strings.Join(parts, "")
+}
+
 // IsMentioningBot 检查消息是否艾特了机器人
 func IsMentioningBot(message *tgbotapi.Message, botUsername string) bool {
 	// 检查消息文本中是否包含@botUsername